@@ -0,0 +1,179 @@
+package main
+
+import (
+  "fmt"
+
+  "github.com/prometheus/client_golang/prometheus"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Names of the aggregated metrics
+const (
+  frontPlayersName       = "hde_front_players"
+  frontKillsName         = "hde_front_kills"
+  frontDeathsName        = "hde_front_deaths"
+  frontMissionsWonName   = "hde_front_missions_won"
+  frontMissionsLostName  = "hde_front_missions_lost"
+  frontPlanetsName       = "hde_front_planets"
+  sectorPlayersName      = "hde_sector_players"
+  sectorKillsName        = "hde_sector_kills"
+  sectorDeathsName       = "hde_sector_deaths"
+  sectorMissionsWonName  = "hde_sector_missions_won"
+  sectorMissionsLostName = "hde_sector_missions_lost"
+  sectorPlanetsName      = "hde_sector_planets"
+)
+
+// Aggregates of the planet metrics, grouped by front (current owner of the
+// planet) and by sector, so dashboards do not have to sum over every planet
+var (
+  frontPlayers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: frontPlayersName,
+    Help: "Number of players on the planets owned by the faction",
+  }, []string{"faction"})
+  frontKills = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: frontKillsName,
+    Help: "Number of kills on the planets owned by the faction",
+  }, []string{"faction"})
+  frontDeaths = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: frontDeathsName,
+    Help: "Number of deaths on the planets owned by the faction",
+  }, []string{"faction"})
+  frontMissionsWon = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: frontMissionsWonName,
+    Help: "Number of missions won on the planets owned by the faction",
+  }, []string{"faction"})
+  frontMissionsLost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: frontMissionsLostName,
+    Help: "Number of missions lost on the planets owned by the faction",
+  }, []string{"faction"})
+  frontPlanets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: frontPlanetsName,
+    Help: "Number of planets owned by the faction",
+  }, []string{"faction"})
+
+  sectorPlayers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: sectorPlayersName,
+    Help: "Number of players in the sector",
+  }, []string{"sector"})
+  sectorKills = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: sectorKillsName,
+    Help: "Number of kills in the sector",
+  }, []string{"sector"})
+  sectorDeaths = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: sectorDeathsName,
+    Help: "Number of deaths in the sector",
+  }, []string{"sector"})
+  sectorMissionsWon = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: sectorMissionsWonName,
+    Help: "Number of missions won in the sector",
+  }, []string{"sector"})
+  sectorMissionsLost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: sectorMissionsLostName,
+    Help: "Number of missions lost in the sector",
+  }, []string{"sector"})
+  sectorPlanets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: sectorPlanetsName,
+    Help: "Number of planets of the sector owned by the faction",
+  }, []string{"sector", "faction"})
+)
+
+// Name of a faction, as used in metric labels
+func factionName(faction client.FactionEnum) string {
+  switch faction {
+  case client.SUPEREARTH:
+    return "super_earth"
+  case client.TERMINIDS:
+    return "terminids"
+  case client.AUTOMATONS:
+    return "automatons"
+  case 4:
+    return "illuminate"
+  default:
+    return fmt.Sprintf("unknown_%d", faction)
+  }
+}
+
+// Sums of planet statistics for a group of planets
+type planetAggregate struct {
+  players      float64
+  kills        float64
+  deaths       float64
+  missionsWon  float64
+  missionsLost float64
+}
+
+// Compute and export the per front and per sector aggregates
+func aggregate(snapshot *WarSnapshot, staticData *staticData) {
+  sectors := map[int32]string{}
+  for _, planet := range snapshot.Info.PlanetInfos {
+    sectors[planet.Index] = staticData.planetSector(snapshot, planet)
+  }
+  owners := map[int32]string{}
+  for _, planet := range snapshot.Status.PlanetStatus {
+    owners[planet.Index] = factionName(planet.Owner)
+  }
+
+  fronts := map[string]*planetAggregate{}
+  bySector := map[string]*planetAggregate{}
+  group := func(groups map[string]*planetAggregate, key string) *planetAggregate {
+    if _, ok := groups[key]; !ok {
+      groups[key] = &planetAggregate{}
+    }
+    return groups[key]
+  }
+  planets := map[string]float64{}
+  sectorOwners := map[[2]string]float64{}
+
+  for _, planet := range snapshot.Status.PlanetStatus {
+    owner, sector := owners[planet.Index], sectors[planet.Index]
+    group(fronts, owner).players += float64(planet.Players)
+    planets[owner]++
+    if sector != "" {
+      group(bySector, sector).players += float64(planet.Players)
+      sectorOwners[[2]string{sector, owner}]++
+    }
+  }
+  for _, planet := range snapshot.Stats.PlanetsStats {
+    kills := float64(planet.BugKills + planet.AutomatonKills + planet.IlluminateKills)
+    groups := []*planetAggregate{}
+    if owner, ok := owners[planet.PlanetIndex]; ok {
+      groups = append(groups, group(fronts, owner))
+    }
+    if sector, ok := sectors[planet.PlanetIndex]; ok {
+      groups = append(groups, group(bySector, sector))
+    }
+    for _, g := range groups {
+      g.kills += kills
+      g.deaths += float64(planet.Deaths)
+      g.missionsWon += float64(planet.MissionsWon)
+      g.missionsLost += float64(planet.MissionsLost)
+    }
+  }
+
+  // Fronts and sectors come and go with the war, start from a clean slate
+  for _, vec := range []*prometheus.GaugeVec{
+    frontPlayers, frontKills, frontDeaths, frontMissionsWon, frontMissionsLost, frontPlanets,
+    sectorPlayers, sectorKills, sectorDeaths, sectorMissionsWon, sectorMissionsLost, sectorPlanets,
+  } {
+    vec.Reset()
+  }
+  for faction, g := range fronts {
+    frontPlayers.WithLabelValues(faction).Set(g.players)
+    frontKills.WithLabelValues(faction).Set(g.kills)
+    frontDeaths.WithLabelValues(faction).Set(g.deaths)
+    frontMissionsWon.WithLabelValues(faction).Set(g.missionsWon)
+    frontMissionsLost.WithLabelValues(faction).Set(g.missionsLost)
+    frontPlanets.WithLabelValues(faction).Set(planets[faction])
+  }
+  for sector, g := range bySector {
+    sectorPlayers.WithLabelValues(sector).Set(g.players)
+    sectorKills.WithLabelValues(sector).Set(g.kills)
+    sectorDeaths.WithLabelValues(sector).Set(g.deaths)
+    sectorMissionsWon.WithLabelValues(sector).Set(g.missionsWon)
+    sectorMissionsLost.WithLabelValues(sector).Set(g.missionsLost)
+  }
+  for key, count := range sectorOwners {
+    sectorPlanets.WithLabelValues(key[0], key[1]).Set(count)
+  }
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "sync"
+
+  "github.com/prometheus/client_golang/prometheus"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Cumulative fields of BattleStatistics.
+// They only grow during a war, and are exported as counters.
+type battleStatField struct {
+  // Metric name, without the hde_galaxy_ / hde_planet_ prefix and _total suffix
+  name  string
+  help  string
+  value func(client.BattleStatistics) int64
+}
+
+var battleStatFields = []battleStatField{
+  {"missions_won", "Number of missions won", func(s client.BattleStatistics) int64 { return s.MissionsWon }},
+  {"missions_lost", "Number of missions lost", func(s client.BattleStatistics) int64 { return s.MissionsLost }},
+  {"mission_time_seconds", "Time spent on missions", func(s client.BattleStatistics) int64 { return s.MissionTime }},
+  {"bug_kills", "Number of bug kills", func(s client.BattleStatistics) int64 { return s.BugKills }},
+  {"automaton_kills", "Number of automaton kills", func(s client.BattleStatistics) int64 { return s.AutomatonKills }},
+  {"illuminate_kills", "Number of illuminate kills", func(s client.BattleStatistics) int64 { return s.IlluminateKills }},
+  {"bullets_fired", "Number of bullets fired", func(s client.BattleStatistics) int64 { return s.BulletsFired }},
+  {"bullets_hit", "Number of bullets hit", func(s client.BattleStatistics) int64 { return s.BulletsHit }},
+  {"time_played_seconds", "Time played", func(s client.BattleStatistics) int64 { return s.TimePlayed }},
+  {"deaths", "Number of deaths", func(s client.BattleStatistics) int64 { return s.Deaths }},
+  {"revives", "Number of revives", func(s client.BattleStatistics) int64 { return s.Revives }},
+  {"friendlies", "Number of friendlies (fire?)", func(s client.BattleStatistics) int64 { return s.Friendlies }},
+}
+
+const battleStatsResetsName = "hde_battle_stats_resets_total"
+
+var battleStatsResets = prometheus.NewCounterVec(prometheus.CounterOpts{
+  Name: battleStatsResetsName,
+  Help: "Number of times a cumulative battle statistic decreased upstream (reset or rollback)",
+}, []string{"field", "planet"})
+
+// Modes of the battle_stats_mode configuration key
+const (
+  // Legacy gauges only
+  battleStatsModeGauge = "gauge"
+  // _total counters only
+  battleStatsModeCounter = "counter"
+  // Both, to migrate dashboards and alerts
+  battleStatsModeBoth = "both"
+)
+
+// Are the cumulative battle statistics exported as gauges in a battle_stats_mode
+func battleStatsGauges(mode string) bool {
+  return mode == battleStatsModeGauge || mode == battleStatsModeBoth
+}
+
+// Are the cumulative battle statistics exported as counters in a battle_stats_mode
+func battleStatsCounters(mode string) bool {
+  return mode == battleStatsModeCounter || mode == battleStatsModeBoth
+}
+
+func validateBattleStatsMode(mode string) error {
+  switch mode {
+  case battleStatsModeGauge, battleStatsModeCounter, battleStatsModeBoth:
+    return nil
+  default:
+    return fmt.Errorf("invalid battle_stats_mode %q, expected one of gauge, counter, both", mode)
+  }
+}
+
+// Statistics of each planet as BattleStatistics, indexed by planet index
+func planetsBattleStatistics(stats *client.WarStatistics) map[int32]client.BattleStatistics {
+  planets := map[int32]client.BattleStatistics{}
+  for _, p := range stats.PlanetsStats {
+    planets[p.PlanetIndex] = client.BattleStatistics{
+      MissionsWon:        p.MissionsWon,
+      MissionsLost:       p.MissionsLost,
+      MissionTime:        p.MissionTime,
+      BugKills:           p.BugKills,
+      AutomatonKills:     p.AutomatonKills,
+      IlluminateKills:    p.IlluminateKills,
+      BulletsFired:       p.BulletsFired,
+      BulletsHit:         p.BulletsHit,
+      TimePlayed:         p.TimePlayed,
+      Deaths:             p.Deaths,
+      Revives:            p.Revives,
+      Friendlies:         p.Friendlies,
+      MissionSuccessRate: p.MissionSuccessRate,
+      Accuracy:           p.Accuracy,
+    }
+  }
+  return planets
+}
+
+// battleStatsCollector exports the cumulative battle statistics as counters.
+// Upstream values are totals, so they are exposed as is with constant
+// metrics instead of being incremented.
+type battleStatsCollector struct {
+  mu      sync.Mutex
+  galaxy  *client.BattleStatistics
+  planets map[string]client.BattleStatistics
+
+  galaxyDescs []*prometheus.Desc
+  planetDescs []*prometheus.Desc
+}
+
+func newBattleStatsCollector() *battleStatsCollector {
+  c := &battleStatsCollector{planets: map[string]client.BattleStatistics{}}
+  for _, field := range battleStatFields {
+    c.galaxyDescs = append(c.galaxyDescs, prometheus.NewDesc(
+      "hde_galaxy_"+field.name+"_total",
+      field.help+" in the galaxy",
+      nil, nil,
+    ))
+    c.planetDescs = append(c.planetDescs, prometheus.NewDesc(
+      "hde_planet_"+field.name+"_total",
+      field.help+" on the planet",
+      []string{"planet"}, nil,
+    ))
+  }
+  return c
+}
+
+var battleStats = newBattleStatsCollector()
+
+func (c *battleStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+  for _, desc := range c.galaxyDescs {
+    ch <- desc
+  }
+  for _, desc := range c.planetDescs {
+    ch <- desc
+  }
+}
+
+func (c *battleStatsCollector) Collect(ch chan<- prometheus.Metric) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  for i, field := range battleStatFields {
+    if c.galaxy != nil {
+      ch <- prometheus.MustNewConstMetric(c.galaxyDescs[i], prometheus.CounterValue, float64(field.value(*c.galaxy)))
+    }
+    for planet, stats := range c.planets {
+      ch <- prometheus.MustNewConstMetric(c.planetDescs[i], prometheus.CounterValue, float64(field.value(stats)), planet)
+    }
+  }
+}
+
+// Replace the statistics with the ones of the latest scrape.
+// A value lower than the previous one means the upstream counter was reset
+// or rolled back, it is counted in hde_battle_stats_resets_total.
+func (c *battleStatsCollector) update(galaxy client.BattleStatistics, planets map[string]client.BattleStatistics) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  if c.galaxy != nil {
+    detectBattleStatsResets("", *c.galaxy, galaxy)
+  }
+  for planet, stats := range planets {
+    if previous, ok := c.planets[planet]; ok {
+      detectBattleStatsResets(planet, previous, stats)
+    }
+  }
+  c.galaxy = &galaxy
+  c.planets = planets
+}
+
+func detectBattleStatsResets(planet string, previous client.BattleStatistics, next client.BattleStatistics) {
+  for _, field := range battleStatFields {
+    before, after := field.value(previous), field.value(next)
+    if after >= before {
+      continue
+    }
+    slog.Warn(
+      "Battle statistic decreased upstream",
+      slog.String("field", field.name),
+      slog.String("planet", planet),
+      slog.Int64("previous", before),
+      slog.Int64("current", after),
+    )
+    battleStatsResets.WithLabelValues(field.name, planet).Inc()
+  }
+}
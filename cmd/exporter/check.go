@@ -0,0 +1,284 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "path/filepath"
+  "regexp"
+  "sort"
+  "strings"
+
+  "github.com/prometheus/prometheus/model/labels"
+  "github.com/prometheus/prometheus/promql/parser"
+  "github.com/spf13/pflag"
+  "github.com/spf13/viper"
+  "gopkg.in/yaml.v3"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/contract"
+)
+
+// Metrics registered by registerCollectors whatever its parameters: the
+// battle statistics and the legacy names aside. Checked against the
+// registered collectors by TestExporterMetrics.
+var exporterMetrics = []string{
+  buildInfoNamespace + "_build_info",
+  planetHealthName,
+  planetMaxHealthName,
+  planetRegenRateName,
+  planetPlayersName,
+  apiRequestDurationName,
+  apiRequestsName,
+  galaxyMissionSuccessRateName,
+  galaxyAccuracyName,
+  planetMissionSuccessRateName,
+  planetAccuracyName,
+  battleStatsResetsName,
+  galaxyKillsPerDeathName,
+  galaxyKillsPerMissionName,
+  galaxyDeathsPerMissionName,
+  galaxyWinRatioName,
+  galaxyComputedAccuracyName,
+  galaxyComputedAccuracyOverflowName,
+  galaxyRevivesPerDeathName,
+  planetKillsPerDeathName,
+  planetKillsPerMissionName,
+  planetDeathsPerMissionName,
+  planetWinRatioName,
+  planetComputedAccuracyName,
+  planetComputedAccuracyOverflowName,
+  planetRevivesPerDeathName,
+  planetInfoName,
+  planetHazardName,
+  frontPlayersName,
+  frontKillsName,
+  frontDeathsName,
+  frontMissionsWonName,
+  frontMissionsLostName,
+  frontPlanetsName,
+  sectorPlayersName,
+  sectorKillsName,
+  sectorDeathsName,
+  sectorMissionsWonName,
+  sectorMissionsLostName,
+  sectorPlanetsName,
+  planetDefenseHealthName,
+  planetDefenseMaxHealthName,
+  planetDefenseRemainingName,
+  globalEventInfoName,
+  globalEventPlanetName,
+  globalEventsName,
+  lastScrapeSuccessName,
+  warEventsTotalName,
+  webhookNotificationsName,
+  staticDataReloadsName,
+  contract.DriftFirstSeenName,
+  staticDataLastReloadName,
+  webConfigReloadsName,
+  webConfigLastReloadName,
+}
+
+// Names of the metrics the exporter registers in a battle_stats_mode.
+// Legacy names are left out, so dashboards and rules move to the new ones.
+func exporterMetricNames(battleStatsMode string) map[string]bool {
+  names := map[string]bool{}
+  for _, name := range exporterMetrics {
+    names[name] = true
+  }
+  for _, field := range battleStatFields {
+    for _, scope := range []string{"hde_galaxy_", "hde_planet_"} {
+      if battleStatsGauges(battleStatsMode) {
+        names[scope+field.name] = true
+      }
+      if battleStatsCounters(battleStatsMode) {
+        names[scope+field.name+"_total"] = true
+      }
+    }
+  }
+  return names
+}
+
+// A query referencing a metric the exporter does not register,
+// or a query that can not be parsed
+type queryProblem struct {
+  File   string
+  Expr   string
+  Metric string
+  Err    error
+}
+
+func (p queryProblem) String() string {
+  if p.Err != nil {
+    return fmt.Sprintf("%s: invalid query %q: %s", p.File, p.Expr, p.Err)
+  }
+  return fmt.Sprintf("%s: unknown metric %s in %q", p.File, p.Metric, p.Expr)
+}
+
+// Grafana variables that can not be parsed as PromQL, e.g. [$__rate_interval]
+var grafanaRangeRegexp = regexp.MustCompile(`\[\$\{?\w+\}?\]`)
+
+// Metric of a label_values(metric, label) variable query
+var labelValuesRegexp = regexp.MustCompile(`^label_values\(\s*([^,]+),`)
+
+// queryChecker checks that queries only reference known metrics
+type queryChecker struct {
+  // Metrics registered by the exporter, and recorded by the rules
+  metrics  map[string]bool
+  problems []queryProblem
+}
+
+func (c *queryChecker) known(name string) bool {
+  if c.metrics[name] {
+    return true
+  }
+  // Series of histograms and summaries
+  for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+    if strings.HasSuffix(name, suffix) && c.metrics[strings.TrimSuffix(name, suffix)] {
+      return true
+    }
+  }
+  return false
+}
+
+func (c *queryChecker) checkMetric(file string, query string, name string) {
+  if c.known(name) {
+    return
+  }
+  problem := queryProblem{File: file, Expr: query, Metric: name}
+  for _, p := range c.problems {
+    if p == problem {
+      return
+    }
+  }
+  c.problems = append(c.problems, problem)
+}
+
+func (c *queryChecker) checkExpr(file string, query string) {
+  expr, err := parser.ParseExpr(grafanaRangeRegexp.ReplaceAllString(query, "[5m]"))
+  if err != nil {
+    c.problems = append(c.problems, queryProblem{File: file, Expr: query, Err: err})
+    return
+  }
+  parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+    selector, ok := node.(*parser.VectorSelector)
+    if !ok {
+      return nil
+    }
+    for _, matcher := range selector.LabelMatchers {
+      if matcher.Name == labels.MetricName && matcher.Type == labels.MatchEqual {
+        c.checkMetric(file, query, matcher.Value)
+      }
+    }
+    return nil
+  })
+}
+
+// Walk a dashboard and check the expr of its targets, and its variables
+func (c *queryChecker) checkDashboardNode(file string, node any) {
+  switch v := node.(type) {
+  case map[string]any:
+    if expr, ok := v["expr"].(string); ok {
+      c.checkExpr(file, expr)
+    }
+    if definition, ok := v["definition"].(string); ok {
+      if match := labelValuesRegexp.FindStringSubmatch(definition); match != nil {
+        c.checkExpr(file, strings.TrimSpace(match[1]))
+      }
+    }
+    for _, child := range v {
+      c.checkDashboardNode(file, child)
+    }
+  case []any:
+    for _, child := range v {
+      c.checkDashboardNode(file, child)
+    }
+  }
+}
+
+// Parse the queries of the dashboards and rules, and report the ones
+// referencing metrics that cmd/exporter does not register in the battle_stats_mode
+func checkQueries(dashboardsDir string, rulesDir string, battleStatsMode string) ([]queryProblem, error) {
+  c := &queryChecker{metrics: exporterMetricNames(battleStatsMode)}
+
+  ruleFiles, err := filepath.Glob(filepath.Join(rulesDir, "*.yml"))
+  if err != nil {
+    return nil, err
+  }
+  rules := map[string]ruleFile{}
+  for _, path := range ruleFiles {
+    content, err := os.ReadFile(path)
+    if err != nil {
+      return nil, err
+    }
+    file := ruleFile{}
+    err = yaml.Unmarshal(content, &file)
+    if err != nil {
+      return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+    }
+    rules[path] = file
+    // Recorded series can be used by every query
+    for _, group := range file.Groups {
+      for _, r := range group.Rules {
+        if r.Record != "" {
+          c.metrics[r.Record] = true
+        }
+      }
+    }
+  }
+  for path, file := range rules {
+    for _, group := range file.Groups {
+      for _, r := range group.Rules {
+        c.checkExpr(path, r.Expr)
+      }
+    }
+  }
+
+  dashboardFiles, err := filepath.Glob(filepath.Join(dashboardsDir, "*.json"))
+  if err != nil {
+    return nil, err
+  }
+  for _, path := range dashboardFiles {
+    content, err := os.ReadFile(path)
+    if err != nil {
+      return nil, err
+    }
+    var dashboard any
+    err = json.Unmarshal(content, &dashboard)
+    if err != nil {
+      return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+    }
+    c.checkDashboardNode(path, dashboard)
+  }
+
+  sort.SliceStable(c.problems, func(i, j int) bool {
+    return c.problems[i].File < c.problems[j].File
+  })
+  return c.problems, nil
+}
+
+// `exporter check` subcommand, reports queries referencing unknown metrics
+func runCheck(args []string) error {
+  checkFlags := pflag.NewFlagSet("check", pflag.ExitOnError)
+  dashboardsDir := checkFlags.String("dashboards", "utils/dashboards", "Directory of the Grafana dashboards")
+  rulesDir := checkFlags.String("rules", "utils/rules", "Directory of the Prometheus rules")
+  battleStatsMode := checkFlags.String("battle-stats-mode", viper.GetString("battle_stats_mode"), "battle_stats_mode of the exporter whose metrics are queried")
+  err := checkFlags.Parse(args)
+  if err != nil {
+    return err
+  }
+  err = validateBattleStatsMode(*battleStatsMode)
+  if err != nil {
+    return err
+  }
+  problems, err := checkQueries(*dashboardsDir, *rulesDir, *battleStatsMode)
+  if err != nil {
+    return err
+  }
+  for _, problem := range problems {
+    fmt.Println(problem)
+  }
+  if len(problems) > 0 {
+    return fmt.Errorf("%d invalid queries", len(problems))
+  }
+  return nil
+}
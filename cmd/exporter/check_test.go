@@ -0,0 +1,111 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+// Registerer counting the descriptors of the collectors it registers
+type countingRegisterer struct {
+  prometheus.Registerer
+  descs int
+}
+
+func (r *countingRegisterer) Register(c prometheus.Collector) error {
+  ch := make(chan *prometheus.Desc)
+  go func() {
+    c.Describe(ch)
+    close(ch)
+  }()
+  for range ch {
+    r.descs++
+  }
+  return r.Registerer.Register(c)
+}
+
+func (r *countingRegisterer) MustRegister(cs ...prometheus.Collector) {
+  for _, c := range cs {
+    if err := r.Register(c); err != nil {
+      panic(err)
+    }
+  }
+}
+
+// The metric names known to the checks are the ones registered by the
+// exporter: every known name is registered, and every registered metric is known
+func TestExporterMetrics(t *testing.T) {
+  for _, mode := range []string{battleStatsModeGauge, battleStatsModeCounter, battleStatsModeBoth} {
+    reg := prometheus.NewPedanticRegistry()
+    counter := &countingRegisterer{Registerer: reg}
+    registerCollectors(counter, battleStatsGauges(mode), battleStatsCounters(mode), false)
+    names := exporterMetricNames(mode)
+    if len(names) != counter.descs {
+      t.Errorf("%s mode: %d known metric names, %d registered metrics", mode, len(names), counter.descs)
+    }
+    for name := range names {
+      // A registry rejects a second metric of the same name with another help
+      probe := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "probe"})
+      if err := reg.Register(probe); err == nil {
+        t.Errorf("%s mode: %s is not registered", mode, name)
+        reg.Unregister(probe)
+      }
+    }
+    if _, err := reg.Gather(); err != nil {
+      t.Errorf("%s mode: %v", mode, err)
+    }
+  }
+}
+
+// Dashboards and rules must only query metrics registered by the exporter
+func TestQueriesReferenceExporterMetrics(t *testing.T) {
+  problems, err := checkQueries(
+    filepath.Join("..", "..", "utils", "dashboards"),
+    filepath.Join("..", "..", "utils", "rules"),
+    battleStatsModeGauge,
+  )
+  if err != nil {
+    t.Fatal(err)
+  }
+  for _, problem := range problems {
+    t.Error(problem)
+  }
+}
+
+// Dashboards generated for a battle_stats_mode only query the metrics
+// exported in this mode, the committed gauge dashboards break in counter mode
+func TestDashboardsBattleStatsMode(t *testing.T) {
+  rules := filepath.Join("..", "..", "utils", "rules")
+  for _, mode := range []string{battleStatsModeGauge, battleStatsModeCounter, battleStatsModeBoth} {
+    t.Run(mode, func(t *testing.T) {
+      dir := t.TempDir()
+      for name, dashboard := range exporterDashboards(mode) {
+        content, err := renderDashboard(dashboard)
+        if err != nil {
+          t.Fatal(err)
+        }
+        err = os.WriteFile(filepath.Join(dir, name), content, 0o644)
+        if err != nil {
+          t.Fatal(err)
+        }
+      }
+      problems, err := checkQueries(dir, rules, mode)
+      if err != nil {
+        t.Fatal(err)
+      }
+      for _, problem := range problems {
+        t.Error(problem)
+      }
+    })
+  }
+
+  problems, err := checkQueries(filepath.Join("..", "..", "utils", "dashboards"), rules, battleStatsModeCounter)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(problems) == 0 {
+    t.Error("the gauge dashboards pass the check in counter mode")
+  }
+}
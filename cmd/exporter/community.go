@@ -0,0 +1,375 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "log/slog"
+  "net/http"
+  "strings"
+  "time"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// communitySource reads the war from the community API
+// (https://github.com/helldivers-2/api), which serves already decoded
+// data in its own JSON shape. Payloads are converted back into the
+// official API structures so they produce the same metrics.
+type communitySource struct {
+  url     string
+  client  string
+  contact string
+  http    client.HttpRequestDoer
+}
+
+// Statistics block of the community API, shared by the war and planets endpoints
+type communityStatistics struct {
+  MissionsWon        int64 `json:"missionsWon"`
+  MissionsLost       int64 `json:"missionsLost"`
+  MissionTime        int64 `json:"missionTime"`
+  TerminidKills      int64 `json:"terminidKills"`
+  AutomatonKills     int64 `json:"automatonKills"`
+  IlluminateKills    int64 `json:"illuminateKills"`
+  BulletsFired       int64 `json:"bulletsFired"`
+  BulletsHit         int64 `json:"bulletsHit"`
+  TimePlayed         int64 `json:"timePlayed"`
+  Deaths             int64 `json:"deaths"`
+  Revives            int64 `json:"revives"`
+  Friendlies         int64 `json:"friendlies"`
+  MissionSuccessRate int64 `json:"missionSuccessRate"`
+  Accuracy           int64 `json:"accuracy"`
+  PlayerCount        int32 `json:"playerCount"`
+}
+
+// Response of /api/v1/war
+type communityWar struct {
+  Started          time.Time           `json:"started"`
+  Ended            time.Time           `json:"ended"`
+  Now              time.Time           `json:"now"`
+  ClientVersion    string              `json:"clientVersion"`
+  ImpactMultiplier float32             `json:"impactMultiplier"`
+  Statistics       communityStatistics `json:"statistics"`
+}
+
+// Planet event (e.g. defense campaign) from /api/v1/planets
+type communityEvent struct {
+  Id                int32     `json:"id"`
+  EventType         int       `json:"eventType"`
+  Faction           string    `json:"faction"`
+  Health            int32     `json:"health"`
+  MaxHealth         int32     `json:"maxHealth"`
+  StartTime         time.Time `json:"startTime"`
+  EndTime           time.Time `json:"endTime"`
+  CampaignId        int32     `json:"campaignId"`
+  JointOperationIds []int32   `json:"jointOperationIds"`
+}
+
+// Item of /api/v1/assignments
+type communityAssignment struct {
+  Id          int64   `json:"id"`
+  Progress    []int32 `json:"progress"`
+  Title       string  `json:"title"`
+  Briefing    string  `json:"briefing"`
+  Description string  `json:"description"`
+  Tasks       []struct {
+    Type       int     `json:"type"`
+    Values     []int32 `json:"values"`
+    ValueTypes []int32 `json:"valueTypes"`
+  } `json:"tasks"`
+  Reward struct {
+    Type   int32 `json:"type"`
+    Amount int32 `json:"amount"`
+  } `json:"reward"`
+  Expiration time.Time `json:"expiration"`
+  Flags      int32     `json:"flags"`
+}
+
+// Item of /api/v1/planets
+type communityPlanet struct {
+  Index    int32  `json:"index"`
+  Name     string `json:"name"`
+  Sector   string `json:"sector"`
+  Hash     int64  `json:"hash"`
+  Position struct {
+    X float32 `json:"x"`
+    Y float32 `json:"y"`
+  } `json:"position"`
+  Waypoints      []int32             `json:"waypoints"`
+  MaxHealth      int32               `json:"maxHealth"`
+  Health         int32               `json:"health"`
+  Disabled       bool                `json:"disabled"`
+  InitialOwner   string              `json:"initialOwner"`
+  CurrentOwner   string              `json:"currentOwner"`
+  RegenPerSecond float32             `json:"regenPerSecond"`
+  Event          *communityEvent     `json:"event"`
+  Statistics     communityStatistics `json:"statistics"`
+  Attacking      []int32             `json:"attacking"`
+}
+
+func newCommunitySource(url string, clientName string, contact string, doer client.HttpRequestDoer) *communitySource {
+  return &communitySource{
+    url:     strings.TrimSuffix(url, "/"),
+    client:  clientName,
+    contact: contact,
+    http:    doer,
+  }
+}
+
+func (s *communitySource) Name() string {
+  return "community"
+}
+
+// Perform a GET request on the community API and decode the JSON response in `out`,
+// its raw body is kept in `responses`
+// Fills prometheus histograms for HTTP queries
+func (s *communitySource) get(ctx context.Context, route string, path string, out interface{}, responses map[string]json.RawMessage) error {
+  ctx, requestID := withRequestID(ctx)
+  req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+path, nil)
+  if err != nil {
+    return err
+  }
+  setRequestIDHeader(ctx, req)
+  // The community API asks its consumers to identify themselves
+  req.Header.Set("X-Super-Client", s.client)
+  if s.contact != "" {
+    req.Header.Set("X-Super-Contact", s.contact)
+  }
+  tStart := time.Now()
+  res, err := s.http.Do(req)
+  tEnd := time.Now()
+  observeAPIRequestDuration(route, tEnd.Sub(tStart).Seconds(), requestID)
+  if err != nil {
+    countAPIRequest(route, apiRequestErrorStatus)
+    scraper.recordRequest(route, requestID, tEnd.Sub(tStart), 0, err)
+    slog.Error("Error fetching community API", slog.String("route", route), slog.String("request_id", requestID), slog.Any("error", err))
+    return err
+  }
+  defer res.Body.Close()
+  slog.Info("Fetched community API", slog.String("route", route), slog.String("request_id", requestID), slog.Int("code", res.StatusCode), slog.Duration("duration", tEnd.Sub(tStart)))
+  countAPIRequest(route, fmt.Sprintf("%d", res.StatusCode))
+  scraper.recordRequest(route, requestID, tEnd.Sub(tStart), res.StatusCode, nil)
+  if res.StatusCode != 200 {
+    slog.Error("Error code while fetching community API", slog.String("route", route), slog.Int("code", res.StatusCode))
+    return fmt.Errorf("Error fetching %s", route)
+  }
+  body, err := io.ReadAll(res.Body)
+  if err != nil {
+    return err
+  }
+  err = json.Unmarshal(body, out)
+  if err != nil {
+    return err
+  }
+  responses[route] = body
+  return nil
+}
+
+// Fetch the war and planets from the community API, and convert them
+// to the official API structures
+func (s *communitySource) Fetch(ctx context.Context) (*WarSnapshot, error) {
+  responses := map[string]json.RawMessage{}
+  war := communityWar{}
+  if err := s.get(ctx, "community_war", "/api/v1/war", &war, responses); err != nil {
+    return nil, err
+  }
+  planets := []communityPlanet{}
+  if err := s.get(ctx, "community_planets", "/api/v1/planets", &planets, responses); err != nil {
+    return nil, err
+  }
+  snapshot := communitySnapshot(&war, planets)
+  snapshot.Responses = responses
+  // Assignments are optional, as with the official source
+  assignments := []communityAssignment{}
+  if err := s.get(ctx, "community_assignments", "/api/v1/assignments", &assignments, responses); err == nil {
+    snapshot.Assignments = communityAssignments(&war, assignments)
+  }
+  return snapshot, nil
+}
+
+// Convert community API payloads to a WarSnapshot.
+// Timestamps in the official API are seconds since the war started.
+func communitySnapshot(war *communityWar, planets []communityPlanet) *WarSnapshot {
+  warTime := func(t time.Time) int64 {
+    return int64(t.Sub(war.Started).Seconds())
+  }
+  status := &client.WarSeasonStatus{
+    WarId:                       801,
+    Time:                        warTime(war.Now),
+    ImpactMultiplier:            war.ImpactMultiplier,
+    JointOperations:             []client.JointOperation{},
+    PlanetEvents:                []client.PlanetEvent{},
+    SuperEarthWarResults:        []client.SuperEarthWarResult{},
+    GlobalEvents:                []client.GlobalEvent{},
+    ActiveElectionPolicyEffects: []client.ActiveElectionPolicyEffect{},
+    PlanetActiveEffects:         []client.PlanetActiveEffect{},
+    PlanetAttacks:               []client.PlanetAttack{},
+    CommunityTargets:            []client.CommunityTarget{},
+    PlanetStatus:                []client.PlanetStatus{},
+  }
+  info := &client.WarSeasonInfo{
+    WarId:                801,
+    StartDate:            war.Started.Unix(),
+    EndDate:              war.Ended.Unix(),
+    MinimumClientVersion: war.ClientVersion,
+    PlanetInfos:          []client.PlanetInfo{},
+  }
+  stats := &client.WarStatistics{
+    GalaxyStats: communityBattleStatistics(war.Statistics),
+  }
+  sectors := map[int32]string{}
+
+  for _, p := range planets {
+    status.PlanetStatus = append(status.PlanetStatus, client.PlanetStatus{
+      Index:          p.Index,
+      Owner:          communityFaction(p.CurrentOwner),
+      Health:         p.Health,
+      RegenPerSecond: p.RegenPerSecond,
+      Players:        p.Statistics.PlayerCount,
+    })
+    for _, target := range p.Attacking {
+      status.PlanetAttacks = append(status.PlanetAttacks, client.PlanetAttack{
+        Source:      p.Index,
+        Destination: target,
+      })
+    }
+    if p.Event != nil {
+      status.PlanetEvents = append(status.PlanetEvents, client.PlanetEvent{
+        Id:                p.Event.Id,
+        PlanetIndex:       p.Index,
+        EventType:         client.EventTypeEnum(p.Event.EventType),
+        Race:              communityFaction(p.Event.Faction),
+        Health:            p.Event.Health,
+        MaxHealth:         p.Event.MaxHealth,
+        StartTime:         warTime(p.Event.StartTime),
+        ExpireTime:        warTime(p.Event.EndTime),
+        CampaignId:        p.Event.CampaignId,
+        JointOperationIds: p.Event.JointOperationIds,
+      })
+    }
+
+    planetInfo := client.PlanetInfo{
+      Index:        p.Index,
+      SettingsHash: p.Hash,
+      Waypoints:    p.Waypoints,
+      MaxHealth:    p.MaxHealth,
+      Disabled:     p.Disabled,
+      InitialOwner: communityFaction(p.InitialOwner),
+      // The community API only exposes the sector name, kept in SectorNames
+      Sector: -1,
+    }
+    sectors[p.Index] = p.Sector
+    planetInfo.Position.X = p.Position.X
+    planetInfo.Position.Y = p.Position.Y
+    info.PlanetInfos = append(info.PlanetInfos, planetInfo)
+
+    planetStats := communityBattleStatistics(p.Statistics)
+    stats.PlanetsStats = append(stats.PlanetsStats, struct {
+      Accuracy           int64 `json:"accurracy"`
+      AutomatonKills     int64 `json:"automatonKills"`
+      BugKills           int64 `json:"bugKills"`
+      BulletsFired       int64 `json:"bulletsFired"`
+      BulletsHit         int64 `json:"bulletsHit"`
+      Deaths             int64 `json:"deaths"`
+      Friendlies         int64 `json:"friendlies"`
+      IlluminateKills    int64 `json:"illuminateKills"`
+      MissionSuccessRate int64 `json:"missionSuccessRate"`
+      MissionTime        int64 `json:"missionTime"`
+      MissionsLost       int64 `json:"missionsLost"`
+      MissionsWon        int64 `json:"missionsWon"`
+      PlanetIndex        int32 `json:"planetIndex"`
+      Revives            int64 `json:"revives"`
+      TimePlayed         int64 `json:"timePlayed"`
+    }{
+      Accuracy:           planetStats.Accuracy,
+      AutomatonKills:     planetStats.AutomatonKills,
+      BugKills:           planetStats.BugKills,
+      BulletsFired:       planetStats.BulletsFired,
+      BulletsHit:         planetStats.BulletsHit,
+      Deaths:             planetStats.Deaths,
+      Friendlies:         planetStats.Friendlies,
+      IlluminateKills:    planetStats.IlluminateKills,
+      MissionSuccessRate: planetStats.MissionSuccessRate,
+      MissionTime:        planetStats.MissionTime,
+      MissionsLost:       planetStats.MissionsLost,
+      MissionsWon:        planetStats.MissionsWon,
+      PlanetIndex:        p.Index,
+      Revives:            planetStats.Revives,
+      TimePlayed:         planetStats.TimePlayed,
+    })
+  }
+
+  return &WarSnapshot{
+    Status:      status,
+    Info:        info,
+    Stats:       stats,
+    SectorNames: sectors,
+  }
+}
+
+// Convert community API assignments to the official API structures
+func communityAssignments(war *communityWar, assignments []communityAssignment) []client.Assignment {
+  converted := []client.Assignment{}
+  for _, a := range assignments {
+    assignment := client.Assignment{
+      Id:       a.Id,
+      Progress: a.Progress,
+      ExpireIn: int64(a.Expiration.Sub(war.Now).Seconds()),
+    }
+    assignment.Setting.Type = client.MAJORORDER
+    assignment.Setting.OverrideTitle = a.Title
+    assignment.Setting.OverrideBrief = a.Briefing
+    assignment.Setting.TaskDescription = a.Description
+    assignment.Setting.Flags = a.Flags
+    assignment.Setting.Reward = client.AssignmentReward{
+      Type:   client.AssignmentRewardType(a.Reward.Type),
+      Amount: a.Reward.Amount,
+    }
+    assignment.Setting.Tasks = []client.AssignmentTask{}
+    for _, task := range a.Tasks {
+      assignment.Setting.Tasks = append(assignment.Setting.Tasks, client.AssignmentTask{
+        Type:        client.AssignmentTaskType(task.Type),
+        Values:      task.Values,
+        ValuesTypes: task.ValueTypes,
+      })
+    }
+    converted = append(converted, assignment)
+  }
+  return converted
+}
+
+func communityBattleStatistics(s communityStatistics) client.BattleStatistics {
+  return client.BattleStatistics{
+    MissionsWon:        s.MissionsWon,
+    MissionsLost:       s.MissionsLost,
+    MissionTime:        s.MissionTime,
+    BugKills:           s.TerminidKills,
+    AutomatonKills:     s.AutomatonKills,
+    IlluminateKills:    s.IlluminateKills,
+    BulletsFired:       s.BulletsFired,
+    BulletsHit:         s.BulletsHit,
+    TimePlayed:         s.TimePlayed,
+    Deaths:             s.Deaths,
+    Revives:            s.Revives,
+    Friendlies:         s.Friendlies,
+    MissionSuccessRate: s.MissionSuccessRate,
+    Accuracy:           s.Accuracy,
+  }
+}
+
+// The community API names factions instead of using the numeric identifiers
+func communityFaction(name string) client.FactionEnum {
+  switch name {
+  case "Humans":
+    return client.SUPEREARTH
+  case "Terminids":
+    return client.TERMINIDS
+  case "Automaton":
+    return client.AUTOMATONS
+  case "Illuminate":
+    return client.FactionEnum(4)
+  default:
+    return client.FactionEnum(0)
+  }
+}
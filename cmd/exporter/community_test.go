@@ -0,0 +1,150 @@
+package main
+
+import (
+  "encoding/json"
+  "testing"
+
+  "github.com/prometheus/client_golang/prometheus/testutil"
+
+  "github.com/Xide/helldivers2-dashboard/data"
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Responses of /api/v1/war and /api/v1/planets of the community API
+const (
+  communityWarPayload = `{
+  "started": "2024-02-08T10:00:00Z",
+  "ended": "2028-01-08T10:00:00Z",
+  "now": "2024-02-09T10:00:00Z",
+  "clientVersion": "0.3.0",
+  "impactMultiplier": 0.005,
+  "statistics": {"missionsWon": 120, "missionsLost": 30, "terminidKills": 5000, "deaths": 400, "playerCount": 3500}
+}`
+  communityPlanetsPayload = `[
+  {
+    "index": 0,
+    "name": "SUPER EARTH",
+    "sector": "Sol",
+    "hash": 1000000,
+    "position": {"x": 0, "y": 0},
+    "waypoints": [1],
+    "maxHealth": 1000000,
+    "health": 1000000,
+    "disabled": false,
+    "initialOwner": "Humans",
+    "currentOwner": "Humans",
+    "regenPerSecond": 0,
+    "event": null,
+    "statistics": {"missionsWon": 10, "deaths": 20, "playerCount": 500},
+    "attacking": []
+  },
+  {
+    "index": 127,
+    "name": "ANGEL'S VENTURE",
+    "sector": "Umlaut",
+    "hash": 1000127,
+    "position": {"x": 0.5, "y": -0.25},
+    "waypoints": [],
+    "maxHealth": 1000000,
+    "health": 1000000,
+    "disabled": false,
+    "initialOwner": "Humans",
+    "currentOwner": "Humans",
+    "regenPerSecond": 0,
+    "event": {
+      "id": 4121,
+      "eventType": 1,
+      "faction": "Automaton",
+      "health": 420000,
+      "maxHealth": 1200000,
+      "startTime": "2024-02-09T08:00:00Z",
+      "endTime": "2024-02-10T08:00:00Z",
+      "campaignId": 49021,
+      "jointOperationIds": [4121]
+    },
+    "statistics": {"missionsWon": 80, "automatonKills": 900, "deaths": 300, "playerCount": 2500},
+    "attacking": [0]
+  }
+]`
+)
+
+func TestCommunitySnapshot(t *testing.T) {
+  war := communityWar{}
+  if err := json.Unmarshal([]byte(communityWarPayload), &war); err != nil {
+    t.Fatal(err)
+  }
+  planets := []communityPlanet{}
+  if err := json.Unmarshal([]byte(communityPlanetsPayload), &planets); err != nil {
+    t.Fatal(err)
+  }
+  snapshot := communitySnapshot(&war, planets)
+
+  if snapshot.Status.Time != 86400 {
+    t.Errorf("war time: got %d, want 86400", snapshot.Status.Time)
+  }
+  if len(snapshot.Status.PlanetStatus) != 2 || snapshot.Status.PlanetStatus[1].Players != 2500 {
+    t.Errorf("planet status: got %+v", snapshot.Status.PlanetStatus)
+  }
+  if len(snapshot.Status.PlanetEvents) != 1 {
+    t.Fatalf("got %d planet events, want 1", len(snapshot.Status.PlanetEvents))
+  }
+  event := snapshot.Status.PlanetEvents[0]
+  if event.PlanetIndex != 127 || event.Race != client.AUTOMATONS || event.StartTime != 79200 || event.ExpireTime != 165600 {
+    t.Errorf("planet event: got %+v", event)
+  }
+  if attacks := snapshot.Status.PlanetAttacks; len(attacks) != 1 || attacks[0] != (client.PlanetAttack{Source: 127, Destination: 0}) {
+    t.Errorf("planet attacks: got %+v", attacks)
+  }
+  if stats := snapshot.Stats.PlanetsStats; len(stats) != 2 || stats[1].AutomatonKills != 900 || stats[1].PlanetIndex != 127 {
+    t.Errorf("planet statistics: got %+v", stats)
+  }
+
+  // The sector names of the community API are kept, whatever the sector table
+  static := newStaticData(map[int32]data.Planet{
+    0:   {Name: "Super Earth"},
+    127: {Name: "Angel's Venture", Sector: "Reference"},
+  }, map[int32]string{0: "Table"})
+  for _, planet := range snapshot.Info.PlanetInfos {
+    want := map[int32]string{0: "Sol", 127: "Umlaut"}[planet.Index]
+    if got := static.planetSector(snapshot, planet); got != want {
+      t.Errorf("sector of planet %d: got %q, want %q", planet.Index, got, want)
+    }
+  }
+  aggregate(snapshot, static)
+  if count := testutil.CollectAndCount(sectorPlayers); count != 2 {
+    t.Errorf("hde_sector_players: got %d series, want 2", count)
+  }
+  for sector, players := range map[string]float64{"Sol": 500, "Umlaut": 2500} {
+    if value := testutil.ToFloat64(sectorPlayers.WithLabelValues(sector)); value != players {
+      t.Errorf("hde_sector_players of %s: got %v, want %v", sector, value, players)
+    }
+  }
+}
+
+// Sources knowing the sector indexes resolve them with the sector table,
+// then with the planet reference data
+func TestPlanetSector(t *testing.T) {
+  static := newStaticData(map[int32]data.Planet{
+    1: {Name: "Klen Dahth II", Sector: "Altus"},
+    2: {Name: "Pathfinder V"},
+  }, map[int32]string{0: "Sol"})
+  snapshot := &WarSnapshot{}
+  tests := []struct {
+    planet client.PlanetInfo
+    want   string
+  }{
+    {client.PlanetInfo{Index: 0, Sector: 0}, "Sol"},
+    {client.PlanetInfo{Index: 1, Sector: 7}, "Altus"},
+    {client.PlanetInfo{Index: 2, Sector: 7}, "7"},
+  }
+  for _, test := range tests {
+    if got := static.planetSector(snapshot, test.planet); got != test.want {
+      t.Errorf("sector of planet %d: got %q, want %q", test.planet.Index, got, test.want)
+    }
+  }
+  // An empty name given by the source falls back to the sector table
+  snapshot.SectorNames = map[int32]string{2: ""}
+  if got := static.planetSector(snapshot, client.PlanetInfo{Index: 2, Sector: 0}); got != "Sol" {
+    t.Errorf("sector of planet 2 without a sector name: got %q, want %q", got, "Sol")
+  }
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "os"
+  "path/filepath"
+
+  "github.com/spf13/pflag"
+  "github.com/spf13/viper"
+)
+
+// Grafana dashboards of utils/dashboards, generated from the metric
+// definitions of the exporter so their queries follow metric changes
+
+// Uid of the Prometheus datasource provisioned by utils/prom-datasource.yml
+const dashboardDatasourceUID = "PBFA97CFB590B2093"
+
+type grafanaDatasource struct {
+  Type string `json:"type"`
+  UID  string `json:"uid"`
+}
+
+var prometheusDatasource = &grafanaDatasource{Type: "prometheus", UID: dashboardDatasourceUID}
+
+type grafanaDashboard struct {
+  Annotations   map[string]any    `json:"annotations"`
+  Editable      bool              `json:"editable"`
+  GraphTooltip  int               `json:"graphTooltip"`
+  ID            *int              `json:"id"`
+  Links         []any             `json:"links"`
+  Panels        []grafanaPanel    `json:"panels"`
+  Refresh       string            `json:"refresh"`
+  SchemaVersion int               `json:"schemaVersion"`
+  Tags          []string          `json:"tags"`
+  Templating    grafanaTemplating `json:"templating"`
+  Time          grafanaTimeRange  `json:"time"`
+  Timezone      string            `json:"timezone"`
+  Title         string            `json:"title"`
+  UID           string            `json:"uid"`
+  Version       int               `json:"version"`
+}
+
+type grafanaTimeRange struct {
+  From string `json:"from"`
+  To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+  List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+  Datasource  *grafanaDatasource `json:"datasource"`
+  Definition  string             `json:"definition"`
+  Description string             `json:"description"`
+  IncludeAll  bool               `json:"includeAll"`
+  Multi       bool               `json:"multi"`
+  Name        string             `json:"name"`
+  Query       map[string]string  `json:"query"`
+  Refresh     int                `json:"refresh"`
+  Sort        int                `json:"sort"`
+  Type        string             `json:"type"`
+}
+
+type grafanaGridPos struct {
+  H int `json:"h"`
+  W int `json:"w"`
+  X int `json:"x"`
+  Y int `json:"y"`
+}
+
+type grafanaPanel struct {
+  ID          int                 `json:"id"`
+  Type        string              `json:"type"`
+  Title       string              `json:"title"`
+  Description string              `json:"description,omitempty"`
+  Datasource  *grafanaDatasource  `json:"datasource,omitempty"`
+  GridPos     grafanaGridPos      `json:"gridPos"`
+  Interval    string              `json:"interval,omitempty"`
+  FieldConfig *grafanaFieldConfig `json:"fieldConfig,omitempty"`
+  Options     map[string]any      `json:"options,omitempty"`
+  Targets     []grafanaTarget     `json:"targets,omitempty"`
+  // Rows only
+  Collapsed *bool          `json:"collapsed,omitempty"`
+  Panels    []grafanaPanel `json:"panels,omitempty"`
+}
+
+type grafanaFieldConfig struct {
+  Defaults  grafanaFieldDefaults `json:"defaults"`
+  Overrides []grafanaOverride    `json:"overrides"`
+}
+
+type grafanaFieldDefaults struct {
+  Unit string `json:"unit,omitempty"`
+}
+
+type grafanaOverride struct {
+  Matcher    map[string]string `json:"matcher"`
+  Properties []grafanaProperty `json:"properties"`
+}
+
+type grafanaProperty struct {
+  ID    string `json:"id"`
+  Value any    `json:"value"`
+}
+
+type grafanaTarget struct {
+  Datasource   *grafanaDatasource `json:"datasource"`
+  EditorMode   string             `json:"editorMode"`
+  Expr         string             `json:"expr"`
+  Format       string             `json:"format,omitempty"`
+  Instant      bool               `json:"instant"`
+  LegendFormat string             `json:"legendFormat"`
+  Range        bool               `json:"range"`
+  RefID        string             `json:"refId"`
+}
+
+// A range query
+func query(expr string, legend string) grafanaTarget {
+  return grafanaTarget{
+    Datasource:   prometheusDatasource,
+    EditorMode:   "code",
+    Expr:         expr,
+    LegendFormat: legend,
+    Range:        true,
+  }
+}
+
+// An instant query, evaluated at the end of the time range
+func instantQuery(expr string, legend string) grafanaTarget {
+  t := query(expr, legend)
+  t.Range = false
+  t.Instant = true
+  return t
+}
+
+func newPanel(panelType string, title string, unit string, pos grafanaGridPos, targets ...grafanaTarget) grafanaPanel {
+  for i := range targets {
+    targets[i].RefID = string(rune('A' + i))
+  }
+  return grafanaPanel{
+    Type:        panelType,
+    Title:       title,
+    Datasource:  prometheusDatasource,
+    GridPos:     pos,
+    FieldConfig: &grafanaFieldConfig{Defaults: grafanaFieldDefaults{Unit: unit}, Overrides: []grafanaOverride{}},
+    Targets:     targets,
+  }
+}
+
+func timeseriesPanel(title string, unit string, pos grafanaGridPos, targets ...grafanaTarget) grafanaPanel {
+  p := newPanel("timeseries", title, unit, pos, targets...)
+  p.Options = map[string]any{
+    "legend":  map[string]any{"displayMode": "list", "placement": "bottom", "showLegend": true},
+    "tooltip": map[string]any{"mode": "single", "sort": "none"},
+  }
+  return p
+}
+
+func statPanel(title string, unit string, pos grafanaGridPos, targets ...grafanaTarget) grafanaPanel {
+  p := newPanel("stat", title, unit, pos, targets...)
+  p.Options = map[string]any{
+    "colorMode":   "value",
+    "graphMode":   "area",
+    "justifyMode": "auto",
+    "reduceOptions": map[string]any{
+      "calcs":  []string{"lastNotNull"},
+      "fields": "",
+      "values": false,
+    },
+    "textMode": "auto",
+  }
+  return p
+}
+
+func rowPanel(title string, y int, collapsed bool, panels ...grafanaPanel) grafanaPanel {
+  return grafanaPanel{
+    Type:      "row",
+    Title:     title,
+    GridPos:   grafanaGridPos{H: 1, W: 24, X: 0, Y: y},
+    Collapsed: &collapsed,
+    Panels:    panels,
+  }
+}
+
+func newDashboard(uid string, title string, from string, panels ...grafanaPanel) grafanaDashboard {
+  id := 0
+  var number func(panels []grafanaPanel)
+  number = func(panels []grafanaPanel) {
+    for i := range panels {
+      id++
+      panels[i].ID = id
+      number(panels[i].Panels)
+    }
+  }
+  number(panels)
+  return grafanaDashboard{
+    Annotations: map[string]any{"list": []any{map[string]any{
+      "builtIn":    1,
+      "datasource": map[string]string{"type": "grafana", "uid": "-- Grafana --"},
+      "enable":     true,
+      "hide":       true,
+      "iconColor":  "rgba(0, 211, 255, 1)",
+      "name":       "Annotations & Alerts",
+      "type":       "dashboard",
+    }}},
+    Editable:      true,
+    Links:         []any{},
+    Panels:        panels,
+    SchemaVersion: 38,
+    Tags:          []string{},
+    Templating:    grafanaTemplating{List: []grafanaVariable{}},
+    Time:          grafanaTimeRange{From: from, To: "now"},
+    Title:         title,
+    UID:           uid,
+    Version:       1,
+  }
+}
+
+func pos(x int, y int, w int, h int) grafanaGridPos {
+  return grafanaGridPos{H: h, W: w, X: x, Y: y}
+}
+
+func warRoomDashboard() grafanaDashboard {
+  players, health, maxHealth := planetPlayersName, planetHealthName, planetMaxHealthName
+
+  liberations := newPanel(
+    "grafana-polystat-panel", "Liberations", "", pos(12, 8, 12, 9),
+    instantQuery(fmt.Sprintf("abs(%s / (deriv(%s[1h]) < 0))", health, health), "{{planet}}"),
+  )
+  liberations.Options = map[string]any{
+    "autoSizeColumns":        true,
+    "autoSizePolygons":       true,
+    "autoSizeRows":           true,
+    "globalDecimals":         1,
+    "globalDisplayMode":      "all",
+    "globalFillColor":        "#343434",
+    "globalOperator":         "last",
+    "globalPolygonSize":      25,
+    "globalShape":            "hexagon_pointed_top",
+    "globalShowValueEnabled": true,
+    "globalTooltipsEnabled":  true,
+    "globalUnitFormat":       "s",
+    "layoutDisplayLimit":     100,
+    "layoutNumColumns":       8,
+    "layoutNumRows":          8,
+    "sortByDirection":        1,
+    "sortByField":            "name",
+  }
+
+  return newDashboard(
+    "zcOfzgbIz", "WarRoom", "now-24h",
+    timeseriesPanel(
+      "Planet repartition", "", pos(0, 0, 12, 8),
+      query(fmt.Sprintf("sum(%s) - sum(topk(5, %s))", players, players), "Other planets"),
+      query(fmt.Sprintf("topk(5, %s) > 0", players), "{{planet}}"),
+    ),
+    timeseriesPanel(
+      "Players", "", pos(12, 0, 12, 8),
+      query(fmt.Sprintf("sum(%s)", players), "Players"),
+    ),
+    timeseriesPanel(
+      "Planet health", "percent", pos(0, 8, 12, 9),
+      query(fmt.Sprintf("((%s / %s) * 100) < 99", health, maxHealth), "{{planet}}"),
+    ),
+    liberations,
+    timeseriesPanel(
+      "Players per front", "", pos(0, 17, 12, 8),
+      query(frontPlayersName, "{{faction}}"),
+    ),
+    timeseriesPanel(
+      "Defenses", "percentunit", pos(12, 17, 12, 8),
+      query(fmt.Sprintf(
+        "1 - (%s / %s)",
+        planetDefenseHealthName, planetDefenseMaxHealthName,
+      ), "{{planet}} ({{faction}})"),
+    ),
+  )
+}
+
+// Planet details, the cumulative battle statistics are queried as exported
+// in the battle_stats_mode: legacy gauges, or _total counters in counter mode
+func planetDetailsDashboard(battleStatsMode string) grafanaDashboard {
+  // Select the series of the planet chosen in the dashboard variable
+  planet := func(metric string) string {
+    return metric + `{planet="$planet_name"}`
+  }
+  battleStat := func(gauge string) string {
+    if battleStatsGauges(battleStatsMode) {
+      return gauge
+    }
+    return gauge + "_total"
+  }
+  players, health, maxHealth := planetPlayersName, planetHealthName, planetMaxHealthName
+  bulletsFired, bulletsHit := battleStat(planetBulletsFiredName), battleStat(planetBulletsHitName)
+
+  liberation := timeseriesPanel(
+    "Liberation", "", pos(0, 1, 12, 8),
+    query(planet(players), "Helldivers"),
+    query(fmt.Sprintf("1 - (%s / %s)", planet(health), planet(maxHealth)), "Liberation"),
+  )
+  liberation.Interval = "10m"
+  liberation.FieldConfig.Overrides = []grafanaOverride{
+    {
+      Matcher:    map[string]string{"id": "byName", "options": "Helldivers"},
+      Properties: []grafanaProperty{{ID: "custom.axisPlacement", Value: "right"}},
+    },
+    {
+      Matcher: map[string]string{"id": "byName", "options": "Liberation"},
+      Properties: []grafanaProperty{
+        {ID: "custom.drawStyle", Value: "bars"},
+        {ID: "unit", Value: "percentunit"},
+        {ID: "min", Value: 0},
+        {ID: "max", Value: 1},
+        {ID: "custom.fillOpacity", Value: 10},
+      },
+    },
+  }
+
+  perHour := newPanel(
+    "barchart", "Liberation per hour", "percentunit", pos(12, 1, 12, 8),
+    query(fmt.Sprintf(
+      "(1 - (%s / %s)) - (1 - (%s offset 1h / %s))",
+      planet(health), planet(maxHealth), planet(health), planet(maxHealth),
+    ), "Liberation"),
+  )
+  perHour.Interval = "1h"
+  perHour.Options = map[string]any{
+    "legend":             map[string]any{"displayMode": "list", "placement": "bottom", "showLegend": true},
+    "xTickLabelRotation": 0,
+  }
+
+  ratio := statPanel(
+    "Helldivers ratio", "percentunit", pos(0, 9, 4, 8),
+    query(fmt.Sprintf("sum(%s) / sum(%s)", planet(players), players), "Helldivers ratio"),
+  )
+  ratio.Description = "Percentage of total active helldivers currently on this planet"
+
+  prediction := fmt.Sprintf("abs(%s / (deriv(%s[1h]) < 0))", planet(health), planet(health))
+
+  d := newDashboard(
+    "-ZYuN7xIk", "Planet details", "now-24h",
+    rowPanel("General", 0, false),
+    liberation,
+    perHour,
+    ratio,
+    statPanel("Active helldivers", "none", pos(4, 9, 4, 8), query(planet(players), "Helldivers")),
+    statPanel("Current liberation prediction", "s", pos(8, 9, 4, 8), query(prediction, "Time until liberation")),
+    timeseriesPanel("Time until liberation predictions", "s", pos(12, 9, 12, 8), query(prediction, "Time until liberation")),
+    rowPanel(
+      "Misc statistics", 17, true,
+      statPanel("Missions won", "none", pos(0, 18, 4, 8), query(planet(battleStat(planetMissionsWonName)), "Missions won")),
+      statPanel("Missions lost", "none", pos(4, 18, 4, 8), query(planet(battleStat(planetMissionsLostName)), "Missions lost")),
+      statPanel("Success rate", "percent", pos(8, 18, 4, 8), query(planet(planetMissionSuccessRateName), "Success rate")),
+      statPanel("Bullet fired", "none", pos(0, 26, 4, 8), query(planet(bulletsFired), "Bullets fired")),
+      statPanel("Bullet hit", "none", pos(4, 26, 4, 8), query(planet(bulletsHit), "Bullets hit")),
+      statPanel("Accuracy", "percentunit", pos(8, 26, 4, 8), query(fmt.Sprintf("%s / %s", planet(bulletsHit), planet(bulletsFired)), "Accuracy")),
+    ),
+  )
+  d.Templating.List = []grafanaVariable{{
+    Datasource:  prometheusDatasource,
+    Definition:  fmt.Sprintf("label_values(%s, planet)", maxHealth),
+    Description: "Name of the planet",
+    Name:        "planet_name",
+    Query: map[string]string{
+      "query": fmt.Sprintf("label_values(%s, planet)", maxHealth),
+      "refId": "StandardVariableQuery",
+    },
+    Refresh: 1,
+    Type:    "query",
+  }}
+  return d
+}
+
+func exporterDashboard() grafanaDashboard {
+  requests := apiRequestsName
+
+  statusCodes := timeseriesPanel(
+    "API HTTP status codes", "cpm", pos(0, 12, 12, 8),
+    query(fmt.Sprintf("sum by (status) (increase(%s[1m]))", requests), "{{status}}"),
+  )
+  statusCodes.Description = "Status codes from the upstream HTTP api"
+
+  latency := newPanel(
+    "heatmap", "API latency", "", pos(0, 20, 12, 9),
+    query(fmt.Sprintf("sum by (le) (increase(%s_bucket[$__rate_interval]))", apiRequestDurationName), "{{le}}"),
+  )
+  latency.Targets[0].Format = "heatmap"
+  latency.Options = map[string]any{
+    "calculate": false,
+    "yAxis":     map[string]any{"axisPlacement": "left", "unit": "s"},
+  }
+
+  return newDashboard(
+    "tY1hCj1Iz", "Helldivers2 exporter", "now-15m",
+    statPanel(
+      "Quota errors", "cpm", pos(0, 0, 2, 4),
+      query(fmt.Sprintf(`sum(increase(%s{status="429"}[1m]))`, requests), "429 errors"),
+    ),
+    statPanel(
+      "Last successful scrape", "s", pos(2, 0, 3, 4),
+      query(fmt.Sprintf("time() - %s", lastScrapeSuccessName), "Seconds ago"),
+    ),
+    timeseriesPanel(
+      "API queries", "cpm", pos(0, 4, 12, 8),
+      query(fmt.Sprintf("sum(increase(%s[1m]))", requests), "Queries"),
+    ),
+    statusCodes,
+    latency,
+  )
+}
+
+// Generated dashboards, by file name, querying the metrics exported in
+// the battle_stats_mode
+func exporterDashboards(battleStatsMode string) map[string]grafanaDashboard {
+  return map[string]grafanaDashboard{
+    "war-room.json":       warRoomDashboard(),
+    "planet-details.json": planetDetailsDashboard(battleStatsMode),
+    "exporter.json":       exporterDashboard(),
+  }
+}
+
+// JSON of a dashboard, as committed in utils/dashboards
+func renderDashboard(d grafanaDashboard) ([]byte, error) {
+  content, err := json.MarshalIndent(d, "", "  ")
+  if err != nil {
+    return nil, err
+  }
+  return append(content, '\n'), nil
+}
+
+// `exporter dashboards` subcommand, writes the Grafana dashboards
+func runDashboards(args []string) error {
+  dashboardsFlags := pflag.NewFlagSet("dashboards", pflag.ExitOnError)
+  output := dashboardsFlags.StringP("output", "o", "utils/dashboards", "Directory of the dashboards")
+  battleStatsMode := dashboardsFlags.String("battle-stats-mode", viper.GetString("battle_stats_mode"), "battle_stats_mode of the exporter whose metrics are queried")
+  err := dashboardsFlags.Parse(args)
+  if err != nil {
+    return err
+  }
+  err = validateBattleStatsMode(*battleStatsMode)
+  if err != nil {
+    return err
+  }
+  for name, dashboard := range exporterDashboards(*battleStatsMode) {
+    content, err := renderDashboard(dashboard)
+    if err != nil {
+      return fmt.Errorf("failed to render %s: %w", name, err)
+    }
+    err = os.WriteFile(filepath.Join(*output, name), content, 0644)
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
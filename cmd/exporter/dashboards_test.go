@@ -0,0 +1,28 @@
+package main
+
+import (
+  "bytes"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+// The committed dashboards must match the generator in the default gauge
+// mode, regenerate them with `go run ./cmd/exporter dashboards`
+func TestDashboardsUpToDate(t *testing.T) {
+  for name, dashboard := range exporterDashboards(battleStatsModeGauge) {
+    t.Run(name, func(t *testing.T) {
+      expected, err := renderDashboard(dashboard)
+      if err != nil {
+        t.Fatal(err)
+      }
+      committed, err := os.ReadFile(filepath.Join("..", "..", "utils", "dashboards", name))
+      if err != nil {
+        t.Fatal(err)
+      }
+      if !bytes.Equal(committed, expected) {
+        t.Errorf("utils/dashboards/%s is out of date, run `go run ./cmd/exporter dashboards`", name)
+      }
+    })
+  }
+}
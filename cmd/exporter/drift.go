@@ -0,0 +1,24 @@
+package main
+
+import (
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/contract"
+)
+
+// Shapes of the fields of the official API missing from spec.yaml, kept in
+// memory until main loads the schema_drift_file
+var schemaDrift = mustNewSchemaDriftTracker("")
+
+func mustNewSchemaDriftTracker(path string) *contract.DriftTracker {
+  tracker, err := contract.NewDriftTracker(path)
+  if err != nil {
+    panic(err)
+  }
+  return tracker
+}
+
+// Build the schema drift tracker, persisted to schema_drift_file when set
+func newSchemaDriftTracker() (*contract.DriftTracker, error) {
+  return contract.NewDriftTracker(viper.GetString("schema_drift_file"))
+}
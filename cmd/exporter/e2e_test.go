@@ -0,0 +1,219 @@
+package main
+
+import (
+  "context"
+  "net/http/httptest"
+  "strings"
+  "testing"
+
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/client_golang/prometheus/promhttp"
+  "github.com/prometheus/client_golang/prometheus/testutil"
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+  "github.com/Xide/helldivers2-dashboard/pkg/mockapi"
+)
+
+// In-process mock API serving the embedded fixtures of war 801,
+// and the official source reading from it
+func startMockAPI(t *testing.T) (*mockapi.Server, *mockapi.Faults, Source) {
+  t.Helper()
+  api := mockapi.NewTestServer(t, 10)
+  cl, err := client.NewClientWithResponses(api.URL, client.WithRequestEditorFn(setRequestIDHeader))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return api.Server, api.Faults, &officialSource{client: cl, warID: 801}
+}
+
+// Registry of the exporter collectors, and the URL of its /metrics endpoint
+func startExporter(t *testing.T) string {
+  t.Helper()
+  viper.Set("json_data_dir", t.TempDir())
+  err := loadStaticAssets()
+  if err != nil {
+    t.Fatal(err)
+  }
+  reg := prometheus.NewRegistry()
+  registerCollectors(reg, true, true, false)
+  metrics := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+  t.Cleanup(metrics.Close)
+  return metrics.URL + "/metrics"
+}
+
+func TestScrapeMockAPI(t *testing.T) {
+  server, _, src := startMockAPI(t)
+  metrics := startExporter(t)
+  requests := testutil.ToFloat64(apiRequests.WithLabelValues("war_status", "200"))
+
+  err := scrape(src)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = testutil.ScrapeAndCompare(metrics, strings.NewReader(`
+# HELP hde_galaxy_missions_won Number of missions won in the galaxy
+# TYPE hde_galaxy_missions_won gauge
+hde_galaxy_missions_won 2.844421e+06
+# HELP hde_planet_defense_health Health of the ongoing defense campaign of the planet, the defense is won when it reaches 0
+# TYPE hde_planet_defense_health gauge
+hde_planet_defense_health{faction="automatons",planet="Angel's Venture"} 420000
+# HELP hde_planet_defense_max_health Max health of the ongoing defense campaign of the planet
+# TYPE hde_planet_defense_max_health gauge
+hde_planet_defense_max_health{faction="automatons",planet="Angel's Venture"} 1.2e+06
+`), "hde_galaxy_missions_won", "hde_planet_defense_health", "hde_planet_defense_max_health")
+  if err != nil {
+    t.Error(err)
+  }
+  if value := testutil.ToFloat64(planetHealth.WithLabelValues("Hydrofall Prime")); value != 620000 {
+    t.Errorf("hde_planet_health of Hydrofall Prime: got %v, want 620000", value)
+  }
+  if value := testutil.ToFloat64(planetPlayers.WithLabelValues("Hydrofall Prime")); value != 8400 {
+    t.Errorf("hde_planet_players of Hydrofall Prime: got %v, want 8400", value)
+  }
+  err = testutil.ScrapeAndCompare(metrics, strings.NewReader(`
+# HELP hde_global_event_planet_info Planet an active global event is about, always 1
+# TYPE hde_global_event_planet_info gauge
+hde_global_event_planet_info{event_id="1225",planet="Angel's Venture"} 1
+hde_global_event_planet_info{event_id="1225",planet="Atrama"} 1
+hde_global_event_planet_info{event_id="1226",planet="Angel's Venture"} 1
+# HELP hde_global_events Number of active global events
+# TYPE hde_global_events gauge
+hde_global_events 2
+`), "hde_global_event_planet_info", "hde_global_events")
+  if err != nil {
+    t.Error(err)
+  }
+  briefing := globalEventInfo.WithLabelValues("1225", "MAJOR ORDER", "super_earth", "0", "1296755127")
+  if value := testutil.ToFloat64(briefing); value != 1 {
+    t.Errorf("hde_global_event_info of the major order briefing: got %v, want 1", value)
+  }
+  if value := testutil.ToFloat64(apiRequests.WithLabelValues("war_status", "200")) - requests; value != 1 {
+    t.Errorf("hde_api_requests_total of war_status: got %v new requests, want 1", value)
+  }
+
+  // The defense is won, its briefing is withdrawn and Hydrofall Prime is under attack
+  server.Update(801, func(war *mockapi.War) {
+    war.Status.PlanetEvents = []client.PlanetEvent{}
+    war.Status.GlobalEvents = war.Status.GlobalEvents[:1]
+    for i, planet := range war.Status.PlanetStatus {
+      if planet.Index == 6 {
+        war.Status.PlanetStatus[i].Health = 250000
+      }
+    }
+  })
+  err = scrape(src)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = testutil.ScrapeAndCompare(metrics, strings.NewReader(""), "hde_planet_defense_health")
+  if err != nil {
+    t.Error(err)
+  }
+  if value := testutil.CollectAndCount(globalEventInfo); value != 1 {
+    t.Errorf("hde_global_event_info: got %d series, want 1", value)
+  }
+  if value := testutil.CollectAndCount(globalEventPlanet); value != 2 {
+    t.Errorf("hde_global_event_planet_info: got %d series, want 2", value)
+  }
+  if value := testutil.ToFloat64(planetHealth.WithLabelValues("Hydrofall Prime")); value != 250000 {
+    t.Errorf("hde_planet_health of Hydrofall Prime: got %v, want 250000", value)
+  }
+}
+
+func TestScrapeMockAPIFaults(t *testing.T) {
+  _, faults, src := startMockAPI(t)
+  startExporter(t)
+
+  tests := []struct {
+    name   string
+    faults mockapi.Profile
+    route  string
+    // Status counted for the route, none when the body can not be decoded
+    status string
+  }{
+    {"server error", mockapi.Profile{"war_status": {ErrorRate: 1}}, "war_status", "503"},
+    {"connection reset", mockapi.Profile{"war_stats": {ResetRate: 1}}, "war_stats", apiRequestErrorStatus},
+    {"rate limited", mockapi.Profile{"war_info": {RateLimitRate: 1}}, "war_info", "429"},
+    {"malformed body", mockapi.Profile{"war_stats": {MalformedRate: 1}}, "war_stats", ""},
+    {"truncated body", mockapi.Profile{"war_info": {TruncateRate: 1}}, "war_info", ""},
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      faults.Activate("none")
+      err := faults.Set(test.faults)
+      if err != nil {
+        t.Fatal(err)
+      }
+      requests := 0.0
+      if test.status != "" {
+        requests = testutil.ToFloat64(apiRequests.WithLabelValues(test.route, test.status))
+      }
+      lastSuccess := testutil.ToFloat64(lastScrapeSuccess)
+
+      err = scrape(src)
+      if err == nil {
+        t.Fatal("scrape succeeded, want an error")
+      }
+      if test.status != "" {
+        if value := testutil.ToFloat64(apiRequests.WithLabelValues(test.route, test.status)) - requests; value != 1 {
+          t.Errorf("hde_api_requests_total of %s: got %v new requests with status %s, want 1", test.route, value, test.status)
+        }
+      }
+      if value := testutil.ToFloat64(lastScrapeSuccess); value != lastSuccess {
+        t.Errorf("hde_last_scrape_success_timestamp_seconds changed after a failed scrape")
+      }
+    })
+  }
+}
+
+func TestScrapeMockAPIAssignmentsFaults(t *testing.T) {
+  _, faults, src := startMockAPI(t)
+  startExporter(t)
+
+  tests := []struct {
+    name   string
+    faults mockapi.Profile
+    // Status counted for the assignments, none when the body can not be decoded
+    status string
+  }{
+    {"server error", mockapi.Profile{"assignments": {ErrorRate: 1}}, "503"},
+    {"connection reset", mockapi.Profile{"assignments": {ResetRate: 1}}, apiRequestErrorStatus},
+    {"truncated body", mockapi.Profile{"assignments": {TruncateRate: 1}}, ""},
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      faults.Activate("none")
+      err := faults.Set(test.faults)
+      if err != nil {
+        t.Fatal(err)
+      }
+      requests := 0.0
+      if test.status != "" {
+        requests = testutil.ToFloat64(apiRequests.WithLabelValues("assignments", test.status))
+      }
+
+      // The war metrics do not depend on the assignments
+      snapshot, err := src.Fetch(context.Background())
+      if err != nil {
+        t.Fatalf("fetch failed: %v", err)
+      }
+      if snapshot.Assignments != nil {
+        t.Errorf("got assignments %+v, want none", snapshot.Assignments)
+      }
+      lastScrapeSuccess.Set(0)
+      err = scrape(src)
+      if err != nil {
+        t.Fatalf("scrape failed: %v", err)
+      }
+      if value := testutil.ToFloat64(lastScrapeSuccess); value == 0 {
+        t.Errorf("hde_last_scrape_success_timestamp_seconds not updated")
+      }
+      if test.status != "" {
+        if value := testutil.ToFloat64(apiRequests.WithLabelValues("assignments", test.status)) - requests; value != 2 {
+          t.Errorf("hde_api_requests_total of assignments: got %v new requests with status %s, want 2", value, test.status)
+        }
+      }
+    })
+  }
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+  "github.com/prometheus/client_golang/prometheus"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Names of the combat efficiency metrics
+const (
+  galaxyKillsPerDeathName            = "hde_galaxy_kills_per_death"
+  galaxyKillsPerMissionName          = "hde_galaxy_kills_per_mission"
+  galaxyDeathsPerMissionName         = "hde_galaxy_deaths_per_mission"
+  galaxyWinRatioName                 = "hde_galaxy_win_ratio"
+  galaxyComputedAccuracyName         = "hde_galaxy_computed_accuracy"
+  galaxyComputedAccuracyOverflowName = "hde_galaxy_computed_accuracy_overflow"
+  galaxyRevivesPerDeathName          = "hde_galaxy_revives_per_death"
+  planetKillsPerDeathName            = "hde_planet_kills_per_death"
+  planetKillsPerMissionName          = "hde_planet_kills_per_mission"
+  planetDeathsPerMissionName         = "hde_planet_deaths_per_mission"
+  planetWinRatioName                 = "hde_planet_win_ratio"
+  planetComputedAccuracyName         = "hde_planet_computed_accuracy"
+  planetComputedAccuracyOverflowName = "hde_planet_computed_accuracy_overflow"
+  planetRevivesPerDeathName          = "hde_planet_revives_per_death"
+)
+
+// Combat efficiency metrics derived from BattleStatistics, so dashboards
+// share a single definition of each ratio.
+// Ratios with a zero denominator are not exported. The galaxy ratios are
+// vectors without labels, so their series can be removed as the planet ones.
+var (
+  galaxyKillsPerDeath = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: galaxyKillsPerDeathName,
+    Help: "Kills (all factions) per death in the galaxy",
+  }, []string{})
+  galaxyKillsPerMission = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: galaxyKillsPerMissionName,
+    Help: "Kills of a faction per mission (won or lost) in the galaxy",
+  }, []string{"faction"})
+  galaxyDeathsPerMission = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: galaxyDeathsPerMissionName,
+    Help: "Deaths per mission (won or lost) in the galaxy",
+  }, []string{})
+  galaxyWinRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: galaxyWinRatioName,
+    Help: "Ratio of missions won over missions played in the galaxy, from 0 to 1",
+  }, []string{})
+  galaxyComputedAccuracy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: galaxyComputedAccuracyName,
+    Help: "Bullets hit over bullets fired in the galaxy. Upstream counts sometimes exceed 1",
+  }, []string{})
+  galaxyComputedAccuracyOverflow = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: galaxyComputedAccuracyOverflowName,
+    Help: "1 if more bullets hit than were fired in the galaxy, 0 otherwise",
+  }, []string{})
+  galaxyRevivesPerDeath = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: galaxyRevivesPerDeathName,
+    Help: "Revives per death in the galaxy",
+  }, []string{})
+
+  planetKillsPerDeath = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetKillsPerDeathName,
+    Help: "Kills (all factions) per death on the planet",
+  }, []string{"planet"})
+  planetKillsPerMission = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetKillsPerMissionName,
+    Help: "Kills of a faction per mission (won or lost) on the planet",
+  }, []string{"planet", "faction"})
+  planetDeathsPerMission = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetDeathsPerMissionName,
+    Help: "Deaths per mission (won or lost) on the planet",
+  }, []string{"planet"})
+  planetWinRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetWinRatioName,
+    Help: "Ratio of missions won over missions played on the planet, from 0 to 1",
+  }, []string{"planet"})
+  planetComputedAccuracy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetComputedAccuracyName,
+    Help: "Bullets hit over bullets fired on the planet. Upstream counts sometimes exceed 1",
+  }, []string{"planet"})
+  planetComputedAccuracyOverflow = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetComputedAccuracyOverflowName,
+    Help: "1 if more bullets hit than were fired on the planet, 0 otherwise",
+  }, []string{"planet"})
+  planetRevivesPerDeath = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetRevivesPerDeathName,
+    Help: "Revives per death on the planet",
+  }, []string{"planet"})
+)
+
+// A derived value, only valid when its denominator is not zero
+type ratio struct {
+  value float64
+  valid bool
+}
+
+func newRatio(numerator int64, denominator int64) ratio {
+  if denominator == 0 {
+    return ratio{}
+  }
+  return ratio{value: float64(numerator) / float64(denominator), valid: true}
+}
+
+// Combat efficiency of a set of BattleStatistics
+type efficiency struct {
+  killsPerDeath    ratio
+  killsPerMission  map[string]ratio
+  deathsPerMission ratio
+  winRatio         ratio
+  accuracy         ratio
+  revivesPerDeath  ratio
+}
+
+func computeEfficiency(stats client.BattleStatistics) efficiency {
+  missions := stats.MissionsWon + stats.MissionsLost
+  return efficiency{
+    killsPerDeath: newRatio(stats.BugKills+stats.AutomatonKills+stats.IlluminateKills, stats.Deaths),
+    killsPerMission: map[string]ratio{
+      factionName(client.TERMINIDS):  newRatio(stats.BugKills, missions),
+      factionName(client.AUTOMATONS): newRatio(stats.AutomatonKills, missions),
+      factionName(4):                 newRatio(stats.IlluminateKills, missions),
+    },
+    deathsPerMission: newRatio(stats.Deaths, missions),
+    winRatio:         newRatio(stats.MissionsWon, missions),
+    accuracy:         newRatio(stats.BulletsHit, stats.BulletsFired),
+    revivesPerDeath:  newRatio(stats.Revives, stats.Deaths),
+  }
+}
+
+// Set a gauge vector child from a ratio, invalid ratios delete it
+func setRatioVec(vec *prometheus.GaugeVec, r ratio, labels ...string) {
+  if r.valid {
+    vec.WithLabelValues(labels...).Set(r.value)
+  } else {
+    vec.DeleteLabelValues(labels...)
+  }
+}
+
+func boolToFloat(b bool) float64 {
+  if b {
+    return 1
+  }
+  return 0
+}
+
+// Export the efficiency metrics of the galaxy
+func exportGalaxyEfficiency(stats client.BattleStatistics) {
+  e := computeEfficiency(stats)
+  setRatioVec(galaxyKillsPerDeath, e.killsPerDeath)
+  for faction, r := range e.killsPerMission {
+    setRatioVec(galaxyKillsPerMission, r, faction)
+  }
+  setRatioVec(galaxyDeathsPerMission, e.deathsPerMission)
+  setRatioVec(galaxyWinRatio, e.winRatio)
+  setRatioVec(galaxyComputedAccuracy, e.accuracy)
+  if e.accuracy.valid {
+    galaxyComputedAccuracyOverflow.WithLabelValues().Set(boolToFloat(e.accuracy.value > 1))
+  } else {
+    galaxyComputedAccuracyOverflow.DeleteLabelValues()
+  }
+  setRatioVec(galaxyRevivesPerDeath, e.revivesPerDeath)
+}
+
+// Export the efficiency metrics of a planet
+func exportPlanetEfficiency(planet string, stats client.BattleStatistics) {
+  e := computeEfficiency(stats)
+  setRatioVec(planetKillsPerDeath, e.killsPerDeath, planet)
+  for faction, r := range e.killsPerMission {
+    setRatioVec(planetKillsPerMission, r, planet, faction)
+  }
+  setRatioVec(planetDeathsPerMission, e.deathsPerMission, planet)
+  setRatioVec(planetWinRatio, e.winRatio, planet)
+  setRatioVec(planetComputedAccuracy, e.accuracy, planet)
+  if e.accuracy.valid {
+    planetComputedAccuracyOverflow.WithLabelValues(planet).Set(boolToFloat(e.accuracy.value > 1))
+  } else {
+    planetComputedAccuracyOverflow.DeleteLabelValues(planet)
+  }
+  setRatioVec(planetRevivesPerDeath, e.revivesPerDeath, planet)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+  "strings"
+  "testing"
+
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/prometheus/client_golang/prometheus/testutil"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Ratios with a zero denominator are removed, in the galaxy as on the planets
+func TestEfficiencyZeroDenominator(t *testing.T) {
+  stats := client.BattleStatistics{
+    MissionsWon:  30,
+    MissionsLost: 10,
+    BugKills:     800,
+    Deaths:       40,
+    Revives:      10,
+    BulletsFired: 1000,
+    BulletsHit:   1200,
+  }
+  exportGalaxyEfficiency(stats)
+  exportPlanetEfficiency("Hellmire", stats)
+  err := testutil.CollectAndCompare(galaxyWinRatio, strings.NewReader(`
+# HELP hde_galaxy_win_ratio Ratio of missions won over missions played in the galaxy, from 0 to 1
+# TYPE hde_galaxy_win_ratio gauge
+hde_galaxy_win_ratio 0.75
+`))
+  if err != nil {
+    t.Error(err)
+  }
+  if value := testutil.ToFloat64(galaxyComputedAccuracyOverflow); value != 1 {
+    t.Errorf("hde_galaxy_computed_accuracy_overflow: got %v, want 1", value)
+  }
+  if value := testutil.ToFloat64(planetKillsPerDeath.WithLabelValues("Hellmire")); value != 20 {
+    t.Errorf("hde_planet_kills_per_death of Hellmire: got %v, want 20", value)
+  }
+
+  // A reset of the statistics, e.g. at the start of a war
+  exportGalaxyEfficiency(client.BattleStatistics{})
+  exportPlanetEfficiency("Hellmire", client.BattleStatistics{})
+  galaxy := map[string]*prometheus.GaugeVec{
+    "hde_galaxy_kills_per_death":            galaxyKillsPerDeath,
+    "hde_galaxy_kills_per_mission":          galaxyKillsPerMission,
+    "hde_galaxy_deaths_per_mission":         galaxyDeathsPerMission,
+    "hde_galaxy_win_ratio":                  galaxyWinRatio,
+    "hde_galaxy_computed_accuracy":          galaxyComputedAccuracy,
+    "hde_galaxy_computed_accuracy_overflow": galaxyComputedAccuracyOverflow,
+    "hde_galaxy_revives_per_death":          galaxyRevivesPerDeath,
+  }
+  for name, gauge := range galaxy {
+    if count := testutil.CollectAndCount(gauge); count != 0 {
+      t.Errorf("%s: got %d series, want none", name, count)
+    }
+  }
+  planet := map[string]*prometheus.GaugeVec{
+    "hde_planet_kills_per_death":            planetKillsPerDeath,
+    "hde_planet_kills_per_mission":          planetKillsPerMission,
+    "hde_planet_deaths_per_mission":         planetDeathsPerMission,
+    "hde_planet_win_ratio":                  planetWinRatio,
+    "hde_planet_computed_accuracy":          planetComputedAccuracy,
+    "hde_planet_computed_accuracy_overflow": planetComputedAccuracyOverflow,
+    "hde_planet_revives_per_death":          planetRevivesPerDeath,
+  }
+  for name, gauge := range planet {
+    if count := gauge.DeletePartialMatch(prometheus.Labels{"planet": "Hellmire"}); count != 0 {
+      t.Errorf("%s: got %d series of Hellmire, want none", name, count)
+    }
+  }
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "strconv"
+  "sync"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Types of war events
+const (
+  // A planet was captured by Super Earth
+  eventPlanetLiberated = "planet_liberated"
+  // A Super Earth planet was captured by an enemy faction
+  eventPlanetLost = "planet_lost"
+  // A planet changed hands between two enemy factions
+  eventPlanetOwnerChanged = "planet_owner_changed"
+  // An enemy faction started attacking a Super Earth planet
+  eventDefenseStarted = "defense_started"
+  // A defense campaign is over, won or lost
+  eventDefenseEnded = "defense_ended"
+  // Helldivers can now reach a planet from another one
+  eventSupplyLaneOpened = "supply_lane_opened"
+  // A planet can no longer be reached from another one
+  eventSupplyLaneClosed = "supply_lane_closed"
+  // A new major order was issued
+  eventMajorOrderIssued = "major_order_issued"
+  // A major order disappeared before its expiration
+  eventMajorOrderCompleted = "major_order_completed"
+  // A major order disappeared after its expiration
+  eventMajorOrderExpired = "major_order_expired"
+)
+
+var warEventTypes = []string{
+  eventPlanetLiberated,
+  eventPlanetLost,
+  eventPlanetOwnerChanged,
+  eventDefenseStarted,
+  eventDefenseEnded,
+  eventSupplyLaneOpened,
+  eventSupplyLaneClosed,
+  eventMajorOrderIssued,
+  eventMajorOrderCompleted,
+  eventMajorOrderExpired,
+}
+
+const warEventsTotalName = "hde_war_events_total"
+
+var warEventsTotal = func() *prometheus.CounterVec {
+  vec := prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: warEventsTotalName,
+    Help: "Number of war events detected between two scrapes, by type",
+  }, []string{"type"})
+  // Export every type from the start, so increase() sees the first event
+  for _, eventType := range warEventTypes {
+    vec.WithLabelValues(eventType)
+  }
+  return vec
+}()
+
+// WarEvent is a change of the war detected between two consecutive snapshots
+type WarEvent struct {
+  // Stable identifier of the event, the same change always gets the same ID
+  ID   string    `json:"id"`
+  Type string    `json:"type"`
+  Time time.Time `json:"time"`
+  // War time (seconds since the beginning of the war) of the snapshot
+  // the event was detected in
+  WarTime int64 `json:"war_time"`
+
+  PlanetIndex *int32 `json:"planet_index,omitempty"`
+  Planet      string `json:"planet,omitempty"`
+  // Owner of the planet, or attacking faction of a defense campaign
+  Faction         string `json:"faction,omitempty"`
+  PreviousFaction string `json:"previous_faction,omitempty"`
+  // Destination of a supply lane
+  TargetIndex *int32 `json:"target_index,omitempty"`
+  Target      string `json:"target,omitempty"`
+  // Planet event (defense campaign) identifier
+  CampaignID *int32 `json:"campaign_id,omitempty"`
+  // Major order identifier
+  AssignmentID *int64 `json:"assignment_id,omitempty"`
+  Title        string `json:"title,omitempty"`
+  Message      string `json:"message,omitempty"`
+}
+
+// Attributes of the event, for structured logs
+func (e WarEvent) logAttrs() []any {
+  attrs := []any{slog.String("type", e.Type), slog.String("id", e.ID), slog.Int64("war_time", e.WarTime)}
+  if e.PlanetIndex != nil {
+    attrs = append(attrs, slog.Int("planet_id", int(*e.PlanetIndex)), slog.String("planet", e.Planet))
+  }
+  if e.Faction != "" {
+    attrs = append(attrs, slog.String("faction", e.Faction))
+  }
+  if e.PreviousFaction != "" {
+    attrs = append(attrs, slog.String("previous_faction", e.PreviousFaction))
+  }
+  if e.TargetIndex != nil {
+    attrs = append(attrs, slog.Int("target_id", int(*e.TargetIndex)), slog.String("target", e.Target))
+  }
+  if e.CampaignID != nil {
+    attrs = append(attrs, slog.Int("campaign_id", int(*e.CampaignID)))
+  }
+  if e.AssignmentID != nil {
+    attrs = append(attrs, slog.Int64("assignment_id", *e.AssignmentID), slog.String("title", e.Title))
+  }
+  return attrs
+}
+
+// Detect the war events between two snapshots
+func diffSnapshots(previous *WarSnapshot, next *WarSnapshot, planetNames map[int32]string, now time.Time) []WarEvent {
+  events := []WarEvent{}
+  warTime := next.Status.Time
+  planetEvent := func(eventType string, index int32) WarEvent {
+    return WarEvent{
+      Type:        eventType,
+      Time:        now,
+      WarTime:     warTime,
+      PlanetIndex: &index,
+      Planet:      planetNames[index],
+    }
+  }
+
+  // Ownership changes
+  owners := map[int32]client.FactionEnum{}
+  for _, planet := range previous.Status.PlanetStatus {
+    owners[planet.Index] = planet.Owner
+  }
+  for _, planet := range next.Status.PlanetStatus {
+    before, ok := owners[planet.Index]
+    if !ok || before == planet.Owner {
+      continue
+    }
+    eventType := eventPlanetOwnerChanged
+    if planet.Owner == client.SUPEREARTH {
+      eventType = eventPlanetLiberated
+    } else if before == client.SUPEREARTH {
+      eventType = eventPlanetLost
+    }
+    event := planetEvent(eventType, planet.Index)
+    event.ID = fmt.Sprintf("%s:%d:%d", eventType, planet.Index, warTime)
+    event.Faction = factionName(planet.Owner)
+    event.PreviousFaction = factionName(before)
+    events = append(events, event)
+  }
+
+  // Defense campaigns
+  campaigns := map[int32]client.PlanetEvent{}
+  for _, e := range previous.Status.PlanetEvents {
+    campaigns[e.Id] = e
+  }
+  ongoing := map[int32]bool{}
+  for _, e := range next.Status.PlanetEvents {
+    ongoing[e.Id] = true
+    if _, ok := campaigns[e.Id]; ok {
+      continue
+    }
+    id := e.Id
+    event := planetEvent(eventDefenseStarted, e.PlanetIndex)
+    event.ID = fmt.Sprintf("%s:%d", eventDefenseStarted, e.Id)
+    event.Faction = factionName(e.Race)
+    event.CampaignID = &id
+    events = append(events, event)
+  }
+  for _, e := range previous.Status.PlanetEvents {
+    if ongoing[e.Id] {
+      continue
+    }
+    id := e.Id
+    event := planetEvent(eventDefenseEnded, e.PlanetIndex)
+    event.ID = fmt.Sprintf("%s:%d", eventDefenseEnded, e.Id)
+    event.Faction = factionName(e.Race)
+    event.CampaignID = &id
+    events = append(events, event)
+  }
+
+  // Supply lanes
+  lanes := map[client.PlanetAttack]bool{}
+  for _, lane := range previous.Status.PlanetAttacks {
+    lanes[lane] = true
+  }
+  nextLanes := map[client.PlanetAttack]bool{}
+  for _, lane := range next.Status.PlanetAttacks {
+    nextLanes[lane] = true
+  }
+  laneEvent := func(eventType string, lane client.PlanetAttack) WarEvent {
+    destination := lane.Destination
+    event := planetEvent(eventType, lane.Source)
+    event.ID = fmt.Sprintf("%s:%d:%d:%d", eventType, lane.Source, lane.Destination, warTime)
+    event.TargetIndex = &destination
+    event.Target = planetNames[destination]
+    return event
+  }
+  for _, lane := range next.Status.PlanetAttacks {
+    if !lanes[lane] {
+      events = append(events, laneEvent(eventSupplyLaneOpened, lane))
+    }
+  }
+  for _, lane := range previous.Status.PlanetAttacks {
+    if !nextLanes[lane] {
+      events = append(events, laneEvent(eventSupplyLaneClosed, lane))
+    }
+  }
+
+  // Major orders, unknown when the assignments of either snapshot could not be fetched
+  if previous.Assignments == nil || next.Assignments == nil {
+    return events
+  }
+  assignments := map[int64]client.Assignment{}
+  for _, a := range previous.Assignments {
+    assignments[a.Id] = a
+  }
+  active := map[int64]bool{}
+  assignmentEvent := func(eventType string, a client.Assignment) WarEvent {
+    id := a.Id
+    return WarEvent{
+      ID:           fmt.Sprintf("%s:%d", eventType, a.Id),
+      Type:         eventType,
+      Time:         now,
+      WarTime:      warTime,
+      AssignmentID: &id,
+      Title:        a.Setting.OverrideTitle,
+      Message:      a.Setting.OverrideBrief,
+    }
+  }
+  for _, a := range next.Assignments {
+    active[a.Id] = true
+    if _, ok := assignments[a.Id]; !ok {
+      events = append(events, assignmentEvent(eventMajorOrderIssued, a))
+    }
+  }
+  elapsed := next.Status.Time - previous.Status.Time
+  for _, a := range previous.Assignments {
+    if active[a.Id] {
+      continue
+    }
+    // The API does not tell whether an order succeeded, an order removed
+    // before its expiration is considered completed
+    if a.ExpireIn > elapsed {
+      events = append(events, assignmentEvent(eventMajorOrderCompleted, a))
+    } else {
+      events = append(events, assignmentEvent(eventMajorOrderExpired, a))
+    }
+  }
+  return events
+}
+
+// warEventLog detects war events between consecutive snapshots,
+// and keeps the most recent ones in memory
+type warEventLog struct {
+  mu          sync.Mutex
+  previous    *WarSnapshot
+  events      []WarEvent
+  size        int
+  subscribers []chan WarEvent
+}
+
+// Events queued for a subscriber still handling the previous ones, on top
+// of which new events are dropped
+const subscriberQueueSize = 100
+
+func validateEventsHistory(size int) error {
+  if size < 0 {
+    return fmt.Errorf("invalid events_history %d, expected a positive number of events", size)
+  }
+  return nil
+}
+
+func newWarEventLog(size int) *warEventLog {
+  return &warEventLog{size: size}
+}
+
+var warEvents = newWarEventLog(100)
+
+// Register a function called for every detected event.
+// Each subscriber is called from its own goroutine, with up to
+// subscriberQueueSize queued events, so a slow subscriber never delays the scrapes.
+func (l *warEventLog) subscribe(fn func(WarEvent)) {
+  queue := make(chan WarEvent, subscriberQueueSize)
+  go func() {
+    for event := range queue {
+      fn(event)
+    }
+  }()
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  l.subscribers = append(l.subscribers, queue)
+}
+
+// Compare a snapshot with the previous one, record and publish the events
+func (l *warEventLog) observe(snapshot *WarSnapshot, planetNames map[int32]string) []WarEvent {
+  l.mu.Lock()
+  previous := l.previous
+  l.previous = snapshot
+  if snapshot.Assignments == nil && previous != nil {
+    // Compare the next assignments with the last known ones
+    kept := *snapshot
+    kept.Assignments = previous.Assignments
+    l.previous = &kept
+  }
+  if previous == nil {
+    l.mu.Unlock()
+    return nil
+  }
+  events := diffSnapshots(previous, snapshot, planetNames, time.Now())
+  l.events = append(l.events, events...)
+  if len(l.events) > l.size {
+    l.events = l.events[len(l.events)-l.size:]
+  }
+  subscribers := l.subscribers
+  l.mu.Unlock()
+
+  for _, event := range events {
+    warEventsTotal.WithLabelValues(event.Type).Inc()
+    slog.Info("War event", event.logAttrs()...)
+    for _, queue := range subscribers {
+      select {
+      case queue <- event:
+      default:
+        slog.Warn("War event subscriber too slow, dropping event", slog.String("id", event.ID))
+      }
+    }
+  }
+  return events
+}
+
+// Most recent events, newest first
+func (l *warEventLog) recent() []WarEvent {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  events := make([]WarEvent, 0, len(l.events))
+  for i := len(l.events) - 1; i >= 0; i-- {
+    events = append(events, l.events[i])
+  }
+  return events
+}
+
+// Serve the recent events as JSON, newest first.
+// Accepts optional `type` and `limit` query parameters.
+func (l *warEventLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  events := l.recent()
+  if eventType := r.URL.Query().Get("type"); eventType != "" {
+    filtered := []WarEvent{}
+    for _, event := range events {
+      if event.Type == eventType {
+        filtered = append(filtered, event)
+      }
+    }
+    events = filtered
+  }
+  if limit := r.URL.Query().Get("limit"); limit != "" {
+    n, err := strconv.Atoi(limit)
+    if err != nil || n < 0 {
+      http.Error(w, "invalid limit", http.StatusBadRequest)
+      return
+    }
+    if n < len(events) {
+      events = events[:n]
+    }
+  }
+  w.Header().Set("Content-Type", "application/json")
+  err := json.NewEncoder(w).Encode(events)
+  if err != nil {
+    slog.Error("Failed to encode war events", slog.Any("error", err))
+  }
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+  "reflect"
+  "testing"
+  "time"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+func ptr[T any](v T) *T {
+  return &v
+}
+
+// Snapshot of the war at `warTime`
+func eventsSnapshot(warTime int64, planets map[int32]client.FactionEnum, campaigns []client.PlanetEvent, lanes []client.PlanetAttack, assignments []client.Assignment) *WarSnapshot {
+  status := &client.WarSeasonStatus{Time: warTime}
+  for index := int32(0); index < 10; index++ {
+    if owner, ok := planets[index]; ok {
+      status.PlanetStatus = append(status.PlanetStatus, client.PlanetStatus{Index: index, Owner: owner})
+    }
+  }
+  status.PlanetEvents = campaigns
+  status.PlanetAttacks = lanes
+  return &WarSnapshot{Status: status, Assignments: assignments}
+}
+
+func majorOrder(id int64, expireIn int64) client.Assignment {
+  a := client.Assignment{Id: id, ExpireIn: expireIn}
+  a.Setting.OverrideTitle = "MAJOR ORDER"
+  a.Setting.OverrideBrief = "Liberate the planets"
+  return a
+}
+
+func TestDiffSnapshots(t *testing.T) {
+  now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+  names := map[int32]string{1: "Malevelon Creek", 2: "Draupnir", 3: "Estanu"}
+  planets := map[int32]client.FactionEnum{1: client.SUPEREARTH, 2: client.TERMINIDS, 3: client.AUTOMATONS}
+  with := func(index int32, owner client.FactionEnum) map[int32]client.FactionEnum {
+    changed := map[int32]client.FactionEnum{}
+    for i, o := range planets {
+      changed[i] = o
+    }
+    changed[index] = owner
+    return changed
+  }
+  campaign := client.PlanetEvent{Id: 7, PlanetIndex: 1, Race: client.AUTOMATONS}
+  lane := client.PlanetAttack{Source: 1, Destination: 2}
+  orders := []client.Assignment{majorOrder(10, 3600), majorOrder(11, 60)}
+  event := func(eventType string, id string, planet int32) WarEvent {
+    return WarEvent{ID: id, Type: eventType, Time: now, WarTime: 1120, PlanetIndex: ptr(planet), Planet: names[planet]}
+  }
+  orderEvent := func(eventType string, id string, assignment int64) WarEvent {
+    return WarEvent{ID: id, Type: eventType, Time: now, WarTime: 1120, AssignmentID: ptr(assignment),
+      Title: "MAJOR ORDER", Message: "Liberate the planets"}
+  }
+
+  tests := []struct {
+    name     string
+    previous *WarSnapshot
+    next     *WarSnapshot
+    want     []WarEvent
+  }{
+    {
+      name:     "no change",
+      previous: eventsSnapshot(1000, planets, []client.PlanetEvent{campaign}, []client.PlanetAttack{lane}, orders),
+      next:     eventsSnapshot(1120, planets, []client.PlanetEvent{campaign}, []client.PlanetAttack{lane}, orders),
+      want:     []WarEvent{},
+    },
+    {
+      name:     "planet liberated",
+      previous: eventsSnapshot(1000, planets, nil, nil, nil),
+      next:     eventsSnapshot(1120, with(2, client.SUPEREARTH), nil, nil, nil),
+      want: []WarEvent{func() WarEvent {
+        e := event(eventPlanetLiberated, "planet_liberated:2:1120", 2)
+        e.Faction, e.PreviousFaction = "super_earth", "terminids"
+        return e
+      }()},
+    },
+    {
+      name:     "planet lost",
+      previous: eventsSnapshot(1000, planets, nil, nil, nil),
+      next:     eventsSnapshot(1120, with(1, client.AUTOMATONS), nil, nil, nil),
+      want: []WarEvent{func() WarEvent {
+        e := event(eventPlanetLost, "planet_lost:1:1120", 1)
+        e.Faction, e.PreviousFaction = "automatons", "super_earth"
+        return e
+      }()},
+    },
+    {
+      name:     "owner changed between enemies",
+      previous: eventsSnapshot(1000, planets, nil, nil, nil),
+      next:     eventsSnapshot(1120, with(3, client.TERMINIDS), nil, nil, nil),
+      want: []WarEvent{func() WarEvent {
+        e := event(eventPlanetOwnerChanged, "planet_owner_changed:3:1120", 3)
+        e.Faction, e.PreviousFaction = "terminids", "automatons"
+        return e
+      }()},
+    },
+    {
+      name:     "new planet",
+      previous: eventsSnapshot(1000, map[int32]client.FactionEnum{1: client.SUPEREARTH}, nil, nil, nil),
+      next:     eventsSnapshot(1120, planets, nil, nil, nil),
+      want:     []WarEvent{},
+    },
+    {
+      name:     "defense started",
+      previous: eventsSnapshot(1000, planets, nil, nil, nil),
+      next:     eventsSnapshot(1120, planets, []client.PlanetEvent{campaign}, nil, nil),
+      want: []WarEvent{func() WarEvent {
+        e := event(eventDefenseStarted, "defense_started:7", 1)
+        e.Faction, e.CampaignID = "automatons", ptr(int32(7))
+        return e
+      }()},
+    },
+    {
+      name:     "defense ended",
+      previous: eventsSnapshot(1000, planets, []client.PlanetEvent{campaign}, nil, nil),
+      next:     eventsSnapshot(1120, planets, nil, nil, nil),
+      want: []WarEvent{func() WarEvent {
+        e := event(eventDefenseEnded, "defense_ended:7", 1)
+        e.Faction, e.CampaignID = "automatons", ptr(int32(7))
+        return e
+      }()},
+    },
+    {
+      name:     "supply lane opened",
+      previous: eventsSnapshot(1000, planets, nil, nil, nil),
+      next:     eventsSnapshot(1120, planets, nil, []client.PlanetAttack{lane}, nil),
+      want: []WarEvent{func() WarEvent {
+        e := event(eventSupplyLaneOpened, "supply_lane_opened:1:2:1120", 1)
+        e.TargetIndex, e.Target = ptr(int32(2)), "Draupnir"
+        return e
+      }()},
+    },
+    {
+      name:     "supply lane closed",
+      previous: eventsSnapshot(1000, planets, nil, []client.PlanetAttack{lane}, nil),
+      next:     eventsSnapshot(1120, planets, nil, nil, nil),
+      want: []WarEvent{func() WarEvent {
+        e := event(eventSupplyLaneClosed, "supply_lane_closed:1:2:1120", 1)
+        e.TargetIndex, e.Target = ptr(int32(2)), "Draupnir"
+        return e
+      }()},
+    },
+    {
+      name:     "major order issued",
+      previous: eventsSnapshot(1000, planets, nil, nil, orders[:1]),
+      next:     eventsSnapshot(1120, planets, nil, nil, orders),
+      want:     []WarEvent{orderEvent(eventMajorOrderIssued, "major_order_issued:11", 11)},
+    },
+    {
+      // Order 10 disappeared before its expiration, 11 after
+      name:     "major orders completed and expired",
+      previous: eventsSnapshot(1000, planets, nil, nil, orders),
+      next:     eventsSnapshot(1120, planets, nil, nil, []client.Assignment{}),
+      want: []WarEvent{
+        orderEvent(eventMajorOrderCompleted, "major_order_completed:10", 10),
+        orderEvent(eventMajorOrderExpired, "major_order_expired:11", 11),
+      },
+    },
+    {
+      name:     "previous assignments unknown",
+      previous: eventsSnapshot(1000, planets, nil, nil, nil),
+      next:     eventsSnapshot(1120, planets, nil, nil, orders),
+      want:     []WarEvent{},
+    },
+    {
+      name:     "next assignments unknown",
+      previous: eventsSnapshot(1000, planets, nil, nil, orders),
+      next:     eventsSnapshot(1120, planets, nil, nil, nil),
+      want:     []WarEvent{},
+    },
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      got := diffSnapshots(test.previous, test.next, names, now)
+      if !reflect.DeepEqual(got, test.want) {
+        t.Errorf("got %+v, want %+v", got, test.want)
+      }
+    })
+  }
+}
+
+func TestWarEventLogSlowSubscriber(t *testing.T) {
+  log := newWarEventLog(10)
+  release := make(chan struct{})
+  received := make(chan WarEvent, 10)
+  log.subscribe(func(e WarEvent) {
+    <-release
+    received <- e
+  })
+
+  planets := map[int32]client.FactionEnum{1: client.SUPEREARTH}
+  log.observe(eventsSnapshot(1000, planets, nil, nil, nil), nil)
+  done := make(chan []WarEvent)
+  go func() {
+    done <- log.observe(eventsSnapshot(1120, map[int32]client.FactionEnum{1: client.AUTOMATONS}, nil, nil, nil), nil)
+  }()
+  select {
+  case events := <-done:
+    if len(events) != 1 {
+      t.Fatalf("got events %+v, want a planet lost", events)
+    }
+  case <-time.After(time.Second):
+    t.Fatal("a slow subscriber blocked the observation of a snapshot")
+  }
+
+  close(release)
+  select {
+  case e := <-received:
+    if e.Type != eventPlanetLost {
+      t.Errorf("subscriber got %+v, want a planet lost", e)
+    }
+  case <-time.After(time.Second):
+    t.Fatal("the subscriber did not get the event")
+  }
+}
+
+// Subscribers keep their queue without any history served by /events
+func TestWarEventLogWithoutHistory(t *testing.T) {
+  if err := validateEventsHistory(-1); err == nil {
+    t.Error("a negative events_history must be rejected")
+  }
+  if err := validateEventsHistory(0); err != nil {
+    t.Error(err)
+  }
+  log := newWarEventLog(0)
+  release := make(chan struct{})
+  received := make(chan WarEvent, 10)
+  log.subscribe(func(e WarEvent) {
+    <-release
+    received <- e
+  })
+
+  log.observe(eventsSnapshot(1000, map[int32]client.FactionEnum{1: client.SUPEREARTH, 2: client.SUPEREARTH, 3: client.TERMINIDS}, nil, nil, nil), nil)
+  events := log.observe(eventsSnapshot(1120, map[int32]client.FactionEnum{1: client.AUTOMATONS, 2: client.TERMINIDS, 3: client.SUPEREARTH}, nil, nil, nil), nil)
+  if len(events) != 3 {
+    t.Fatalf("got events %+v, want 3", events)
+  }
+  if recent := log.recent(); len(recent) != 0 {
+    t.Errorf("got %d events in the history, want none", len(recent))
+  }
+  close(release)
+  for i := range events {
+    select {
+    case e := <-received:
+      if e.ID != events[i].ID {
+        t.Errorf("event %d: got %s, want %s", i, e.ID, events[i].ID)
+      }
+    case <-time.After(time.Second):
+      t.Fatalf("the subscriber got %d events, want %d", i, len(events))
+    }
+  }
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+  "log/slog"
+  "strconv"
+
+  "github.com/prometheus/client_golang/prometheus"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Names of the global event metrics
+const (
+  globalEventInfoName   = "hde_global_event_info"
+  globalEventPlanetName = "hde_global_event_planet_info"
+  globalEventsName      = "hde_global_events"
+)
+
+// Active global events of the war status: major order briefings and story
+// events shown in game. Only their title is exported as a label, their
+// messages are long and change with every revision, sync keeps them in
+// its global_events table.
+var (
+  globalEventInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: globalEventInfoName,
+    Help: "Active global event (major order briefing, story event), always 1",
+  }, []string{"event_id", "title", "faction", "flag", "assignment_id"})
+  globalEventPlanet = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: globalEventPlanetName,
+    Help: "Planet an active global event is about, always 1",
+  }, []string{"event_id", "planet"})
+  globalEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+    Name: globalEventsName,
+    Help: "Number of active global events",
+  })
+)
+
+// Export the global events of the war status, replacing the previous ones
+func exportGlobalEvents(events []client.GlobalEvent, planetNames map[int32]string) {
+  // Events come and go, start from a clean slate
+  globalEventInfo.Reset()
+  globalEventPlanet.Reset()
+  for _, event := range events {
+    eventID := strconv.Itoa(int(event.EventId))
+    assignmentID := ""
+    if event.AssignmentId32 != nil && *event.AssignmentId32 != 0 {
+      assignmentID = strconv.FormatInt(*event.AssignmentId32, 10)
+    }
+    globalEventInfo.WithLabelValues(
+      eventID,
+      event.Title,
+      factionName(event.Race),
+      strconv.Itoa(int(event.Flag)),
+      assignmentID,
+    ).Set(1)
+    for _, index := range event.PlanetIndices {
+      planetName, ok := planetNames[index]
+      if !ok {
+        slog.Warn("Unknown planet", slog.Int("planet_id", int(index)))
+        continue
+      }
+      globalEventPlanet.WithLabelValues(eventID, planetName).Set(1)
+    }
+  }
+  globalEvents.Set(float64(len(events)))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+  "context"
+  "fmt"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/google/uuid"
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/spf13/viper"
+)
+
+// Buckets of the API latency histogram, tuned for an upstream API
+// that usually answers in a few hundred milliseconds, and is cut at 5s
+const defaultAPILatencyBuckets = "0.05,0.1,0.25,0.5,0.75,1,1.5,2,3,5"
+
+// Name of the API latency histogram, queried by the generated dashboards
+const apiRequestDurationName = "hde_api_request_duration_seconds"
+
+// Build the API latency histogram.
+// When native is set, the histogram is also exposed as a native histogram,
+// for Prometheus servers scraping with the protobuf format.
+func newAPIRequestDuration(buckets []float64, native bool) *prometheus.HistogramVec {
+  opts := prometheus.HistogramOpts{
+    Name:    apiRequestDurationName,
+    Help:    "Duration of the api request",
+    Buckets: buckets,
+  }
+  if native {
+    opts.NativeHistogramBucketFactor = 1.1
+    opts.NativeHistogramMaxBucketNumber = 100
+    opts.NativeHistogramMinResetDuration = time.Hour
+  }
+  return prometheus.NewHistogramVec(opts, []string{"route"})
+}
+
+func parseBuckets(value string) ([]float64, error) {
+  buckets := []float64{}
+  for _, field := range strings.Split(value, ",") {
+    field = strings.TrimSpace(field)
+    if field == "" {
+      continue
+    }
+    bucket, err := strconv.ParseFloat(field, 64)
+    if err != nil {
+      return nil, fmt.Errorf("invalid bucket %q: %w", field, err)
+    }
+    if len(buckets) > 0 && bucket <= buckets[len(buckets)-1] {
+      return nil, fmt.Errorf("buckets must be in increasing order, got %v after %v", bucket, buckets[len(buckets)-1])
+    }
+    buckets = append(buckets, bucket)
+  }
+  if len(buckets) == 0 {
+    return nil, fmt.Errorf("no bucket in %q", value)
+  }
+  return buckets, nil
+}
+
+func mustParseBuckets(value string) []float64 {
+  buckets, err := parseBuckets(value)
+  if err != nil {
+    panic(err)
+  }
+  return buckets
+}
+
+// Replace the API latency histogram with one built from the
+// api_latency_buckets and api_latency_native_histogram keys.
+// Must be called before the collectors are registered.
+func configureAPIRequestDuration() error {
+  buckets, err := parseBuckets(viper.GetString("api_latency_buckets"))
+  if err != nil {
+    return fmt.Errorf("invalid api_latency_buckets: %w", err)
+  }
+  apiRequestDuration = newAPIRequestDuration(buckets, viper.GetBool("api_latency_native_histogram"))
+  return nil
+}
+
+// Header carrying the correlation ID of the upstream requests
+const requestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// Attach a new correlation ID to the context of an upstream request.
+// It is sent to the upstream API, logged, and attached as an exemplar to
+// the latency histogram, so a slow bucket can be traced to the exact call.
+func withRequestID(ctx context.Context) (context.Context, string) {
+  id := uuid.NewString()
+  return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+func requestIDFromContext(ctx context.Context) string {
+  id, _ := ctx.Value(requestIDKey{}).(string)
+  return id
+}
+
+// Set the correlation ID header of a request, from its context
+func setRequestIDHeader(ctx context.Context, req *http.Request) error {
+  if id := requestIDFromContext(ctx); id != "" {
+    req.Header.Set(requestIDHeader, id)
+  }
+  return nil
+}
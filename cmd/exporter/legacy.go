@@ -0,0 +1,60 @@
+package main
+
+import (
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics renamed to follow the Prometheus naming conventions, still
+// exported under their previous name while legacy_metric_names is set.
+// They are deprecated and will be removed in a future release.
+var (
+  // Replaced by hde_api_request_duration_seconds
+  legacyAPIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+    Name: "hde_api_request_duration",
+    Help: "Duration of the api request. Deprecated, use hde_api_request_duration_seconds",
+  }, []string{"route"})
+  // Replaced by hde_api_requests_total
+  legacyAPIRequestStatus = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: "hde_api_request_status",
+    Help: "Status of the api request. Deprecated, use hde_api_requests_total",
+  }, []string{"route", "status"})
+
+  legacyGalaxyMissionTime = prometheus.NewGauge(prometheus.GaugeOpts{
+    Name: "hde_galaxy_mission_time",
+    Help: "Time spent on missions in the galaxy. Deprecated, use hde_galaxy_mission_time_seconds",
+  })
+  legacyGalaxyTimePlayed = prometheus.NewGauge(prometheus.GaugeOpts{
+    Name: "hde_galaxy_time_played",
+    Help: "Time played in the galaxy. Deprecated, use hde_galaxy_time_played_seconds",
+  })
+  legacyPlanetMissionTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "hde_planet_mission_time",
+    Help: "Time spent on missions on the planet. Deprecated, use hde_planet_mission_time_seconds",
+  }, []string{"planet"})
+  legacyPlanetTimePlayed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: "hde_planet_time_played",
+    Help: "Time played on the planet. Deprecated, use hde_planet_time_played_seconds",
+  }, []string{"planet"})
+)
+
+// Record the duration of an upstream API request, with its correlation ID
+// as exemplar
+func observeAPIRequestDuration(route string, seconds float64, requestID string) {
+  observer := apiRequestDuration.WithLabelValues(route)
+  if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && requestID != "" {
+    exemplarObserver.ObserveWithExemplar(seconds, prometheus.Labels{"request_id": requestID})
+  } else {
+    observer.Observe(seconds)
+  }
+  legacyAPIRequestDuration.WithLabelValues(route).Observe(seconds)
+}
+
+// Status of the upstream API requests that failed without a response
+// (e.g. connection refused, timeout)
+const apiRequestErrorStatus = "error"
+
+// Count an upstream API request by its HTTP status, or apiRequestErrorStatus
+func countAPIRequest(route string, status string) {
+  apiRequests.WithLabelValues(route, status).Inc()
+  legacyAPIRequestStatus.WithLabelValues(route, status).Inc()
+}
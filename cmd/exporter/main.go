@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"path"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -17,11 +18,9 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
-  "github.com/Xide/helldivers2-dashboard/pkg/client"
+	"github.com/Xide/helldivers2-dashboard/pkg/client"
 )
 
-var planetNames = map[int32]string{}
-
 var flags *pflag.FlagSet = pflag.NewFlagSet("hde", pflag.ExitOnError)
 
 func initLogger() {
@@ -34,9 +33,30 @@ func init() {
 
   viper.Set("collector_version", "0.0.1")
   flags.String("collector", "helldivers2-api", "Name of the collector")
+  flags.String("source", "official", "Source of the war data (official, community, replay)")
+  flags.Duration("scrape_interval", 30*time.Second, "Interval between two scrapes of the source")
   flags.String("api_url", "https://api.live.prod.thehelldiversgame.com/api", "URL of the API")
+  flags.String("community_api_url", "https://api.helldivers2.dev", "URL of the community API, used by the community source")
+  flags.String("community_client", "helldivers2-dashboard", "Client name sent to the community API")
+  flags.String("community_contact", "", "Contact information sent to the community API")
+  flags.String("record_dir", "", "Directory where the raw upstream responses are recorded, disabled when empty")
+  flags.Int64("record_max_size_mb", 100, "Size of a recording file, in compressed megabytes, before it is rotated")
+  flags.Duration("record_max_age", 24*time.Hour, "Age of a recording file before it is rotated")
+  flags.Bool("validate_responses", false, "Check the responses of the official API against spec.yaml, and log the differences")
+  flags.String("schema_drift_file", "", "File where the shapes of the fields missing from spec.yaml are kept across restarts, in memory only when empty")
+  flags.String("replay_path", "", "Recording file or directory read by the replay source")
+  flags.String("replay_mode", "realtime", "Playback of the replay source (realtime, accelerated, step)")
+  flags.Float64("replay_speed", 60, "Speed factor of the accelerated replay")
   flags.String("expose_address", ":9101", "Address to expose the metrics")
+  flags.Bool("debug_endpoints", false, "Expose the pprof profiles and the last fetched upstream payloads under /debug")
+  flags.String("web_config_file", "", "Path of the web configuration file (TLS and basic auth), in the exporter-toolkit format")
   flags.String("json_data_dir", "/data", "Directory where the static json data is stored")
+  flags.Int("events_history", 100, "Number of recent war events served by /events")
+  flags.String("webhook_config", "", "Path of the YAML file configuring the war events webhooks, disabled when empty")
+  flags.String("api_latency_buckets", defaultAPILatencyBuckets, "Comma separated buckets of the API latency histogram, in seconds")
+  flags.Bool("api_latency_native_histogram", false, "Also expose the API latency histogram as a Prometheus native histogram")
+  flags.Bool("legacy_metric_names", true, "Also export the metrics renamed to follow the Prometheus naming conventions under their legacy name")
+  flags.String("battle_stats_mode", "gauge", "How cumulative battle statistics are exported: gauge (legacy), counter (_total counters) or both")
 
   err := viper.BindPFlags(flags)
   if err != nil {
@@ -45,34 +65,59 @@ func init() {
   initLogger()
 }
 
+// Names of the metrics
+const (
+  // Namespace of the hde_build_info metric of the version collector
+  buildInfoNamespace           = "hde"
+  planetHealthName             = "hde_planet_health"
+  planetMaxHealthName          = "hde_planet_max_health"
+  planetPlayersName            = "hde_planet_players"
+  apiRequestsName              = "hde_api_requests_total"
+  planetMissionsWonName        = "hde_planet_missions_won"
+  planetMissionsLostName       = "hde_planet_missions_lost"
+  planetBulletsFiredName       = "hde_planet_bullets_fired"
+  planetBulletsHitName         = "hde_planet_bullets_hit"
+  planetMissionSuccessRateName = "hde_planet_mission_success_rate"
+  planetDefenseHealthName      = "hde_planet_defense_health"
+  planetDefenseMaxHealthName   = "hde_planet_defense_max_health"
+  planetDefenseRemainingName   = "hde_planet_defense_remaining_seconds"
+  lastScrapeSuccessName        = "hde_last_scrape_success_timestamp_seconds"
+  planetRegenRateName          = "hde_planet_regen_rate"
+  galaxyMissionSuccessRateName = "hde_galaxy_mission_success_rate"
+  galaxyAccuracyName           = "hde_galaxy_accuracy"
+  planetAccuracyName           = "hde_planet_accuracy"
+  planetInfoName               = "hde_planet_info"
+  planetHazardName             = "hde_planet_hazard_info"
+  staticDataReloadsName        = "hde_static_data_reloads_total"
+  staticDataLastReloadName     = "hde_static_data_last_reload_success_timestamp_seconds"
+)
+
 var (
   planetHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_health",
+    Name: planetHealthName,
     Help: "Health of the planet",
   }, []string{"planet"})
 
   planetMaxHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_max_health",
+    Name: planetMaxHealthName,
     Help: "Max health of the planet",
   }, []string{"planet"})
 
   planetPlayers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_players",
+    Name: planetPlayersName,
     Help: "Number of players on the planet",
   }, []string{"planet"})
 
   planetRegenRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_regen_rate",
+    Name: planetRegenRateName,
     Help: "Regen rate of the planet",
   }, []string{"planet"})
 
-  apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-    Name: "hde_api_request_duration",
-    Help: "Duration of the api request",
-  }, []string{"route"})
-  apiRequestStatus = prometheus.NewCounterVec(prometheus.CounterOpts{
-    Name: "hde_api_request_status",
-    Help: "Status of the api request",
+  // Rebuilt from the configuration by configureAPIRequestDuration
+  apiRequestDuration = newAPIRequestDuration(mustParseBuckets(defaultAPILatencyBuckets), false)
+  apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: apiRequestsName,
+    Help: "Number of api requests, by route and status",
   }, []string{"route", "status"})
 
   galaxyMissionsWon = prometheus.NewGauge(prometheus.GaugeOpts{
@@ -86,7 +131,7 @@ var (
   })
 
   galaxyMissionTime = prometheus.NewGauge(prometheus.GaugeOpts{
-    Name: "hde_galaxy_mission_time",
+    Name: "hde_galaxy_mission_time_seconds",
     Help: "Time spent on missions in the galaxy",
   })
 
@@ -116,7 +161,7 @@ var (
   })
 
   galaxyTimePlayed = prometheus.NewGauge(prometheus.GaugeOpts{
-    Name: "hde_galaxy_time_played",
+    Name: "hde_galaxy_time_played_seconds",
     Help: "Time played in the galaxy",
   })
 
@@ -136,25 +181,25 @@ var (
   })
 
   galaxyMissionSuccessRate = prometheus.NewGauge(prometheus.GaugeOpts{
-    Name: "hde_galaxy_mission_success_rate",
+    Name: galaxyMissionSuccessRateName,
     Help: "Mission success rate in the galaxy",
   })
 
   galaxyAccuracy = prometheus.NewGauge(prometheus.GaugeOpts{
-    Name: "hde_galaxy_accuracy",
+    Name: galaxyAccuracyName,
     Help: "Accuracy in the galaxy",
   })
 
   planetMissionsWon = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_missions_won",
+    Name: planetMissionsWonName,
     Help: "Number of missions won on the planet",
   }, []string{"planet"})
   planetMissionsLost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_missions_lost",
+    Name: planetMissionsLostName,
     Help: "Number of missions lost on the planet",
   }, []string{"planet"})
   planetMissionTime = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_mission_time",
+    Name: "hde_planet_mission_time_seconds",
     Help: "Time spent on missions on the planet",
   }, []string{"planet"})
   planetBugKills = prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -170,15 +215,15 @@ var (
     Help: "Number of illuminate kills on the planet",
   }, []string{"planet"})
   planetBulletsFired = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_bullets_fired",
+    Name: planetBulletsFiredName,
     Help: "Number of bullets fired on the planet",
   }, []string{"planet"})
   planetBulletsHit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_bullets_hit",
+    Name: planetBulletsHitName,
     Help: "Number of bullets hit on the planet",
   }, []string{"planet"})
   planetTimePlayed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_time_played",
+    Name: "hde_planet_time_played_seconds",
     Help: "Time played on the planet",
   }, []string{"planet"})
   planetDeaths = prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -194,93 +239,110 @@ var (
     Help: "Number of friendlies (fire?) on the planet",
   }, []string{"planet"})
   planetMissionSuccessRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_mission_success_rate",
+    Name: planetMissionSuccessRateName,
     Help: "Mission success rate on the planet",
   }, []string{"planet"})
   planetAccuracy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
-    Name: "hde_planet_accuracy",
+    Name: planetAccuracyName,
     Help: "Accuracy on the planet",
   }, []string{"planet"})
-)
 
-// Fetch information from the 3 main endpoints:
-// * Current war status (e.g. planet health, players, regen rate)
-// * War info (e.g. max health of the planets)
-// * War statistics (e.g. missions won, time played, etc.)
-// Returns this information as 3 structs
-// Fills prometheus histograms for HTTP queries
-func fetch(cl client.ClientWithResponsesInterface) (*client.WarSeasonStatus, *client.WarSeasonInfo, *client.WarStatistics, error) {
-  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-  defer cancel()
-  tStart := time.Now()
-  warStatus, err := cl.GetWarSeasonWarIdStatusWithResponse(ctx, 801)
-  tEnd := time.Now()
-  apiRequestDuration.WithLabelValues("war_status").Observe(tEnd.Sub(tStart).Seconds())
-  if err != nil {
-    slog.Error("Error fetching war status", slog.Any("error", err))
-    return nil, nil, nil, err
-  }
-  slog.Info("Fetched war status", slog.Int("code", warStatus.StatusCode()), slog.Duration("duration", tEnd.Sub(tStart)))
-  apiRequestStatus.WithLabelValues("war_status", fmt.Sprintf("%d", warStatus.StatusCode())).Inc()
-  if warStatus.StatusCode() != 200 {
-    slog.Error("Error code while fetching war status", slog.Int("code", warStatus.StatusCode()))
-    return nil, nil, nil, fmt.Errorf("Error fetching war status")
-  }
-  warStatusRes := warStatus.JSON200
-
-  tStart = time.Now()
-  warInfo, err := cl.GetWarSeasonWarIdWarInfoWithResponse(ctx, 801)
-  tEnd = time.Now()
-  apiRequestDuration.WithLabelValues("war_info").Observe(tEnd.Sub(tStart).Seconds())
-  if err != nil {
-    slog.Error("Error fetching war info", slog.Any("error", err))
-    return nil, nil, nil, err
-  }
-  slog.Info("Fetched war info", slog.Int("code", warInfo.StatusCode()), slog.Duration("duration", tEnd.Sub(tStart)))
-  apiRequestStatus.WithLabelValues("war_info", fmt.Sprintf("%d", warInfo.StatusCode())).Inc()
-  if warInfo.StatusCode() != 200 {
-    slog.Error("Error code while fetching war info", slog.Int("code", warInfo.StatusCode()))
-    return nil, nil, nil, fmt.Errorf("Error fetching war info")
-  }
-  warInfoRes := warInfo.JSON200
+  planetInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetInfoName,
+    Help: "Reference data of the planet, always 1",
+  }, []string{"planet", "index", "sector", "biome"})
+  planetHazard = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetHazardName,
+    Help: "Environmental hazards of the planet, always 1",
+  }, []string{"planet", "hazard"})
+
+  planetDefenseHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetDefenseHealthName,
+    Help: "Health of the ongoing defense campaign of the planet, the defense is won when it reaches 0",
+  }, []string{"planet", "faction"})
+  planetDefenseMaxHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetDefenseMaxHealthName,
+    Help: "Max health of the ongoing defense campaign of the planet",
+  }, []string{"planet", "faction"})
+  planetDefenseRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: planetDefenseRemainingName,
+    Help: "Time left before the ongoing defense campaign of the planet expires",
+  }, []string{"planet", "faction"})
+
+  lastScrapeSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+    Name: lastScrapeSuccessName,
+    Help: "Timestamp of the last successful scrape of the upstream API",
+  })
 
-  tStart = time.Now()
-  warStats, err := cl.GetStatsWarWarIdSummaryWithResponse(ctx, 801)
-  tEnd = time.Now()
-  apiRequestDuration.WithLabelValues("war_stats").Observe(tEnd.Sub(tStart).Seconds())
-  if err != nil {
-    slog.Error("Error fetching war stats", slog.Any("error", err))
-    return nil, nil, nil, err
-  }
-  slog.Info("Fetched war stats", slog.Int("code", warStats.StatusCode()), slog.Duration("duration", tEnd.Sub(tStart)))
-  apiRequestStatus.WithLabelValues("war_stats", fmt.Sprintf("%d", warStats.StatusCode())).Inc()
-  if warStats.StatusCode() != 200 {
-    slog.Error("Error code while fetching war stats", slog.Int("code", warStats.StatusCode()))
-    return nil, nil, nil, fmt.Errorf("Error fetching war stats")
-  }
-  warStatsRes := warStats.JSON200
+  staticDataReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: staticDataReloadsName,
+    Help: "Number of static data file loads, by result",
+  }, []string{"file", "result"})
+  staticDataLastReload = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+    Name: staticDataLastReloadName,
+    Help: "Timestamp of the last successful static data file load",
+  }, []string{"file"})
+)
 
-  return warStatusRes, warInfoRes, warStatsRes, nil
+// Metrics labelled by planet name, their series are dropped when a planet is renamed
+var planetVecs = []*prometheus.GaugeVec{
+  planetHealth,
+  planetMaxHealth,
+  planetPlayers,
+  planetRegenRate,
+  planetMissionsWon,
+  planetMissionsLost,
+  planetMissionTime,
+  planetBugKills,
+  planetAutomatonKills,
+  planetIlluminateKills,
+  planetBulletsFired,
+  planetBulletsHit,
+  planetTimePlayed,
+  legacyPlanetMissionTime,
+  legacyPlanetTimePlayed,
+  planetDeaths,
+  planetRevives,
+  planetFriendlies,
+  planetMissionSuccessRate,
+  planetAccuracy,
+  planetInfo,
+  planetHazard,
+  planetKillsPerDeath,
+  planetKillsPerMission,
+  planetDeathsPerMission,
+  planetWinRatio,
+  planetComputedAccuracy,
+  planetComputedAccuracyOverflow,
+  planetRevivesPerDeath,
 }
 
-// Scrape the API and fill the prometheus metrics
+// Scrape the source and fill the prometheus metrics
 // Returns an error if the API call fails
 // called every 30 seconds
-func scrape(cl client.ClientWithResponsesInterface) error {
-  status, infos, stats, err := fetch(cl)
+func scrape(src Source) error {
+  ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+  defer cancel()
+  snapshot, err := src.Fetch(ctx)
+  scraper.recordScrape(snapshot, err)
   if err != nil {
     return err
   }
+  status, infos, stats := snapshot.Status, snapshot.Info, snapshot.Stats
+  staticData := currentStaticData()
+  planetNames := staticData.names
 
   galaxyMissionsWon.Set(float64(stats.GalaxyStats.MissionsWon))
   galaxyMissionsLost.Set(float64(stats.GalaxyStats.MissionsLost))
   galaxyMissionTime.Set(float64(stats.GalaxyStats.MissionTime))
+  legacyGalaxyMissionTime.Set(float64(stats.GalaxyStats.MissionTime))
   galaxyBugKills.Set(float64(stats.GalaxyStats.BugKills))
   galaxyAutomatonKills.Set(float64(stats.GalaxyStats.AutomatonKills))
   galaxyIlluminateKills.Set(float64(stats.GalaxyStats.IlluminateKills))
   galaxyBulletsFired.Set(float64(stats.GalaxyStats.BulletsFired))
   galaxyBulletsHit.Set(float64(stats.GalaxyStats.BulletsHit))
   galaxyTimePlayed.Set(float64(stats.GalaxyStats.TimePlayed))
+  legacyGalaxyTimePlayed.Set(float64(stats.GalaxyStats.TimePlayed))
   galaxyDeaths.Set(float64(stats.GalaxyStats.Deaths))
   galaxyRevives.Set(float64(stats.GalaxyStats.Revives))
   galaxyFriendlies.Set(float64(stats.GalaxyStats.Friendlies))
@@ -296,12 +358,14 @@ func scrape(cl client.ClientWithResponsesInterface) error {
     planetMissionsWon.WithLabelValues(planetName).Set(float64(planet.MissionsWon))
     planetMissionsLost.WithLabelValues(planetName).Set(float64(planet.MissionsLost))
     planetMissionTime.WithLabelValues(planetName).Set(float64(planet.MissionTime))
+    legacyPlanetMissionTime.WithLabelValues(planetName).Set(float64(planet.MissionTime))
     planetBugKills.WithLabelValues(planetName).Set(float64(planet.BugKills))
     planetAutomatonKills.WithLabelValues(planetName).Set(float64(planet.AutomatonKills))
     planetIlluminateKills.WithLabelValues(planetName).Set(float64(planet.IlluminateKills))
     planetBulletsFired.WithLabelValues(planetName).Set(float64(planet.BulletsFired))
     planetBulletsHit.WithLabelValues(planetName).Set(float64(planet.BulletsHit))
     planetTimePlayed.WithLabelValues(planetName).Set(float64(planet.TimePlayed))
+    legacyPlanetTimePlayed.WithLabelValues(planetName).Set(float64(planet.TimePlayed))
     planetDeaths.WithLabelValues(planetName).Set(float64(planet.Deaths))
     planetRevives.WithLabelValues(planetName).Set(float64(planet.Revives))
     planetFriendlies.WithLabelValues(planetName).Set(float64(planet.Friendlies))
@@ -309,6 +373,18 @@ func scrape(cl client.ClientWithResponsesInterface) error {
     planetAccuracy.WithLabelValues(planetName).Set(float64(planet.Accuracy))
 
   }
+  planetStats := map[string]client.BattleStatistics{}
+  for index, planet := range planetsBattleStatistics(stats) {
+    if planetName, ok := planetNames[index]; ok {
+      planetStats[planetName] = planet
+    }
+  }
+  battleStats.update(stats.GalaxyStats, planetStats)
+  exportGalaxyEfficiency(stats.GalaxyStats)
+  for planetName, planet := range planetStats {
+    exportPlanetEfficiency(planetName, planet)
+  }
+
   for _, planet := range infos.PlanetInfos {
     planetName, ok := planetNames[planet.Index]
     if !ok {
@@ -316,6 +392,20 @@ func scrape(cl client.ClientWithResponsesInterface) error {
       continue
     }
     planetMaxHealth.WithLabelValues(planetName).Set(float64(planet.MaxHealth))
+
+    // Reference data may change between two scrapes, replace the previous series
+    reference := staticData.planets[planet.Index]
+    planetInfo.DeletePartialMatch(prometheus.Labels{"planet": planetName})
+    planetInfo.WithLabelValues(
+      planetName,
+      strconv.Itoa(int(planet.Index)),
+      staticData.planetSector(snapshot, planet),
+      reference.Biome,
+    ).Set(1)
+    planetHazard.DeletePartialMatch(prometheus.Labels{"planet": planetName})
+    for _, hazard := range reference.Hazards {
+      planetHazard.WithLabelValues(planetName, hazard).Set(1)
+    }
   }
   for _, planet := range status.PlanetStatus {
     planetName, ok := planetNames[planet.Index]
@@ -328,107 +418,261 @@ func scrape(cl client.ClientWithResponsesInterface) error {
     planetRegenRate.WithLabelValues(planetName).Set(float64(planet.RegenPerSecond))
   }
 
+  // Defense campaigns come and go, start from a clean slate
+  planetDefenseHealth.Reset()
+  planetDefenseMaxHealth.Reset()
+  planetDefenseRemaining.Reset()
+  for _, event := range status.PlanetEvents {
+    planetName, ok := planetNames[event.PlanetIndex]
+    if !ok {
+      slog.Warn("Unknown planet", slog.Int("planet_id", int(event.PlanetIndex)))
+      continue
+    }
+    faction := factionName(event.Race)
+    planetDefenseHealth.WithLabelValues(planetName, faction).Set(float64(event.Health))
+    planetDefenseMaxHealth.WithLabelValues(planetName, faction).Set(float64(event.MaxHealth))
+    planetDefenseRemaining.WithLabelValues(planetName, faction).Set(float64(event.ExpireTime - status.Time))
+  }
+  exportGlobalEvents(status.GlobalEvents, planetNames)
+  aggregate(snapshot, staticData)
+  warEvents.observe(snapshot, planetNames)
+  lastScrapeSuccess.SetToCurrentTime()
+
   return nil
 }
 
-// Start the scraper, which will scrape the API every 30 seconds
+// Start the scraper, which will scrape the API every scrape_interval (30 seconds by default)
 // Started as a goroutine
-func startScraper() {
-  cl, err := client.NewClientWithResponses(viper.GetString("api_url"))
-  if err != nil {
-    panic(err)
+func startScraper(src Source) {
+  slog.Info("Starting scraper", slog.String("source", src.Name()))
+  scraper.setSource(src.Name())
+  if replay, ok := src.(*replaySource); ok && replay.mode == "step" {
+    replay.runSteps(func() error { return scrape(src) })
+    return
   }
-  slog.Info("Starting scraper")
   for {
     slog.Info("Performing scrape")
-    err = scrape(cl)
+    err := scrape(src)
+    if errors.Is(err, errReplayFinished) {
+      // Metrics keep the values of the last replayed scrape
+      slog.Info("Replay finished, stopping the scraper")
+      return
+    }
     if err != nil {
       fmt.Println("Error scraping", err)
     }
-    time.Sleep(30 * time.Second)
+    time.Sleep(viper.GetDuration("scrape_interval"))
   }
 }
 
-// Load the static assets (planet names) from the json files
-// Returns an error if the files cannot be loaded
-func loadStaticAssets() error {
-  slog.Info("Loading static assets")
-  slog.Info("Loading planet names")
-  planetsFile, err := os.Open(path.Join(viper.GetString("json_data_dir"), "planets.json"))
-  if err != nil {
-    slog.Error("Error loading planet names", slog.Any("error", err))
-    return err
-  }
-  defer planetsFile.Close()
-  planetData, err := io.ReadAll(planetsFile)
-  if err != nil {
-    slog.Error("Failed to read planet names", slog.Any("error", err))
-    return err
-  }
-  err = json.Unmarshal(planetData, &planetNames)
-  if err != nil {
-    slog.Error("Failed to unmarshal planet names", slog.Any("error", err))
-    return err
-  }
-  slog.Info("Loaded planet names")
-  slog.Info("Loaded static assets")
-  return nil
-}
-
-func main() {
-  err := loadStaticAssets()
-  if err != nil {
-    panic(err)
-  }
-  	// Create a new registry.
-	reg := prometheus.NewRegistry()
-
-	// Register version collector.
-	reg.MustRegister(version.NewCollector("hde"))
+// Register the collectors of the exporter.
+// The cumulative battle statistics are registered as gauges and/or counters
+// depending on battle_stats_mode, the metrics renamed to follow the naming
+// conventions are also registered under their legacy name when legacyNames is set.
+func registerCollectors(reg prometheus.Registerer, battleGauges bool, battleCounters bool, legacyNames bool) {
+  // Register version collector.
+  reg.MustRegister(version.NewCollector(buildInfoNamespace))
 
   reg.MustRegister(planetHealth)
   reg.MustRegister(planetMaxHealth)
   reg.MustRegister(planetRegenRate)
   reg.MustRegister(planetPlayers)
   reg.MustRegister(apiRequestDuration)
-  reg.MustRegister(apiRequestStatus)
-  reg.MustRegister(galaxyMissionsWon)
-  reg.MustRegister(galaxyMissionsLost)
-  reg.MustRegister(galaxyMissionTime)
-  reg.MustRegister(galaxyBugKills)
-  reg.MustRegister(galaxyAutomatonKills)
-  reg.MustRegister(galaxyIlluminateKills)
-  reg.MustRegister(galaxyBulletsFired)
-  reg.MustRegister(galaxyBulletsHit)
-  reg.MustRegister(galaxyTimePlayed)
-  reg.MustRegister(galaxyDeaths)
-  reg.MustRegister(galaxyRevives)
-  reg.MustRegister(galaxyFriendlies)
+  reg.MustRegister(apiRequests)
+  if legacyNames {
+    reg.MustRegister(legacyAPIRequestDuration)
+    reg.MustRegister(legacyAPIRequestStatus)
+  }
   reg.MustRegister(galaxyMissionSuccessRate)
   reg.MustRegister(galaxyAccuracy)
-  reg.MustRegister(planetMissionsWon)
-  reg.MustRegister(planetMissionsLost)
-  reg.MustRegister(planetMissionTime)
-  reg.MustRegister(planetBugKills)
-  reg.MustRegister(planetAutomatonKills)
-  reg.MustRegister(planetIlluminateKills)
-  reg.MustRegister(planetBulletsFired)
-  reg.MustRegister(planetBulletsHit)
-  reg.MustRegister(planetTimePlayed)
-  reg.MustRegister(planetDeaths)
-  reg.MustRegister(planetRevives)
-  reg.MustRegister(planetFriendlies)
   reg.MustRegister(planetMissionSuccessRate)
   reg.MustRegister(planetAccuracy)
+  if battleGauges {
+    reg.MustRegister(galaxyMissionsWon)
+    reg.MustRegister(galaxyMissionsLost)
+    reg.MustRegister(galaxyMissionTime)
+    reg.MustRegister(galaxyBugKills)
+    reg.MustRegister(galaxyAutomatonKills)
+    reg.MustRegister(galaxyIlluminateKills)
+    reg.MustRegister(galaxyBulletsFired)
+    reg.MustRegister(galaxyBulletsHit)
+    reg.MustRegister(galaxyTimePlayed)
+    reg.MustRegister(galaxyDeaths)
+    reg.MustRegister(galaxyRevives)
+    reg.MustRegister(galaxyFriendlies)
+    reg.MustRegister(planetMissionsWon)
+    reg.MustRegister(planetMissionsLost)
+    reg.MustRegister(planetMissionTime)
+    reg.MustRegister(planetBugKills)
+    reg.MustRegister(planetAutomatonKills)
+    reg.MustRegister(planetIlluminateKills)
+    reg.MustRegister(planetBulletsFired)
+    reg.MustRegister(planetBulletsHit)
+    reg.MustRegister(planetTimePlayed)
+    reg.MustRegister(planetDeaths)
+    reg.MustRegister(planetRevives)
+    reg.MustRegister(planetFriendlies)
+    if legacyNames {
+      reg.MustRegister(legacyGalaxyMissionTime)
+      reg.MustRegister(legacyGalaxyTimePlayed)
+      reg.MustRegister(legacyPlanetMissionTime)
+      reg.MustRegister(legacyPlanetTimePlayed)
+    }
+  }
+  if battleCounters {
+    reg.MustRegister(battleStats)
+  }
+  reg.MustRegister(battleStatsResets)
+  reg.MustRegister(galaxyKillsPerDeath)
+  reg.MustRegister(galaxyKillsPerMission)
+  reg.MustRegister(galaxyDeathsPerMission)
+  reg.MustRegister(galaxyWinRatio)
+  reg.MustRegister(galaxyComputedAccuracy)
+  reg.MustRegister(galaxyComputedAccuracyOverflow)
+  reg.MustRegister(galaxyRevivesPerDeath)
+  reg.MustRegister(planetKillsPerDeath)
+  reg.MustRegister(planetKillsPerMission)
+  reg.MustRegister(planetDeathsPerMission)
+  reg.MustRegister(planetWinRatio)
+  reg.MustRegister(planetComputedAccuracy)
+  reg.MustRegister(planetComputedAccuracyOverflow)
+  reg.MustRegister(planetRevivesPerDeath)
+  reg.MustRegister(planetInfo)
+  reg.MustRegister(planetHazard)
+  reg.MustRegister(frontPlayers)
+  reg.MustRegister(frontKills)
+  reg.MustRegister(frontDeaths)
+  reg.MustRegister(frontMissionsWon)
+  reg.MustRegister(frontMissionsLost)
+  reg.MustRegister(frontPlanets)
+  reg.MustRegister(sectorPlayers)
+  reg.MustRegister(sectorKills)
+  reg.MustRegister(sectorDeaths)
+  reg.MustRegister(sectorMissionsWon)
+  reg.MustRegister(sectorMissionsLost)
+  reg.MustRegister(sectorPlanets)
+  reg.MustRegister(planetDefenseHealth)
+  reg.MustRegister(planetDefenseMaxHealth)
+  reg.MustRegister(planetDefenseRemaining)
+  reg.MustRegister(globalEventInfo)
+  reg.MustRegister(globalEventPlanet)
+  reg.MustRegister(globalEvents)
+  reg.MustRegister(lastScrapeSuccess)
+  reg.MustRegister(warEventsTotal)
+  reg.MustRegister(webhookNotifications)
+  reg.MustRegister(staticDataReloads)
+  reg.MustRegister(schemaDrift)
+  reg.MustRegister(staticDataLastReload)
+  reg.MustRegister(webConfigReloads)
+  reg.MustRegister(webConfigLastReload)
+}
 
-	// Expose the registered metrics via HTTP.
-	http.Handle("/metrics", promhttp.HandlerFor(
+// Close the recording of the upstream responses on SIGINT or SIGTERM, so the
+// gzip stream of its last file is complete, then exit
+func closeOnSignal() {
+  stop := make(chan os.Signal, 1)
+  signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+  sig := <-stop
+  slog.Info("Shutting down", slog.String("signal", sig.String()))
+  if recording != nil {
+    err := recording.Close()
+    if err != nil {
+      slog.Error("Failed to close the recording", slog.Any("error", err))
+      os.Exit(1)
+    }
+  }
+  os.Exit(0)
+}
+
+func main() {
+  if len(os.Args) > 1 && os.Args[1] == "rules" {
+    err := runRules(os.Args[2:])
+    if err != nil {
+      slog.Error("Error writing rules", slog.Any("error", err))
+      os.Exit(1)
+    }
+    return
+  }
+  if len(os.Args) > 1 && os.Args[1] == "check" {
+    err := runCheck(os.Args[2:])
+    if err != nil {
+      slog.Error("Error checking queries", slog.Any("error", err))
+      os.Exit(1)
+    }
+    return
+  }
+  if len(os.Args) > 1 && os.Args[1] == "validate" {
+    err := runValidate(os.Args[2:])
+    if err != nil {
+      slog.Error("Error validating responses", slog.Any("error", err))
+      os.Exit(1)
+    }
+    return
+  }
+  if len(os.Args) > 1 && os.Args[1] == "sectors" {
+    err := runSectors(os.Args[2:])
+    if err != nil {
+      slog.Error("Error writing sectors", slog.Any("error", err))
+      os.Exit(1)
+    }
+    return
+  }
+  if len(os.Args) > 1 && os.Args[1] == "dashboards" {
+    err := runDashboards(os.Args[2:])
+    if err != nil {
+      slog.Error("Error writing dashboards", slog.Any("error", err))
+      os.Exit(1)
+    }
+    return
+  }
+  battleStatsMode := viper.GetString("battle_stats_mode")
+  err := validateBattleStatsMode(battleStatsMode)
+  if err != nil {
+    panic(err)
+  }
+  err = validateEventsHistory(viper.GetInt("events_history"))
+  if err != nil {
+    panic(err)
+  }
+  err = loadStaticAssets()
+  if err != nil {
+    panic(err)
+  }
+  err = configureAPIRequestDuration()
+  if err != nil {
+    panic(err)
+  }
+  schemaDrift, err = newSchemaDriftTracker()
+  if err != nil {
+    panic(err)
+  }
+  // Create a new registry.
+  reg := prometheus.NewRegistry()
+  registerCollectors(reg, battleStatsGauges(battleStatsMode), battleStatsCounters(battleStatsMode), viper.GetBool("legacy_metric_names"))
+
+  warEvents = newWarEventLog(viper.GetInt("events_history"))
+  src, err := newSource()
+  if err != nil {
+    panic(err)
+  }
+  // Expose the registered metrics via HTTP.
+  mux := http.NewServeMux()
+  registerHandlers(mux, promhttp.HandlerFor(
 		reg,
-		promhttp.HandlerOpts{},
-	))
-  go startScraper()
+		promhttp.HandlerOpts{
+			// Required to expose the exemplars of the API latency histogram
+			EnableOpenMetrics: true,
+		},
+	), warEvents, schemaDrift, replayStepHandler(src), viper.GetBool("debug_endpoints"))
+  err = startWebhooks(context.Background(), warEvents)
+  if err != nil {
+    panic(err)
+  }
+  go closeOnSignal()
+  go watchStaticAssets()
+  go startScraper(src)
   slog.Info("Starting server", slog.String("address", viper.GetString("expose_address")))
-  err = http.ListenAndServe(viper.GetString("expose_address"), nil)
+  err = listenAndServe(viper.GetString("expose_address"), viper.GetString("web_config_file"), mux)
   if err != nil {
     slog.Error("Error starting server", slog.Any("error", err))
     panic(err)
@@ -0,0 +1,319 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+  "github.com/Xide/helldivers2-dashboard/pkg/recorder"
+)
+
+// Returned by the replay source once every recorded scrape has been served
+var errReplayFinished = errors.New("replay finished")
+
+// Route names of the recorded paths, by suffix of the recorded route.
+// The first route of each set starts a scrape.
+var officialReplayRoutes = []struct{ suffix, name string }{
+  {"/WarSeason/{war_id}/Status", "war_status"},
+  {"/WarSeason/{war_id}/WarInfo", "war_info"},
+  {"/Stats/war/{war_id}/summary", "war_stats"},
+  {"/v2/Assignment/War/{war_id}", "assignments"},
+}
+
+var communityReplayRoutes = []struct{ suffix, name string }{
+  {"/api/v1/war", "community_war"},
+  {"/api/v1/planets", "community_planets"},
+  {"/api/v1/assignments", "community_assignments"},
+}
+
+// replaySource reads the war from recordings of the upstream API (see
+// pkg/recorder) instead of calling it. Recordings of both the official
+// and the community API can be replayed.
+//
+// The recorded scrapes are played back following a replay clock:
+// * realtime: at the pace they were recorded
+// * accelerated: `speed` times faster
+// * step: the first recorded scrape, then one more per POST /replay/step,
+//   regardless of time
+type replaySource struct {
+  mode  string
+  speed float64
+  // Recorded entries, by route name, sorted by time
+  routes    map[string][]recorder.Entry
+  community bool
+  // Time of the last entry of each recorded scrape
+  scrapes []time.Time
+
+  mu sync.Mutex
+  // Wall time of the first Fetch
+  started time.Time
+  // Index of the last served scrape, -1 before the first Fetch
+  served int
+
+  // Step requests of the step mode, each one with the channel
+  // the result of its scrape is sent back on
+  steps chan chan error
+}
+
+// Load the recordings at `path`, a recording file or a directory
+func newReplaySource(path string, mode string, speed float64) (*replaySource, error) {
+  switch mode {
+  case "realtime":
+    speed = 1
+  case "accelerated":
+    if speed <= 0 {
+      return nil, fmt.Errorf("replay speed must be positive, got %v", speed)
+    }
+  case "step":
+  default:
+    return nil, fmt.Errorf("unknown replay mode %q, expected realtime, accelerated or step", mode)
+  }
+  entries, err := recorder.Read(path)
+  if err != nil {
+    return nil, err
+  }
+  s := &replaySource{mode: mode, speed: speed, routes: map[string][]recorder.Entry{}, served: -1, steps: make(chan chan error)}
+  for _, entry := range entries {
+    if name := replayRouteName(entry.Route); name != "" {
+      s.routes[name] = append(s.routes[name], entry)
+    }
+  }
+
+  // Official recordings take precedence when both were recorded
+  first := officialReplayRoutes[0].name
+  if len(s.routes[first]) == 0 {
+    first = communityReplayRoutes[0].name
+    s.community = true
+  }
+  if len(s.routes[first]) == 0 {
+    return nil, fmt.Errorf("no recorded war status in %s", path)
+  }
+  // A scrape spans from one request of its first route to the next one
+  starts := s.routes[first]
+  for i := range starts {
+    end := entries[len(entries)-1].Time
+    if i+1 < len(starts) {
+      end = starts[i+1].Time.Add(-time.Nanosecond)
+    }
+    last := starts[i].Time
+    for _, routeEntries := range s.routes {
+      if entry := latestEntry(routeEntries, end); entry != nil && entry.Time.After(last) {
+        last = entry.Time
+      }
+    }
+    s.scrapes = append(s.scrapes, last)
+  }
+  slog.Info("Loaded recordings", slog.String("path", path), slog.Int("scrapes", len(s.scrapes)), slog.Time("from", s.scrapes[0]), slog.Time("to", s.scrapes[len(s.scrapes)-1]))
+  return s, nil
+}
+
+// Name of a recorded route, empty if it is not replayed
+func replayRouteName(route string) string {
+  for _, routes := range [][]struct{ suffix, name string }{officialReplayRoutes, communityReplayRoutes} {
+    for _, r := range routes {
+      if strings.HasSuffix(route, r.suffix) {
+        return r.name
+      }
+    }
+  }
+  return ""
+}
+
+// Last entry recorded at or before `at`, nil if there is none
+func latestEntry(entries []recorder.Entry, at time.Time) *recorder.Entry {
+  i := sort.Search(len(entries), func(i int) bool {
+    return entries[i].Time.After(at)
+  })
+  if i == 0 {
+    return nil
+  }
+  return &entries[i-1]
+}
+
+func (s *replaySource) Name() string {
+  return "replay"
+}
+
+// Index of the next scrape to serve
+func (s *replaySource) next() (int, error) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  last := len(s.scrapes) - 1
+  if s.served == last {
+    return 0, errReplayFinished
+  }
+  if s.mode == "step" {
+    s.served++
+    return s.served, nil
+  }
+  if s.started.IsZero() {
+    s.started = time.Now()
+  }
+  // Replay clock, starting at the first recorded scrape
+  elapsed := time.Duration(float64(time.Since(s.started)) * s.speed)
+  at := s.scrapes[0].Add(elapsed)
+  index := sort.Search(len(s.scrapes), func(i int) bool {
+    return s.scrapes[i].After(at)
+  }) - 1
+  s.served = index
+  return index, nil
+}
+
+// Replay a recorded request: fill the API metrics as the live source
+// does, decode its body into `out` and keep it in `responses`
+func (s *replaySource) replay(name string, at time.Time, out interface{}, responses map[string]json.RawMessage) error {
+  entry := latestEntry(s.routes[name], at)
+  if entry == nil {
+    return fmt.Errorf("no recorded %s before %s", name, at.Format(time.RFC3339))
+  }
+  requestID := entry.RequestHeaders.Get(requestIDHeader)
+  duration := time.Duration(entry.Duration * float64(time.Second))
+  observeAPIRequestDuration(name, entry.Duration, requestID)
+  if entry.Status == 0 {
+    err := fmt.Errorf("recorded error: %s", entry.Error)
+    countAPIRequest(name, apiRequestErrorStatus)
+    scraper.recordRequest(name, requestID, duration, 0, err)
+    return err
+  }
+  countAPIRequest(name, strconv.Itoa(entry.Status))
+  scraper.recordRequest(name, requestID, duration, entry.Status, nil)
+  if entry.Status != 200 {
+    return fmt.Errorf("Error fetching %s: recorded status %d", name, entry.Status)
+  }
+  err := json.Unmarshal(entry.Body, out)
+  if err != nil {
+    return err
+  }
+  responses[name] = entry.Body
+  return nil
+}
+
+func (s *replaySource) Fetch(ctx context.Context) (*WarSnapshot, error) {
+  index, err := s.next()
+  if err != nil {
+    return nil, err
+  }
+  at := s.scrapes[index]
+  slog.Info("Replaying scrape", slog.Int("scrape", index+1), slog.Int("scrapes", len(s.scrapes)), slog.Time("recorded_at", at))
+
+  responses := map[string]json.RawMessage{}
+  if s.community {
+    war := communityWar{}
+    if err := s.replay("community_war", at, &war, responses); err != nil {
+      return nil, err
+    }
+    planets := []communityPlanet{}
+    if err := s.replay("community_planets", at, &planets, responses); err != nil {
+      return nil, err
+    }
+    snapshot := communitySnapshot(&war, planets)
+    snapshot.Responses = responses
+    // Assignments are optional, as with the live sources
+    assignments := []communityAssignment{}
+    if err := s.replay("community_assignments", at, &assignments, responses); err == nil {
+      snapshot.Assignments = communityAssignments(&war, assignments)
+    }
+    return snapshot, nil
+  }
+
+  snapshot := &WarSnapshot{
+    Status:    &client.WarSeasonStatus{},
+    Info:      &client.WarSeasonInfo{},
+    Stats:     &client.WarStatistics{},
+    Responses: responses,
+  }
+  if err := s.replay("war_status", at, snapshot.Status, responses); err != nil {
+    return nil, err
+  }
+  if err := s.replay("war_info", at, snapshot.Info, responses); err != nil {
+    return nil, err
+  }
+  if err := s.replay("war_stats", at, snapshot.Stats, responses); err != nil {
+    return nil, err
+  }
+  assignments := []client.Assignment{}
+  if err := s.replay("assignments", at, &assignments, responses); err == nil {
+    snapshot.Assignments = assignments
+  }
+  return snapshot, nil
+}
+
+// Step mode: scrape the first recorded scrape, then one more per step request
+func (s *replaySource) runSteps(scrape func() error) {
+  err := scrape()
+  if err != nil {
+    slog.Error("Error replaying the first scrape", slog.Any("error", err))
+  }
+  slog.Info("Waiting for step requests", slog.String("endpoint", "/replay/step"))
+  for done := range s.steps {
+    done <- scrape()
+  }
+}
+
+// Replay the next recorded scrape, on POST, and answer once it is scraped
+func (s *replaySource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  if r.Method != http.MethodPost {
+    w.Header().Set("Allow", http.MethodPost)
+    http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+    return
+  }
+  s.mu.Lock()
+  finished := s.served == len(s.scrapes)-1
+  s.mu.Unlock()
+  if finished {
+    http.Error(w, errReplayFinished.Error(), http.StatusConflict)
+    return
+  }
+  // Buffered, the scraper never waits for the requester
+  done := make(chan error, 1)
+  select {
+  case s.steps <- done:
+  case <-r.Context().Done():
+    return
+  }
+  err := <-done
+  if err != nil {
+    http.Error(w, err.Error(), http.StatusInternalServerError)
+    return
+  }
+  s.mu.Lock()
+  served := s.served
+  s.mu.Unlock()
+  w.Header().Set("Content-Type", "application/json")
+  err = json.NewEncoder(w).Encode(map[string]interface{}{
+    "scrape":      served + 1,
+    "scrapes":     len(s.scrapes),
+    "recorded_at": s.scrapes[served],
+  })
+  if err != nil {
+    slog.Error("Failed to write the step response", slog.Any("error", err))
+  }
+}
+
+// Handler of the step requests when `src` is a replay source in step mode, nil otherwise
+func replayStepHandler(src Source) http.Handler {
+  if replay, ok := src.(*replaySource); ok && replay.mode == "step" {
+    return replay
+  }
+  return nil
+}
+
+// Build the replay source from the replay_* configuration keys
+func newConfiguredReplaySource() (*replaySource, error) {
+  path := viper.GetString("replay_path")
+  if path == "" {
+    return nil, fmt.Errorf("replay_path is required by the replay source")
+  }
+  return newReplaySource(path, viper.GetString("replay_mode"), viper.GetFloat64("replay_speed"))
+}
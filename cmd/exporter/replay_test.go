@@ -0,0 +1,132 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Recording of 3 scrapes of the simulated mock API, 2s apart
+const replayTestdata = "testdata/replay.jsonl.gz"
+
+// Health of planet 6 and missions won in the galaxy, by recorded scrape
+var replayedScrapes = []struct {
+  health      float64
+  missionsWon float64
+}{
+  {619160, 2845544},
+  {618351, 2846621},
+  {617506, 2847681},
+}
+
+func TestReplayScrape(t *testing.T) {
+  startExporter(t)
+  src, err := newReplaySource(replayTestdata, "step", 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  planet := currentStaticData().names[6]
+  for i, want := range replayedScrapes {
+    requests := testutil.ToFloat64(apiRequests.WithLabelValues("war_status", "200"))
+    err := scrape(src)
+    if err != nil {
+      t.Fatalf("scrape %d: %v", i+1, err)
+    }
+    if value := testutil.ToFloat64(planetHealth.WithLabelValues(planet)); value != want.health {
+      t.Errorf("scrape %d: hde_planet_health of %s: got %v, want %v", i+1, planet, value, want.health)
+    }
+    if value := testutil.ToFloat64(galaxyMissionsWon); value != want.missionsWon {
+      t.Errorf("scrape %d: hde_galaxy_missions_won: got %v, want %v", i+1, value, want.missionsWon)
+    }
+    // The recorded requests fill the API metrics as live ones
+    if value := testutil.ToFloat64(apiRequests.WithLabelValues("war_status", "200")) - requests; value != 1 {
+      t.Errorf("scrape %d: got %v new war_status requests, want 1", i+1, value)
+    }
+  }
+  err = scrape(src)
+  if !errors.Is(err, errReplayFinished) {
+    t.Errorf("scrape after the last recorded one: got %v, want %v", err, errReplayFinished)
+  }
+}
+
+func TestReplayAccelerated(t *testing.T) {
+  src, err := newReplaySource(replayTestdata, "accelerated", 10000)
+  if err != nil {
+    t.Fatal(err)
+  }
+  snapshot, err := src.Fetch(context.Background())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if health := snapshot.Status.PlanetStatus[2].Health; float64(health) != replayedScrapes[0].health {
+    t.Errorf("first scrape: got health %d, want %v", health, replayedScrapes[0].health)
+  }
+  // 10ms of wall time are 100s of replay clock, past the last scrape
+  time.Sleep(10 * time.Millisecond)
+  snapshot, err = src.Fetch(context.Background())
+  if err != nil {
+    t.Fatal(err)
+  }
+  if health := snapshot.Status.PlanetStatus[2].Health; float64(health) != replayedScrapes[2].health {
+    t.Errorf("accelerated scrape: got health %d, want %v", health, replayedScrapes[2].health)
+  }
+}
+
+// Status and decoded body of a request to the step endpoint
+func postStep(t *testing.T, src *replaySource, method string) (int, map[string]any) {
+  t.Helper()
+  rec := httptest.NewRecorder()
+  src.ServeHTTP(rec, httptest.NewRequest(method, "/replay/step", nil))
+  body := map[string]any{}
+  if rec.Code == http.StatusOK {
+    err := json.Unmarshal(rec.Body.Bytes(), &body)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  return rec.Code, body
+}
+
+func TestReplayStepEndpoint(t *testing.T) {
+  startExporter(t)
+  src, err := newReplaySource(replayTestdata, "step", 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if replayStepHandler(src) == nil {
+    t.Fatal("no step handler in step mode")
+  }
+  go src.runSteps(func() error { return scrape(src) })
+
+  if status, _ := postStep(t, src, http.MethodGet); status != http.StatusMethodNotAllowed {
+    t.Errorf("GET: got status %d, want 405", status)
+  }
+  planet := currentStaticData().names[6]
+  for i := 2; i <= len(replayedScrapes); i++ {
+    status, body := postStep(t, src, http.MethodPost)
+    if status != http.StatusOK || body["scrape"] != float64(i) || body["scrapes"] != float64(len(replayedScrapes)) {
+      t.Fatalf("step %d: got status %d, body %v", i, status, body)
+    }
+    // The scrape is done when the step is answered
+    if value := testutil.ToFloat64(planetHealth.WithLabelValues(planet)); value != replayedScrapes[i-1].health {
+      t.Errorf("step %d: hde_planet_health of %s: got %v, want %v", i, planet, value, replayedScrapes[i-1].health)
+    }
+  }
+  if status, _ := postStep(t, src, http.MethodPost); status != http.StatusConflict {
+    t.Errorf("step after the last scrape: got status %d, want 409", status)
+  }
+
+  realtime, err := newReplaySource(replayTestdata, "realtime", 0)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if replayStepHandler(realtime) != nil {
+    t.Error("step handler registered in realtime mode")
+  }
+}
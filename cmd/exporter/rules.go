@@ -0,0 +1,150 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "os"
+
+  "github.com/spf13/pflag"
+  "gopkg.in/yaml.v3"
+)
+
+// Prometheus rule file, see
+// https://prometheus.io/docs/prometheus/latest/configuration/recording_rules/
+type ruleFile struct {
+  Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+  Name  string `yaml:"name"`
+  Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+  Record      string            `yaml:"record,omitempty"`
+  Alert       string            `yaml:"alert,omitempty"`
+  Expr        string            `yaml:"expr"`
+  For         string            `yaml:"for,omitempty"`
+  Labels      map[string]string `yaml:"labels,omitempty"`
+  Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// Alerting and recording rules for the metrics of the exporter
+func exporterRules() ruleFile {
+  return ruleFile{Groups: []ruleGroup{
+    {
+      Name: "hde.recording",
+      Rules: []rule{
+        {
+          Record: "hde:planet_liberation:percent",
+          Expr:   fmt.Sprintf("100 * (1 - (%s / %s))", planetHealthName, planetMaxHealthName),
+        },
+        {
+          Record: "hde:planet_defense_progress:percent",
+          Expr:   fmt.Sprintf("100 * (1 - (%s / %s))", planetDefenseHealthName, planetDefenseMaxHealthName),
+        },
+        {
+          Record: "hde:front_players:sum",
+          Expr:   fmt.Sprintf("sum by (faction) (%s)", frontPlayersName),
+        },
+        {
+          Record: "hde:players:sum",
+          Expr:   fmt.Sprintf("sum(%s)", planetPlayersName),
+        },
+      },
+    },
+    {
+      Name: "hde.alerts",
+      Rules: []rule{
+        {
+          Alert:  "HelldiversExporterStale",
+          Expr:   fmt.Sprintf("time() - %s > 300", lastScrapeSuccessName),
+          For:    "5m",
+          Labels: map[string]string{"severity": "warning"},
+          Annotations: map[string]string{
+            "summary":     "The exporter did not scrape the upstream API successfully for more than 5 minutes",
+            "description": "Last successful scrape {{ $value | humanizeDuration }} ago on {{ $labels.instance }}",
+          },
+        },
+        {
+          Alert:  "HelldiversExporterAbsent",
+          Expr:   fmt.Sprintf("absent(%s)", lastScrapeSuccessName),
+          For:    "5m",
+          Labels: map[string]string{"severity": "warning"},
+          Annotations: map[string]string{
+            "summary":     "The exporter metrics are missing",
+            "description": "Prometheus did not get the metrics of the exporter for more than 5 minutes, the exporter is down or not scraped",
+          },
+        },
+        {
+          Alert: "HelldiversUpstreamErrors",
+          Expr: fmt.Sprintf(
+            "sum(rate(%s{status!~\"2..\"}[15m])) / sum(rate(%s[15m])) > 0.1",
+            apiRequestsName, apiRequestsName,
+          ),
+          For:    "15m",
+          Labels: map[string]string{"severity": "warning"},
+          Annotations: map[string]string{
+            "summary":     "More than 10% of the upstream API requests fail",
+            "description": "{{ $value | humanizePercentage }} of the upstream API requests failed in the last 15 minutes",
+          },
+        },
+        {
+          Alert: "HelldiversPlanetAboutToFall",
+          Expr: fmt.Sprintf(
+            "predict_linear(%s[1h], 3600) > 0 and %s < 3600",
+            planetDefenseHealthName, planetDefenseRemainingName,
+          ),
+          For:    "5m",
+          Labels: map[string]string{"severity": "critical"},
+          Annotations: map[string]string{
+            "summary":     "{{ $labels.planet }} is about to fall to the {{ $labels.faction }}",
+            "description": "The defense of {{ $labels.planet }} will not be won before it expires at the current pace",
+          },
+        },
+        {
+          Alert:  "HelldiversDefenseNearExpiry",
+          Expr:   fmt.Sprintf("%s < 7200 and %s > 0", planetDefenseRemainingName, planetDefenseHealthName),
+          Labels: map[string]string{"severity": "warning"},
+          Annotations: map[string]string{
+            "summary":     "The defense of {{ $labels.planet }} expires soon",
+            "description": "The defense of {{ $labels.planet }} against the {{ $labels.faction }} expires in {{ $value | humanizeDuration }}",
+          },
+        },
+      },
+    },
+  }}
+}
+
+func writeRules(w io.Writer) error {
+  _, err := io.WriteString(w, "# Generated by `exporter rules`, do not edit.\n")
+  if err != nil {
+    return err
+  }
+  encoder := yaml.NewEncoder(w)
+  encoder.SetIndent(2)
+  err = encoder.Encode(exporterRules())
+  if err != nil {
+    return err
+  }
+  return encoder.Close()
+}
+
+// `exporter rules` subcommand, writes the Prometheus rules file
+func runRules(args []string) error {
+  rulesFlags := pflag.NewFlagSet("rules", pflag.ExitOnError)
+  output := rulesFlags.StringP("output", "o", "-", "Path of the rules file, - for stdout")
+  err := rulesFlags.Parse(args)
+  if err != nil {
+    return err
+  }
+  if *output == "-" {
+    return writeRules(os.Stdout)
+  }
+  file, err := os.Create(*output)
+  if err != nil {
+    return err
+  }
+  defer file.Close()
+  return writeRules(file)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+  "bytes"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+// The committed rules must match the generator, regenerate them with
+// `go run ./cmd/exporter rules -o utils/rules/hde.rules.yml`
+func TestRulesUpToDate(t *testing.T) {
+  expected := &bytes.Buffer{}
+  err := writeRules(expected)
+  if err != nil {
+    t.Fatal(err)
+  }
+  committed, err := os.ReadFile(filepath.Join("..", "..", "utils", "rules", "hde.rules.yml"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if !bytes.Equal(committed, expected.Bytes()) {
+    t.Error("utils/rules/hde.rules.yml is out of date, run `go run ./cmd/exporter rules -o utils/rules/hde.rules.yml`")
+  }
+}
+
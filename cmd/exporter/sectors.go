@@ -0,0 +1,126 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "log/slog"
+  "net/http"
+  "os"
+  "sort"
+  "time"
+
+  "github.com/spf13/pflag"
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Build the sector table: the official war info gives the sector index of
+// every planet, the community API and the planet reference data its name.
+// A sector index named differently by several planets gets its most
+// frequent name, ties broken alphabetically, so the table is deterministic.
+func buildSectorTable(infos []client.PlanetInfo, names map[int32]string) (map[int32]string, []string) {
+  counts := map[int32]map[string]int{}
+  for _, planet := range infos {
+    name := names[planet.Index]
+    if name == "" {
+      continue
+    }
+    if counts[planet.Sector] == nil {
+      counts[planet.Sector] = map[string]int{}
+    }
+    counts[planet.Sector][name]++
+  }
+  sectors := map[int32]string{}
+  conflicts := []string{}
+  for index, candidates := range counts {
+    ranked := make([]string, 0, len(candidates))
+    for name := range candidates {
+      ranked = append(ranked, name)
+    }
+    sort.Slice(ranked, func(i, j int) bool {
+      if candidates[ranked[i]] != candidates[ranked[j]] {
+        return candidates[ranked[i]] > candidates[ranked[j]]
+      }
+      return ranked[i] < ranked[j]
+    })
+    sectors[index] = ranked[0]
+    if len(ranked) > 1 {
+      conflicts = append(conflicts, fmt.Sprintf("sector %d is named %v, using %q", index, ranked, ranked[0]))
+    }
+  }
+  sort.Strings(conflicts)
+  return sectors, conflicts
+}
+
+// `exporter sectors` subcommand, writes the sector table embedded in
+// data/sectors.json from the live APIs
+func runSectors(args []string) error {
+  sectorsFlags := pflag.NewFlagSet("sectors", pflag.ExitOnError)
+  apiURL := sectorsFlags.String("url", viper.GetString("api_url"), "URL of the official API, source of the sector indexes")
+  communityURL := sectorsFlags.String("community-url", viper.GetString("community_api_url"), "URL of the community API, source of the sector names")
+  warID := sectorsFlags.Int("war-id", 801, "War ID of the war info")
+  output := sectorsFlags.StringP("output", "o", "", "Output file, stdout when empty")
+  err := sectorsFlags.Parse(args)
+  if err != nil {
+    return err
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+  defer cancel()
+
+  cl, err := client.NewClientWithResponses(*apiURL)
+  if err != nil {
+    return err
+  }
+  info, err := cl.GetWarSeasonWarIdWarInfoWithResponse(ctx, *warID)
+  if err != nil {
+    return err
+  }
+  if info.StatusCode() != http.StatusOK || info.JSON200 == nil {
+    return fmt.Errorf("failed to fetch the war info: status %d", info.StatusCode())
+  }
+
+  // Sector names of the planet reference data, overridden by the community API
+  err = loadStaticAssets()
+  if err != nil {
+    return err
+  }
+  names := map[int32]string{}
+  for index, planet := range currentStaticData().planets {
+    names[index] = planet.Sector
+  }
+  community := newCommunitySource(*communityURL, viper.GetString("community_client"), viper.GetString("community_contact"), &http.Client{})
+  planets := []communityPlanet{}
+  err = community.get(ctx, "community_planets", "/api/v1/planets", &planets, map[string]json.RawMessage{})
+  if err != nil {
+    return err
+  }
+  for _, planet := range planets {
+    if planet.Sector != "" {
+      names[planet.Index] = planet.Sector
+    }
+  }
+
+  sectors, conflicts := buildSectorTable(info.JSON200.PlanetInfos, names)
+  for _, conflict := range conflicts {
+    slog.Warn("Conflicting sector names", slog.String("conflict", conflict))
+  }
+  content, err := json.MarshalIndent(sectors, "", "  ")
+  if err != nil {
+    return err
+  }
+  content = append(content, '\n')
+  var out io.Writer = os.Stdout
+  if *output != "" {
+    file, err := os.Create(*output)
+    if err != nil {
+      return err
+    }
+    defer file.Close()
+    out = file
+  }
+  _, err = out.Write(content)
+  return err
+}
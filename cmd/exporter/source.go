@@ -0,0 +1,225 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "time"
+
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+  "github.com/Xide/helldivers2-dashboard/pkg/contract"
+  "github.com/Xide/helldivers2-dashboard/pkg/recorder"
+)
+
+// WarSnapshot is the exporter's internal view of the war at a given time.
+// Every source converts its upstream payloads into this shape, so the
+// metrics computed by scrape() do not depend on where the data came from.
+type WarSnapshot struct {
+  Status      *client.WarSeasonStatus
+  Info        *client.WarSeasonInfo
+  Stats       *client.WarStatistics
+  // Nil when the assignments could not be fetched
+  Assignments []client.Assignment
+  // Sector names by planet index, from the sources that only know the
+  // names of the sectors and not their index (community API)
+  SectorNames map[int32]string
+  // Raw bodies of the upstream responses the snapshot was decoded from,
+  // by route, served by /debug/snapshot
+  Responses map[string]json.RawMessage
+}
+
+// Source produces war snapshots from an upstream API
+type Source interface {
+  // Name of the source, used in logs
+  Name() string
+  // Fetch a full snapshot of the war
+  Fetch(ctx context.Context) (*WarSnapshot, error)
+}
+
+// Recording of the upstream responses of the source, closed on shutdown
+var recording *recorder.Doer
+
+// HTTP client of the upstream APIs, recording the responses when record_dir is set
+func newHTTPDoer() (client.HttpRequestDoer, error) {
+  doer, err := recorder.Wrap(&http.Client{}, recorder.Options{
+    Dir:     viper.GetString("record_dir"),
+    Prefix:  "exporter",
+    MaxSize: viper.GetInt64("record_max_size_mb") * 1024 * 1024,
+    MaxAge:  viper.GetDuration("record_max_age"),
+  })
+  if err != nil {
+    return nil, err
+  }
+  recording = doer
+  return doer, nil
+}
+
+// Build the source selected by the `source` configuration key
+func newSource() (Source, error) {
+  switch viper.GetString("source") {
+  case "official":
+    doer, err := newHTTPDoer()
+    if err != nil {
+      return nil, err
+    }
+    doer, err = contract.Wrap(doer, contract.Options{
+      Validate: viper.GetBool("validate_responses"),
+      Drift:    schemaDrift,
+    })
+    if err != nil {
+      return nil, err
+    }
+    cl, err := client.NewClientWithResponses(
+      viper.GetString("api_url"),
+      client.WithHTTPClient(doer),
+      client.WithRequestEditorFn(setRequestIDHeader),
+    )
+    if err != nil {
+      return nil, err
+    }
+    return &officialSource{client: cl, warID: 801}, nil
+  case "community":
+    doer, err := newHTTPDoer()
+    if err != nil {
+      return nil, err
+    }
+    return newCommunitySource(
+      viper.GetString("community_api_url"),
+      viper.GetString("community_client"),
+      viper.GetString("community_contact"),
+      doer,
+    ), nil
+  case "replay":
+    return newConfiguredReplaySource()
+  default:
+    return nil, fmt.Errorf("unknown source %q", viper.GetString("source"))
+  }
+}
+
+// officialSource reads the war from the official Helldivers 2 API,
+// through the generated client
+type officialSource struct {
+  client client.ClientWithResponsesInterface
+  warID  int
+}
+
+func (s *officialSource) Name() string {
+  return "official"
+}
+
+// Fetch information from the 4 main endpoints:
+// * Current war status (e.g. planet health, players, regen rate)
+// * War info (e.g. max health of the planets)
+// * War statistics (e.g. missions won, time played, etc.)
+// * Assignments (e.g. major orders)
+// Fills prometheus histograms for HTTP queries
+func (s *officialSource) Fetch(ctx context.Context) (*WarSnapshot, error) {
+  reqCtx, requestID := withRequestID(ctx)
+  tStart := time.Now()
+  warStatus, err := s.client.GetWarSeasonWarIdStatusWithResponse(reqCtx, s.warID)
+  tEnd := time.Now()
+  observeAPIRequestDuration("war_status", tEnd.Sub(tStart).Seconds(), requestID)
+  if err != nil {
+    countAPIRequest("war_status", apiRequestErrorStatus)
+    scraper.recordRequest("war_status", requestID, tEnd.Sub(tStart), 0, err)
+    slog.Error("Error fetching war status", slog.String("request_id", requestID), slog.Any("error", err))
+    return nil, err
+  }
+  slog.Info("Fetched war status", slog.String("request_id", requestID), slog.Int("code", warStatus.StatusCode()), slog.Duration("duration", tEnd.Sub(tStart)))
+  countAPIRequest("war_status", fmt.Sprintf("%d", warStatus.StatusCode()))
+  scraper.recordRequest("war_status", requestID, tEnd.Sub(tStart), warStatus.StatusCode(), nil)
+  if warStatus.StatusCode() != 200 {
+    slog.Error("Error code while fetching war status", slog.Int("code", warStatus.StatusCode()))
+    return nil, fmt.Errorf("Error fetching war status")
+  }
+
+  reqCtx, requestID = withRequestID(ctx)
+  tStart = time.Now()
+  warInfo, err := s.client.GetWarSeasonWarIdWarInfoWithResponse(reqCtx, s.warID)
+  tEnd = time.Now()
+  observeAPIRequestDuration("war_info", tEnd.Sub(tStart).Seconds(), requestID)
+  if err != nil {
+    countAPIRequest("war_info", apiRequestErrorStatus)
+    scraper.recordRequest("war_info", requestID, tEnd.Sub(tStart), 0, err)
+    slog.Error("Error fetching war info", slog.String("request_id", requestID), slog.Any("error", err))
+    return nil, err
+  }
+  slog.Info("Fetched war info", slog.String("request_id", requestID), slog.Int("code", warInfo.StatusCode()), slog.Duration("duration", tEnd.Sub(tStart)))
+  countAPIRequest("war_info", fmt.Sprintf("%d", warInfo.StatusCode()))
+  scraper.recordRequest("war_info", requestID, tEnd.Sub(tStart), warInfo.StatusCode(), nil)
+  if warInfo.StatusCode() != 200 {
+    slog.Error("Error code while fetching war info", slog.Int("code", warInfo.StatusCode()))
+    return nil, fmt.Errorf("Error fetching war info")
+  }
+
+  reqCtx, requestID = withRequestID(ctx)
+  tStart = time.Now()
+  warStats, err := s.client.GetStatsWarWarIdSummaryWithResponse(reqCtx, s.warID)
+  tEnd = time.Now()
+  observeAPIRequestDuration("war_stats", tEnd.Sub(tStart).Seconds(), requestID)
+  if err != nil {
+    countAPIRequest("war_stats", apiRequestErrorStatus)
+    scraper.recordRequest("war_stats", requestID, tEnd.Sub(tStart), 0, err)
+    slog.Error("Error fetching war stats", slog.String("request_id", requestID), slog.Any("error", err))
+    return nil, err
+  }
+  slog.Info("Fetched war stats", slog.String("request_id", requestID), slog.Int("code", warStats.StatusCode()), slog.Duration("duration", tEnd.Sub(tStart)))
+  countAPIRequest("war_stats", fmt.Sprintf("%d", warStats.StatusCode()))
+  scraper.recordRequest("war_stats", requestID, tEnd.Sub(tStart), warStats.StatusCode(), nil)
+  if warStats.StatusCode() != 200 {
+    slog.Error("Error code while fetching war stats", slog.Int("code", warStats.StatusCode()))
+    return nil, fmt.Errorf("Error fetching war stats")
+  }
+
+  if warStatus.JSON200 == nil || warInfo.JSON200 == nil || warStats.JSON200 == nil {
+    slog.Error("Unexpected content type of the war responses")
+    return nil, fmt.Errorf("Error decoding the war responses")
+  }
+
+  responses := map[string]json.RawMessage{
+    "war_status": warStatus.Body,
+    "war_info":   warInfo.Body,
+    "war_stats":  warStats.Body,
+  }
+  return &WarSnapshot{
+    Status:      warStatus.JSON200,
+    Info:        warInfo.JSON200,
+    Stats:       warStats.JSON200,
+    Assignments: s.fetchAssignments(ctx, responses),
+    Responses:   responses,
+  }, nil
+}
+
+// Fetch the assignments, nil when they are not available: the war metrics do
+// not depend on them, a failure only skips the major order events of the scrape.
+// The raw body of the response is kept in `responses`.
+func (s *officialSource) fetchAssignments(ctx context.Context, responses map[string]json.RawMessage) []client.Assignment {
+  reqCtx, requestID := withRequestID(ctx)
+  tStart := time.Now()
+  assignments, err := s.client.GetV2AssignmentWarWarIdWithResponse(reqCtx, s.warID)
+  tEnd := time.Now()
+  observeAPIRequestDuration("assignments", tEnd.Sub(tStart).Seconds(), requestID)
+  if err != nil {
+    countAPIRequest("assignments", apiRequestErrorStatus)
+    scraper.recordRequest("assignments", requestID, tEnd.Sub(tStart), 0, err)
+    slog.Error("Error fetching assignments", slog.String("request_id", requestID), slog.Any("error", err))
+    return nil
+  }
+  slog.Info("Fetched assignments", slog.String("request_id", requestID), slog.Int("code", assignments.StatusCode()), slog.Duration("duration", tEnd.Sub(tStart)))
+  countAPIRequest("assignments", fmt.Sprintf("%d", assignments.StatusCode()))
+  scraper.recordRequest("assignments", requestID, tEnd.Sub(tStart), assignments.StatusCode(), nil)
+  if assignments.StatusCode() != 200 {
+    slog.Error("Error code while fetching assignments", slog.Int("code", assignments.StatusCode()))
+    return nil
+  }
+  if assignments.JSON200 == nil {
+    slog.Error("Unexpected content type of the assignments", slog.String("content_type", assignments.HTTPResponse.Header.Get("Content-Type")))
+    return nil
+  }
+  responses["assignments"] = assignments.Body
+  return *assignments.JSON200
+}
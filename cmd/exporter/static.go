@@ -0,0 +1,309 @@
+package main
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/fs"
+  "log/slog"
+  "os"
+  "path"
+  "path/filepath"
+  "strconv"
+  "sync"
+  "sync/atomic"
+  "time"
+
+  "github.com/fsnotify/fsnotify"
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/data"
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Static data files, watched in json_data_dir
+var staticDataFiles = []string{"planets.json", "sectors.json"}
+
+// Static reference data.
+// Swapped atomically when the files are reloaded, never mutated in place.
+type staticData struct {
+  // Planet reference data, indexed by planet index
+  planets map[int32]data.Planet
+  // Planet names, indexed by planet index
+  names map[int32]string
+  // Sector names, indexed by sector index (`PlanetInfo.Sector`)
+  sectors map[int32]string
+  // Short hash of the reference data, changes whenever the data does
+  version string
+}
+
+func newStaticData(planets map[int32]data.Planet, sectors map[int32]string) *staticData {
+  names := map[int32]string{}
+  for index, planet := range planets {
+    names[index] = planet.Name
+  }
+  // Maps are marshalled with sorted keys, so the hash is stable
+  content, _ := json.Marshal(struct {
+    Planets map[int32]data.Planet `json:"planets"`
+    Sectors map[int32]string          `json:"sectors"`
+  }{planets, sectors})
+  hash := sha256.Sum256(content)
+  return &staticData{
+    planets: planets,
+    names:   names,
+    sectors: sectors,
+    version: hex.EncodeToString(hash[:6]),
+  }
+}
+
+// Resolve the name of a sector index.
+// Falls back to the sector name of the planet reference data, then to the index.
+func (d *staticData) sectorName(index int32, planet data.Planet) string {
+  if name, ok := d.sectors[index]; ok {
+    return name
+  }
+  if planet.Sector != "" {
+    return planet.Sector
+  }
+  return strconv.Itoa(int(index))
+}
+
+// Resolve the sector name of a planet of a snapshot, preferring the name
+// given by the source
+func (d *staticData) planetSector(snapshot *WarSnapshot, planet client.PlanetInfo) string {
+  if name, ok := snapshot.SectorNames[planet.Index]; ok && name != "" {
+    return name
+  }
+  return d.sectorName(planet.Sector, d.planets[planet.Index])
+}
+
+var staticDataTable atomic.Pointer[staticData]
+
+// Serializes reloads, so renamed planets are computed against the table being replaced
+var staticDataReload sync.Mutex
+
+// Current static data
+func currentStaticData() *staticData {
+  loaded := staticDataTable.Load()
+  if loaded == nil {
+    return newStaticData(map[int32]data.Planet{}, map[int32]string{})
+  }
+  return loaded
+}
+
+// Current planet names table
+func currentPlanetNames() map[int32]string {
+  return currentStaticData().names
+}
+
+// Decode the sector names embedded in the binary
+func embeddedSectors() (map[int32]string, error) {
+  sectors := map[int32]string{}
+  err := json.Unmarshal(data.Sectors, &sectors)
+  if err != nil {
+    return nil, err
+  }
+  return sectors, nil
+}
+
+// Static data embedded in the binary, without the files of json_data_dir
+func embeddedStaticData() (*staticData, error) {
+  planets, err := data.EmbeddedPlanets()
+  if err != nil {
+    return nil, fmt.Errorf("failed to unmarshal embedded planets: %w", err)
+  }
+  sectors, err := embeddedSectors()
+  if err != nil {
+    return nil, fmt.Errorf("failed to unmarshal embedded sectors: %w", err)
+  }
+  return newStaticData(planets, sectors), nil
+}
+
+// Decode json_data_dir/`name` into `out`
+// Returns false if the file does not exist
+func readStaticFile(name string, out interface{}) (bool, error) {
+  content, err := os.ReadFile(path.Join(viper.GetString("json_data_dir"), name))
+  if errors.Is(err, fs.ErrNotExist) {
+    return false, nil
+  }
+  if err != nil {
+    return false, err
+  }
+  return true, json.Unmarshal(content, out)
+}
+
+// Planet reference data: the embedded defaults, with planets.json merged on top
+func loadPlanets(embedded map[int32]data.Planet) (map[int32]data.Planet, error) {
+  overrides := map[int32]data.Planet{}
+  found, err := readStaticFile("planets.json", &overrides)
+  if err != nil {
+    return nil, err
+  }
+  if !found {
+    slog.Debug("No planets override", slog.String("dir", viper.GetString("json_data_dir")))
+  }
+  planets := make(map[int32]data.Planet, len(embedded))
+  for index, planet := range embedded {
+    planets[index] = planet
+  }
+  for index, planet := range overrides {
+    planets[index] = planets[index].Merge(planet)
+  }
+  return planets, nil
+}
+
+// Sector names: the embedded defaults, with sectors.json merged on top
+func loadSectors(embedded map[int32]string) (map[int32]string, error) {
+  overrides := map[int32]string{}
+  _, err := readStaticFile("sectors.json", &overrides)
+  if err != nil {
+    return nil, err
+  }
+  sectors := make(map[int32]string, len(embedded))
+  for index, name := range embedded {
+    sectors[index] = name
+  }
+  for index, name := range overrides {
+    sectors[index] = name
+  }
+  return sectors, nil
+}
+
+// Build the static data from the embedded defaults, with the files of
+// json_data_dir merged on top when they exist. Files are loaded
+// independently: an invalid file keeps its part of `current`.
+// Returns the errors of the files that failed to load, by file name.
+func buildStaticData(embedded *staticData, current *staticData) (*staticData, map[string]error) {
+  failed := map[string]error{}
+  planets, err := loadPlanets(embedded.planets)
+  if err != nil {
+    failed["planets.json"] = err
+    planets = current.planets
+  }
+  sectors, err := loadSectors(embedded.sectors)
+  if err != nil {
+    failed["sectors.json"] = err
+    sectors = current.sectors
+  }
+  return newStaticData(planets, sectors), failed
+}
+
+// Record the result of a load of every static data file
+func recordStaticDataLoaded(failed map[string]error, message string) {
+  for _, file := range staticDataFiles {
+    if err, ok := failed[file]; ok {
+      slog.Error(message, slog.String("file", file), slog.Any("error", err))
+      staticDataReloads.WithLabelValues(file, "failure").Inc()
+      continue
+    }
+    staticDataReloads.WithLabelValues(file, "success").Inc()
+    staticDataLastReload.WithLabelValues(file).SetToCurrentTime()
+  }
+}
+
+// Load the static assets (planets and sectors reference data), from the
+// embedded defaults and the optional json files on disk.
+// An invalid file is reported and replaced by its embedded defaults, only
+// broken embedded data returns an error.
+func loadStaticAssets() error {
+  slog.Info("Loading static assets")
+  embedded, err := embeddedStaticData()
+  if err != nil {
+    slog.Error("Failed to load the embedded static assets", slog.Any("error", err))
+    return err
+  }
+  loaded, failed := buildStaticData(embedded, embedded)
+  recordStaticDataLoaded(failed, "Error loading static assets, using embedded defaults")
+  staticDataTable.Store(loaded)
+  slog.Info("Loaded static assets", slog.Int("planets", len(loaded.planets)), slog.Int("sectors", len(loaded.sectors)), slog.String("version", loaded.version))
+  return nil
+}
+
+// Reload the static assets after a change on disk.
+// An invalid file is rejected and its current data is kept,
+// removing a file falls back to the embedded defaults.
+func reloadStaticAssets() {
+  staticDataReload.Lock()
+  defer staticDataReload.Unlock()
+  embedded, err := embeddedStaticData()
+  if err != nil {
+    slog.Error("Failed to load the embedded static assets", slog.Any("error", err))
+    return
+  }
+  current := currentStaticData()
+  loaded, failed := buildStaticData(embedded, current)
+  recordStaticDataLoaded(failed, "Failed to reload static assets, keeping the current data")
+  staticDataTable.Store(loaded)
+  forgetRenamedPlanets(current.names, loaded.names)
+  slog.Info("Reloaded static assets", slog.Int("planets", len(loaded.planets)), slog.Int("sectors", len(loaded.sectors)), slog.String("version", loaded.version))
+}
+
+// Drop the series of planets whose name changed or disappeared,
+// the next scrape recreates them under the new name
+func forgetRenamedPlanets(previous map[int32]string, next map[int32]string) {
+  for index, name := range previous {
+    if next[index] == name {
+      continue
+    }
+    slog.Info("Planet renamed", slog.Int("planet_id", int(index)), slog.String("from", name), slog.String("to", next[index]))
+    for _, vec := range planetVecs {
+      vec.DeletePartialMatch(prometheus.Labels{"planet": name})
+    }
+  }
+}
+
+// Is `name` one of the static data files
+func isStaticDataFile(name string) bool {
+  for _, file := range staticDataFiles {
+    if name == file {
+      return true
+    }
+  }
+  return false
+}
+
+// Watch the static data directory and reload the static data when it changes.
+// The directory is watched rather than the files, so files replaced by a
+// rename (editors, kubernetes config maps, ...) are still picked up.
+// Started as a goroutine
+func watchStaticAssets() {
+  watcher, err := fsnotify.NewWatcher()
+  if err != nil {
+    slog.Error("Failed to create static assets watcher", slog.Any("error", err))
+    return
+  }
+  defer watcher.Close()
+  dir := viper.GetString("json_data_dir")
+  err = watcher.Add(dir)
+  if err != nil {
+    slog.Warn("Not watching static assets, embedded defaults will be used", slog.String("dir", dir), slog.Any("error", err))
+    return
+  }
+  slog.Info("Watching static assets", slog.String("dir", dir))
+
+  // Writes usually come as bursts of events, wait for the files to settle
+  var debounce *time.Timer
+  for {
+    select {
+    case event, ok := <-watcher.Events:
+      if !ok {
+        return
+      }
+      if !isStaticDataFile(filepath.Base(event.Name)) || event.Op == fsnotify.Chmod {
+        continue
+      }
+      if debounce != nil {
+        debounce.Stop()
+      }
+      debounce = time.AfterFunc(200*time.Millisecond, reloadStaticAssets)
+    case err, ok := <-watcher.Errors:
+      if !ok {
+        return
+      }
+      slog.Error("Static assets watcher error", slog.Any("error", err))
+    }
+  }
+}
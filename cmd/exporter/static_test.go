@@ -0,0 +1,216 @@
+package main
+
+import (
+  "encoding/json"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/data"
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+func TestEmbeddedStaticData(t *testing.T) {
+  embedded, err := embeddedStaticData()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if embedded.names[0] != "Super Earth" {
+    t.Errorf("name of planet 0: got %q, want Super Earth", embedded.names[0])
+  }
+  // The shipped planets are objects, the legacy format is only read from overrides
+  raw := map[string]json.RawMessage{}
+  err = json.Unmarshal(data.Planets, &raw)
+  if err != nil {
+    t.Fatal(err)
+  }
+  for index, entry := range raw {
+    if !strings.HasPrefix(string(entry), "{") {
+      t.Errorf("planet %s: got %s, want an object", index, entry)
+    }
+  }
+}
+
+// A planets.json override in the legacy format, a flat map of names
+func TestLegacyPlanetsFile(t *testing.T) {
+  legacy, err := os.ReadFile(filepath.Join("testdata", "planets_legacy.json"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  names := map[int32]string{}
+  err = json.Unmarshal(legacy, &names)
+  if err != nil {
+    t.Fatal(err)
+  }
+  dir := t.TempDir()
+  viper.Set("json_data_dir", dir)
+  err = os.WriteFile(filepath.Join(dir, "planets.json"), legacy, 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  embedded := newStaticData(map[int32]data.Planet{
+    0: {Name: "Earth", Sector: "Sol", Biome: "Ocean"},
+  }, map[int32]string{})
+  loaded, failed := buildStaticData(embedded, embedded)
+  if len(failed) != 0 {
+    t.Fatalf("failed files: %v", failed)
+  }
+  if !reflect.DeepEqual(loaded.names, names) {
+    t.Errorf("names: got %v, want %v", loaded.names, names)
+  }
+  // Legacy entries only rename the planets
+  if planet := loaded.planets[0]; !reflect.DeepEqual(planet, data.Planet{Name: "Super Earth", Sector: "Sol", Biome: "Ocean"}) {
+    t.Errorf("planet 0: got %+v", planet)
+  }
+}
+
+func TestBuildStaticData(t *testing.T) {
+  embedded := newStaticData(map[int32]data.Planet{
+    0: {Name: "Super Earth", Sector: "Sol"},
+    1: {Name: "Klen Dahth II", Hazards: []string{"Rainstorms"}},
+  }, map[int32]string{0: "Sol"})
+  current := newStaticData(map[int32]data.Planet{0: {Name: "Current"}}, map[int32]string{0: "Current"})
+
+  tests := []struct {
+    name    string
+    files   map[string]string
+    planets map[int32]data.Planet
+    sectors map[int32]string
+    failed  []string
+  }{
+    {
+      name:    "embedded only",
+      files:   map[string]string{},
+      planets: embedded.planets,
+      sectors: embedded.sectors,
+    },
+    {
+      name: "overrides merged",
+      files: map[string]string{
+        "planets.json": `{"1": {"name": "Klen Dahth II", "biome": "Rainforest"}, "2": "Pathfinder V"}`,
+        "sectors.json": `{"3": "Altus"}`,
+      },
+      planets: map[int32]data.Planet{
+        0: {Name: "Super Earth", Sector: "Sol"},
+        1: {Name: "Klen Dahth II", Biome: "Rainforest", Hazards: []string{"Rainstorms"}},
+        2: {Name: "Pathfinder V"},
+      },
+      sectors: map[int32]string{0: "Sol", 3: "Altus"},
+    },
+    {
+      name: "invalid planets keep the sectors",
+      files: map[string]string{
+        "planets.json": `{"1": `,
+        "sectors.json": `{"3": "Altus"}`,
+      },
+      planets: current.planets,
+      sectors: map[int32]string{0: "Sol", 3: "Altus"},
+      failed:  []string{"planets.json"},
+    },
+    {
+      name: "invalid sectors keep the planets",
+      files: map[string]string{
+        "planets.json": `{"2": "Pathfinder V"}`,
+        "sectors.json": `["Sol"]`,
+      },
+      planets: map[int32]data.Planet{
+        0: {Name: "Super Earth", Sector: "Sol"},
+        1: {Name: "Klen Dahth II", Hazards: []string{"Rainstorms"}},
+        2: {Name: "Pathfinder V"},
+      },
+      sectors: current.sectors,
+      failed:  []string{"sectors.json"},
+    },
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      dir := t.TempDir()
+      viper.Set("json_data_dir", dir)
+      for name, content := range test.files {
+        err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+        if err != nil {
+          t.Fatal(err)
+        }
+      }
+      loaded, failed := buildStaticData(embedded, current)
+      if !reflect.DeepEqual(loaded.planets, test.planets) {
+        t.Errorf("planets: got %+v, want %+v", loaded.planets, test.planets)
+      }
+      if !reflect.DeepEqual(loaded.sectors, test.sectors) {
+        t.Errorf("sectors: got %+v, want %+v", loaded.sectors, test.sectors)
+      }
+      if len(failed) != len(test.failed) {
+        t.Errorf("failed files: got %v, want %v", failed, test.failed)
+      }
+      for _, file := range test.failed {
+        if failed[file] == nil {
+          t.Errorf("%s did not fail", file)
+        }
+      }
+    })
+  }
+  // Overrides never modify the embedded data
+  if embedded.planets[1].Biome != "" {
+    t.Errorf("embedded planet 1 modified: %+v", embedded.planets[1])
+  }
+}
+
+// An invalid file rejected by a reload keeps its current data, the other
+// files are reloaded
+func TestReloadStaticAssets(t *testing.T) {
+  dir := t.TempDir()
+  viper.Set("json_data_dir", dir)
+  write := func(name string, content string) {
+    err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  write("planets.json", `{"0": "Renamed Earth"}`)
+  err := loadStaticAssets()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if name := currentStaticData().names[0]; name != "Renamed Earth" {
+    t.Fatalf("name of planet 0: got %q, want Renamed Earth", name)
+  }
+
+  write("planets.json", `{"0": `)
+  write("sectors.json", `{"0": "Sol"}`)
+  reloadStaticAssets()
+  loaded := currentStaticData()
+  if name := loaded.names[0]; name != "Renamed Earth" {
+    t.Errorf("name of planet 0 after an invalid reload: got %q, want Renamed Earth", name)
+  }
+  if sector := loaded.sectorName(0, data.Planet{}); sector != "Sol" {
+    t.Errorf("sector 0: got %q, want Sol", sector)
+  }
+}
+
+func TestBuildSectorTable(t *testing.T) {
+  infos := []client.PlanetInfo{
+    {Index: 0, Sector: 0},
+    {Index: 1, Sector: 1},
+    {Index: 2, Sector: 1},
+    {Index: 3, Sector: 1},
+    {Index: 4, Sector: 2},
+    {Index: 5, Sector: 2},
+    {Index: 6, Sector: 3},
+  }
+  names := map[int32]string{0: "Sol", 1: "Altus", 2: "Altus", 3: "Barnard", 4: "Cancri", 5: "Borgus"}
+  want := map[int32]string{0: "Sol", 1: "Altus", 2: "Borgus"}
+  // Ties are broken alphabetically, whatever the map order
+  for i := 0; i < 20; i++ {
+    sectors, conflicts := buildSectorTable(infos, names)
+    if !reflect.DeepEqual(sectors, want) {
+      t.Fatalf("got %v, want %v", sectors, want)
+    }
+    if len(conflicts) != 2 {
+      t.Fatalf("got conflicts %v, want 2", conflicts)
+    }
+  }
+}
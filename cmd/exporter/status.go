@@ -0,0 +1,268 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "html/template"
+  "log/slog"
+  "net/http"
+  "net/http/pprof"
+  "net/url"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/spf13/viper"
+)
+
+// Result of the last request to an upstream route
+type routeResult struct {
+  Time      time.Time
+  Duration  time.Duration
+  RequestID string
+  // HTTP status code, 0 when the request failed without a response
+  Code  int
+  Error string
+}
+
+// State of the scraper, shown by the landing page and the debug endpoints
+type scraperState struct {
+  mu     sync.RWMutex
+  source string
+  routes map[string]routeResult
+  // Last scrape, successful or not
+  lastScrape time.Time
+  lastError  string
+  // Last successfully fetched snapshot, and when it was fetched
+  snapshot     *WarSnapshot
+  snapshotTime time.Time
+}
+
+var scraper = &scraperState{routes: map[string]routeResult{}}
+
+func (s *scraperState) setSource(name string) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.source = name
+}
+
+// Record the result of an upstream API request
+func (s *scraperState) recordRequest(route string, requestID string, duration time.Duration, code int, err error) {
+  result := routeResult{Time: time.Now(), Duration: duration, RequestID: requestID, Code: code}
+  if err != nil {
+    result.Error = err.Error()
+  }
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.routes[route] = result
+}
+
+// Record the result of a scrape, and its snapshot when it succeeded
+func (s *scraperState) recordScrape(snapshot *WarSnapshot, err error) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.lastScrape = time.Now()
+  if err != nil {
+    s.lastError = err.Error()
+    return
+  }
+  s.lastError = ""
+  s.snapshot = snapshot
+  s.snapshotTime = s.lastScrape
+}
+
+// Configuration keys whose value is never shown
+var sensitiveConfigKeys = []string{"contact", "password", "secret", "token"}
+
+// Hide secrets from a configuration value: sensitive keys are masked,
+// and credentials are removed from URLs
+func redactConfig(key string, value string) string {
+  for _, sensitive := range sensitiveConfigKeys {
+    if strings.Contains(key, sensitive) && value != "" {
+      return "<redacted>"
+    }
+  }
+  if u, err := url.Parse(value); err == nil && u.User != nil {
+    return u.Redacted()
+  }
+  return value
+}
+
+type configEntry struct {
+  Key   string
+  Value string
+}
+
+// Effective configuration of the exporter, redacted and sorted by key
+func effectiveConfig() []configEntry {
+  keys := viper.AllKeys()
+  sort.Strings(keys)
+  entries := make([]configEntry, 0, len(keys))
+  for _, key := range keys {
+    entries = append(entries, configEntry{Key: key, Value: redactConfig(key, fmt.Sprint(viper.Get(key)))})
+  }
+  return entries
+}
+
+var landingTemplate = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <title>Helldivers 2 exporter</title>
+  <style>
+    body { font-family: sans-serif; margin: 2em; }
+    table { border-collapse: collapse; }
+    td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+    .error { color: #c00; }
+  </style>
+</head>
+<body>
+  <h1>Helldivers 2 exporter</h1>
+  <h2>Endpoints</h2>
+  <ul>
+    {{- range .Endpoints }}
+    <li><a href="{{ .Path }}">{{ .Path }}</a>: {{ .Description }}</li>
+    {{- end }}
+  </ul>
+  <h2>War</h2>
+  <table>
+    <tr><th>Source</th><td>{{ .Source }}</td></tr>
+    <tr><th>War ID</th><td>{{ if .WarID }}{{ .WarID }}{{ else }}unknown{{ end }}</td></tr>
+    <tr><th>Planet data version</th><td>{{ .PlanetDataVersion }}</td></tr>
+    <tr><th>Last scrape</th><td>{{ if .LastScrape.IsZero }}never{{ else }}{{ .LastScrape.Format "2006-01-02T15:04:05Z07:00" }}{{ end }}</td></tr>
+    {{- if .LastError }}
+    <tr><th>Last error</th><td class="error">{{ .LastError }}</td></tr>
+    {{- end }}
+  </table>
+  <h2>Upstream routes</h2>
+  <table>
+    <tr><th>Route</th><th>Time</th><th>Status</th><th>Duration</th><th>Request ID</th></tr>
+    {{- range .Routes }}
+    <tr>
+      <td>{{ .Route }}</td>
+      <td>{{ .Time.Format "2006-01-02T15:04:05Z07:00" }}</td>
+      {{- if .Error }}
+      <td class="error">{{ .Error }}</td>
+      {{- else }}
+      <td>{{ .Code }}</td>
+      {{- end }}
+      <td>{{ .Duration }}</td>
+      <td>{{ .RequestID }}</td>
+    </tr>
+    {{- end }}
+  </table>
+  <h2>Configuration</h2>
+  <table>
+    {{- range .Config }}
+    <tr><th>{{ .Key }}</th><td>{{ .Value }}</td></tr>
+    {{- end }}
+  </table>
+</body>
+</html>
+`))
+
+type landingEndpoint struct {
+  Path        string
+  Description string
+}
+
+type landingRoute struct {
+  Route string
+  routeResult
+}
+
+// Landing page at /, links to the endpoints and shows the state of the exporter
+type landingPage struct {
+  endpoints []landingEndpoint
+}
+
+func (p *landingPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  scraper.mu.RLock()
+  data := struct {
+    Endpoints         []landingEndpoint
+    Source            string
+    WarID             int32
+    PlanetDataVersion string
+    LastScrape        time.Time
+    LastError         string
+    Routes            []landingRoute
+    Config            []configEntry
+  }{
+    Endpoints:         p.endpoints,
+    Source:            scraper.source,
+    PlanetDataVersion: currentStaticData().version,
+    LastScrape:        scraper.lastScrape,
+    LastError:         scraper.lastError,
+    Config:            effectiveConfig(),
+  }
+  if scraper.snapshot != nil && scraper.snapshot.Status != nil {
+    data.WarID = scraper.snapshot.Status.WarId
+  }
+  for route, result := range scraper.routes {
+    data.Routes = append(data.Routes, landingRoute{Route: route, routeResult: result})
+  }
+  scraper.mu.RUnlock()
+  sort.Slice(data.Routes, func(i, j int) bool {
+    return data.Routes[i].Route < data.Routes[j].Route
+  })
+
+  w.Header().Set("Content-Type", "text/html; charset=utf-8")
+  err := landingTemplate.Execute(w, data)
+  if err != nil {
+    slog.Error("Failed to render the landing page", slog.Any("error", err))
+  }
+}
+
+// Dump the raw upstream response bodies of the last successful scrape, by route
+func serveSnapshot(w http.ResponseWriter, r *http.Request) {
+  scraper.mu.RLock()
+  snapshot, fetched := scraper.snapshot, scraper.snapshotTime
+  scraper.mu.RUnlock()
+  if snapshot == nil {
+    http.Error(w, "no snapshot fetched yet", http.StatusServiceUnavailable)
+    return
+  }
+  w.Header().Set("Content-Type", "application/json")
+  encoder := json.NewEncoder(w)
+  encoder.SetIndent("", "  ")
+  err := encoder.Encode(map[string]interface{}{
+    "time":      fetched,
+    "responses": snapshot.Responses,
+  })
+  if err != nil {
+    slog.Error("Failed to encode the snapshot", slog.Any("error", err))
+  }
+}
+
+// Register the landing page and the exporter endpoints on `mux`.
+// The profiling and snapshot endpoints are only registered when `debug` is set,
+// they expose internals of the exporter and should not be public.
+// The `step` handler of the step replay mode is registered when set.
+func registerHandlers(mux *http.ServeMux, metrics http.Handler, events http.Handler, schema http.Handler, step http.Handler, debug bool) {
+  landing := &landingPage{endpoints: []landingEndpoint{
+    {"/metrics", "Prometheus metrics"},
+    {"/events", "Recent war events, as JSON"},
+    {"/schema", "Shapes of the upstream fields missing from spec.yaml, as JSON"},
+  }}
+  mux.Handle("/metrics", metrics)
+  mux.Handle("/events", events)
+  mux.Handle("/schema", schema)
+  if step != nil {
+    landing.endpoints = append(landing.endpoints, landingEndpoint{"/replay/step", "Replay the next recorded scrape (POST), in the step replay mode"})
+    mux.Handle("/replay/step", step)
+  }
+  if debug {
+    landing.endpoints = append(landing.endpoints,
+      landingEndpoint{"/debug/pprof/", "Go runtime profiles"},
+      landingEndpoint{"/debug/snapshot", "Raw upstream responses of the last successful scrape, as JSON"},
+    )
+    mux.HandleFunc("/debug/pprof/", pprof.Index)
+    mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+    mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+    mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+    mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+    mux.HandleFunc("/debug/snapshot", serveSnapshot)
+  }
+  // Only the root, other unknown paths are still not found
+  mux.Handle("/{$}", landing)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "testing"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// The snapshot endpoint serves the upstream bodies as received, with the
+// fields the client does not know about
+func TestDebugSnapshot(t *testing.T) {
+  bodies := map[string]string{
+    "/api/WarSeason/801/Status":  `{"warId":801,"time":1200,"undocumentedField":[1,2]}`,
+    "/api/WarSeason/801/WarInfo": `{"warId":801,"planetInfos":[]}`,
+    "/api/Stats/war/801/summary": `{"galaxy_stats":{"missionsWon":12}}`,
+    "/api/v2/Assignment/War/801": `[]`,
+  }
+  api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    body, ok := bodies[r.URL.Path]
+    if !ok {
+      http.NotFound(w, r)
+      return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write([]byte(body))
+  }))
+  t.Cleanup(api.Close)
+  cl, err := client.NewClientWithResponses(api.URL + "/api")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  scraper.mu.Lock()
+  scraper.snapshot = nil
+  scraper.mu.Unlock()
+  rec := httptest.NewRecorder()
+  serveSnapshot(rec, httptest.NewRequest(http.MethodGet, "/debug/snapshot", nil))
+  if rec.Code != http.StatusServiceUnavailable {
+    t.Errorf("before the first scrape: got status %d, want 503", rec.Code)
+  }
+
+  snapshot, err := (&officialSource{client: cl, warID: 801}).Fetch(context.Background())
+  if err != nil {
+    t.Fatal(err)
+  }
+  scraper.recordScrape(snapshot, nil)
+  rec = httptest.NewRecorder()
+  serveSnapshot(rec, httptest.NewRequest(http.MethodGet, "/debug/snapshot", nil))
+  served := struct {
+    Responses map[string]json.RawMessage `json:"responses"`
+  }{}
+  err = json.Unmarshal(rec.Body.Bytes(), &served)
+  if err != nil {
+    t.Fatal(err)
+  }
+  routes := map[string]string{
+    "war_status":  "/api/WarSeason/801/Status",
+    "war_info":    "/api/WarSeason/801/WarInfo",
+    "war_stats":   "/api/Stats/war/801/summary",
+    "assignments": "/api/v2/Assignment/War/801",
+  }
+  for route, path := range routes {
+    body := bytes.Buffer{}
+    err := json.Compact(&body, served.Responses[route])
+    if err != nil {
+      t.Fatalf("%s: %v", route, err)
+    }
+    if body.String() != bodies[path] {
+      t.Errorf("%s: got %s, want %s", route, body.String(), bodies[path])
+    }
+  }
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/spf13/pflag"
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/contract"
+  "github.com/Xide/helldivers2-dashboard/pkg/recorder"
+)
+
+// Problems of the responses of a route, aggregated over every validated response
+type routeContract struct {
+  responses int
+  // Problems by key, counted once per response they appear in
+  problems map[string]*contract.Problem
+}
+
+// Contract report of a set of responses, by route of the spec
+type contractReport struct {
+  routes map[string]*routeContract
+  // Responses that could not be validated, e.g. errors or undocumented routes
+  skipped []string
+}
+
+func newContractReport(routes []string) *contractReport {
+  report := &contractReport{routes: map[string]*routeContract{}}
+  for _, route := range routes {
+    report.routes[route] = &routeContract{problems: map[string]*contract.Problem{}}
+  }
+  return report
+}
+
+func (r *contractReport) add(route string, problems []contract.Problem) {
+  validated := r.routes[route]
+  validated.responses++
+  for _, problem := range problems {
+    if known, ok := validated.problems[problem.Key()]; ok {
+      known.Count++
+      continue
+    }
+    problem.Count = 1
+    validated.problems[problem.Key()] = &problem
+  }
+}
+
+func (r *contractReport) skip(format string, args ...interface{}) {
+  r.skipped = append(r.skipped, fmt.Sprintf(format, args...))
+}
+
+// Write the report, returns the number of distinct problems
+func (r *contractReport) write(out io.Writer) int {
+  routes := make([]string, 0, len(r.routes))
+  for route := range r.routes {
+    routes = append(routes, route)
+  }
+  sort.Strings(routes)
+  total := 0
+  for _, route := range routes {
+    validated := r.routes[route]
+    if validated.responses == 0 {
+      fmt.Fprintf(out, "%s: no response\n", route)
+      continue
+    }
+    fmt.Fprintf(out, "%s: %d responses, %d problems\n", route, validated.responses, len(validated.problems))
+    problems := make([]*contract.Problem, 0, len(validated.problems))
+    for _, problem := range validated.problems {
+      problems = append(problems, problem)
+    }
+    sort.Slice(problems, func(i, j int) bool {
+      return problems[i].Key() < problems[j].Key()
+    })
+    for _, problem := range problems {
+      field := problem.Field
+      if field == "" {
+        field = "(body)"
+      }
+      message := ""
+      if problem.Message != "" {
+        message = ": " + problem.Message
+      }
+      fmt.Fprintf(out, "  %s %s%s (%d/%d responses)\n", problem.Kind, field, message, problem.Count, validated.responses)
+    }
+    total += len(problems)
+  }
+  for _, skipped := range r.skipped {
+    fmt.Fprintf(out, "skipped: %s\n", skipped)
+  }
+  return total
+}
+
+// Validate the current responses of every route of the spec
+func validateLive(validator *contract.Validator, report *contractReport, apiURL string, warID int) error {
+  httpClient := &http.Client{Timeout: 10 * time.Second}
+  for _, route := range validator.Routes() {
+    target := strings.TrimSuffix(apiURL, "/") + strings.ReplaceAll(route, "{war_id}", strconv.Itoa(warID))
+    resp, err := httpClient.Get(target)
+    if err != nil {
+      report.skip("%s: %v", route, err)
+      continue
+    }
+    body, err := io.ReadAll(resp.Body)
+    resp.Body.Close()
+    if err != nil {
+      report.skip("%s: %v", route, err)
+      continue
+    }
+    if resp.StatusCode != http.StatusOK {
+      report.skip("%s: status %d", route, resp.StatusCode)
+      continue
+    }
+    problems, err := validator.Validate(route, body)
+    if err != nil {
+      return err
+    }
+    report.add(route, problems)
+  }
+  return nil
+}
+
+// Validate the successful responses of a recording, a file or a directory
+func validateRecording(validator *contract.Validator, report *contractReport, path string) error {
+  entries, err := recorder.Read(path)
+  if err != nil {
+    return err
+  }
+  for _, entry := range entries {
+    if entry.Status != http.StatusOK {
+      continue
+    }
+    requestURL, err := url.Parse(entry.URL)
+    if err != nil {
+      report.skip("%s: %v", entry.URL, err)
+      continue
+    }
+    route, ok := validator.Route(requestURL.Path)
+    if !ok {
+      report.skip("%s: not in the spec", entry.Route)
+      continue
+    }
+    body := []byte(entry.Body)
+    if len(body) == 0 {
+      body = entry.RawBody
+    }
+    problems, err := validator.Validate(route, body)
+    if err != nil {
+      return err
+    }
+    report.add(route, problems)
+  }
+  return nil
+}
+
+// `exporter validate` subcommand, checks live or recorded responses against
+// the schemas of spec.yaml
+func runValidate(args []string) error {
+  validateFlags := pflag.NewFlagSet("validate", pflag.ExitOnError)
+  apiURL := validateFlags.String("url", viper.GetString("api_url"), "URL of the API to validate")
+  warID := validateFlags.Int("war-id", 801, "War ID of the validated requests")
+  recording := validateFlags.String("recording", "", "Recording file or directory to validate instead of the live API")
+  err := validateFlags.Parse(args)
+  if err != nil {
+    return err
+  }
+  validator, err := contract.NewValidator()
+  if err != nil {
+    return err
+  }
+  report := newContractReport(validator.Routes())
+  if *recording != "" {
+    err = validateRecording(validator, report, *recording)
+  } else {
+    err = validateLive(validator, report, *apiURL, *warID)
+  }
+  if err != nil {
+    return err
+  }
+  problems := report.write(os.Stdout)
+  if problems > 0 {
+    return fmt.Errorf("%d contract problems", problems)
+  }
+  return nil
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "crypto/tls"
+  "crypto/x509"
+  "fmt"
+  "io"
+  "log/slog"
+  "net/http"
+  "os"
+  "os/signal"
+  "path/filepath"
+  "sync"
+  "sync/atomic"
+  "syscall"
+  "time"
+
+  "github.com/fsnotify/fsnotify"
+  "github.com/prometheus/client_golang/prometheus"
+  "golang.org/x/crypto/bcrypt"
+  "gopkg.in/yaml.v3"
+)
+
+// Web configuration file, in the format of the Prometheus exporter-toolkit
+// https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md
+type webConfig struct {
+  TLSServerConfig *webTLSConfig `yaml:"tls_server_config"`
+  // bcrypt hashed passwords, by user name
+  BasicAuthUsers map[string]string `yaml:"basic_auth_users"`
+}
+
+type webTLSConfig struct {
+  CertFile     string `yaml:"cert_file"`
+  KeyFile      string `yaml:"key_file"`
+  ClientCAFile string `yaml:"client_ca_file"`
+  // NoClientCert (default), RequestClientCert, RequireAnyClientCert,
+  // VerifyClientCertIfGiven or RequireAndVerifyClientCert
+  ClientAuthType string `yaml:"client_auth_type"`
+  // TLS10 to TLS13, TLS12 by default
+  MinVersion string `yaml:"min_version"`
+}
+
+var webClientAuthTypes = map[string]tls.ClientAuthType{
+  "":                           tls.NoClientCert,
+  "NoClientCert":               tls.NoClientCert,
+  "RequestClientCert":          tls.RequestClientCert,
+  "RequireAnyClientCert":       tls.RequireAnyClientCert,
+  "VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+  "RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+var webTLSVersions = map[string]uint16{
+  "":      tls.VersionTLS12,
+  "TLS10": tls.VersionTLS10,
+  "TLS11": tls.VersionTLS11,
+  "TLS12": tls.VersionTLS12,
+  "TLS13": tls.VersionTLS13,
+}
+
+// Names of the web configuration metrics
+const (
+  webConfigReloadsName    = "hde_web_config_reloads_total"
+  webConfigLastReloadName = "hde_web_config_last_reload_success_timestamp_seconds"
+)
+
+var (
+  webConfigReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+    Name: webConfigReloadsName,
+    Help: "Number of web configuration file loads, by result",
+  }, []string{"result"})
+  webConfigLastReload = prometheus.NewGauge(prometheus.GaugeOpts{
+    Name: webConfigLastReloadName,
+    Help: "Timestamp of the last successful web configuration file load",
+  })
+)
+
+// A loaded web configuration, ready to serve requests
+type loadedWebConfig struct {
+  tls   *tls.Config
+  users map[string]string
+  // Successful basic auth checks, bcrypt is too slow to run on every scrape
+  authCache *sync.Map
+}
+
+// Read and validate the web configuration file, with its certificates
+func loadWebConfig(path string) (*loadedWebConfig, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  config := webConfig{}
+  decoder := yaml.NewDecoder(bytes.NewReader(content))
+  decoder.KnownFields(true)
+  err = decoder.Decode(&config)
+  if err != nil && err != io.EOF {
+    return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+  }
+  for user, hash := range config.BasicAuthUsers {
+    if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+      return nil, fmt.Errorf("invalid bcrypt hash for user %s: %w", user, err)
+    }
+  }
+  loaded := &loadedWebConfig{users: config.BasicAuthUsers, authCache: &sync.Map{}}
+  if config.TLSServerConfig == nil {
+    return loaded, nil
+  }
+
+  // Relative paths are relative to the configuration file
+  resolve := func(file string) string {
+    if file == "" || filepath.IsAbs(file) {
+      return file
+    }
+    return filepath.Join(filepath.Dir(path), file)
+  }
+  c := config.TLSServerConfig
+  if c.CertFile == "" || c.KeyFile == "" {
+    return nil, fmt.Errorf("tls_server_config requires cert_file and key_file")
+  }
+  cert, err := tls.LoadX509KeyPair(resolve(c.CertFile), resolve(c.KeyFile))
+  if err != nil {
+    return nil, fmt.Errorf("failed to load certificate: %w", err)
+  }
+  clientAuth, ok := webClientAuthTypes[c.ClientAuthType]
+  if !ok {
+    return nil, fmt.Errorf("invalid client_auth_type %q", c.ClientAuthType)
+  }
+  minVersion, ok := webTLSVersions[c.MinVersion]
+  if !ok {
+    return nil, fmt.Errorf("invalid min_version %q", c.MinVersion)
+  }
+  loaded.tls = &tls.Config{
+    Certificates: []tls.Certificate{cert},
+    ClientAuth:   clientAuth,
+    MinVersion:   minVersion,
+  }
+  if c.ClientCAFile != "" {
+    ca, err := os.ReadFile(resolve(c.ClientCAFile))
+    if err != nil {
+      return nil, fmt.Errorf("failed to read client CA: %w", err)
+    }
+    pool := x509.NewCertPool()
+    if !pool.AppendCertsFromPEM(ca) {
+      return nil, fmt.Errorf("no certificate found in %s", c.ClientCAFile)
+    }
+    loaded.tls.ClientCAs = pool
+  } else if clientAuth == tls.VerifyClientCertIfGiven || clientAuth == tls.RequireAndVerifyClientCert {
+    return nil, fmt.Errorf("client_auth_type %s requires client_ca_file", c.ClientAuthType)
+  }
+  return loaded, nil
+}
+
+// Files the web configuration depends on, watched for changes
+func (c *webConfig) files(path string) []string {
+  files := []string{path}
+  if c.TLSServerConfig != nil {
+    for _, file := range []string{c.TLSServerConfig.CertFile, c.TLSServerConfig.KeyFile, c.TLSServerConfig.ClientCAFile} {
+      if file == "" {
+        continue
+      }
+      if !filepath.IsAbs(file) {
+        file = filepath.Join(filepath.Dir(path), file)
+      }
+      files = append(files, file)
+    }
+  }
+  return files
+}
+
+// webServer serves HTTP with the TLS and basic auth settings of the web
+// configuration file. The file, and the certificates it references, are
+// reloaded when they change or on SIGHUP.
+type webServer struct {
+  path    string
+  current atomic.Pointer[loadedWebConfig]
+  reload  sync.Mutex
+}
+
+func newWebServer(path string) (*webServer, error) {
+  s := &webServer{path: path}
+  loaded, err := loadWebConfig(path)
+  if err != nil {
+    webConfigReloads.WithLabelValues("failure").Inc()
+    return nil, err
+  }
+  s.store(loaded)
+  return s, nil
+}
+
+func (s *webServer) store(loaded *loadedWebConfig) {
+  s.current.Store(loaded)
+  webConfigReloads.WithLabelValues("success").Inc()
+  webConfigLastReload.SetToCurrentTime()
+}
+
+// Reload the configuration, an invalid file is rejected and the current
+// configuration is kept. The listener is either TLS or plain HTTP, so a
+// file enabling or disabling TLS is rejected too, it requires a restart.
+func (s *webServer) reloadConfig() {
+  s.reload.Lock()
+  defer s.reload.Unlock()
+  loaded, err := loadWebConfig(s.path)
+  if err != nil {
+    slog.Error("Failed to reload web config, keeping the current one", slog.String("file", s.path), slog.Any("error", err))
+    webConfigReloads.WithLabelValues("failure").Inc()
+    return
+  }
+  if (loaded.tls == nil) != (s.current.Load().tls == nil) {
+    slog.Error("Enabling or disabling TLS requires a restart, keeping the current web config", slog.String("file", s.path), slog.Bool("tls", loaded.tls != nil))
+    webConfigReloads.WithLabelValues("failure").Inc()
+    return
+  }
+  s.store(loaded)
+  slog.Info("Reloaded web config", slog.String("file", s.path), slog.Bool("tls", loaded.tls != nil), slog.Int("users", len(loaded.users)))
+}
+
+// Reload the configuration when one of its files changes, or on SIGHUP
+func (s *webServer) watch() {
+  hup := make(chan os.Signal, 1)
+  signal.Notify(hup, syscall.SIGHUP)
+
+  watcher, err := fsnotify.NewWatcher()
+  if err != nil {
+    slog.Error("Failed to create web config watcher", slog.Any("error", err))
+    return
+  }
+  defer watcher.Close()
+  watched := map[string]bool{}
+  watchFiles := func() {
+    // Watch the directories, files are often replaced instead of written
+    config := webConfig{}
+    content, err := os.ReadFile(s.path)
+    if err == nil {
+      yaml.Unmarshal(content, &config)
+    }
+    watched = map[string]bool{}
+    for _, file := range config.files(s.path) {
+      watched[filepath.Clean(file)] = true
+      err := watcher.Add(filepath.Dir(file))
+      if err != nil {
+        slog.Warn("Failed to watch web config file", slog.String("file", file), slog.Any("error", err))
+      }
+    }
+  }
+  watchFiles()
+
+  // Writes usually come as bursts of events, wait for the files to settle
+  var debounce *time.Timer
+  settled := make(chan struct{}, 1)
+  reload := func() {
+    s.reloadConfig()
+    watchFiles()
+  }
+  for {
+    select {
+    case <-hup:
+      reload()
+    case <-settled:
+      reload()
+    case event, ok := <-watcher.Events:
+      if !ok {
+        return
+      }
+      if !watched[filepath.Clean(event.Name)] || event.Op == fsnotify.Chmod {
+        continue
+      }
+      if debounce != nil {
+        debounce.Stop()
+      }
+      debounce = time.AfterFunc(200*time.Millisecond, func() {
+        select {
+        case settled <- struct{}{}:
+        default:
+        }
+      })
+    case err, ok := <-watcher.Errors:
+      if !ok {
+        return
+      }
+      slog.Error("Web config watcher error", slog.Any("error", err))
+    }
+  }
+}
+
+// Hash compared to when the user is unknown, so unknown users take as long
+// to reject as wrong passwords
+var webDummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+
+func (s *webServer) authorized(r *http.Request) bool {
+  loaded := s.current.Load()
+  users := loaded.users
+  if len(users) == 0 {
+    return true
+  }
+  user, password, ok := r.BasicAuth()
+  if !ok {
+    return false
+  }
+  hash, known := users[user]
+  if !known {
+    bcrypt.CompareHashAndPassword(webDummyHash, []byte(password))
+    return false
+  }
+  key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+  if _, ok := loaded.authCache.Load(key); ok {
+    return true
+  }
+  if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+    return false
+  }
+  loaded.authCache.Store(key, true)
+  return true
+}
+
+// Require basic auth on every request when users are configured
+func (s *webServer) handler(next http.Handler) http.Handler {
+  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if !s.authorized(r) {
+      w.Header().Set("WWW-Authenticate", `Basic realm="hde"`)
+      http.Error(w, "Unauthorized", http.StatusUnauthorized)
+      return
+    }
+    next.ServeHTTP(w, r)
+  })
+}
+
+// Serve HTTP on `address`, with the web configuration file when `path` is
+// set, or plain HTTP without authentication otherwise
+func listenAndServe(address string, path string, handler http.Handler) error {
+  if path == "" {
+    return http.ListenAndServe(address, handler)
+  }
+  s, err := newWebServer(path)
+  if err != nil {
+    return err
+  }
+  go s.watch()
+  server := &http.Server{Addr: address, Handler: s.handler(handler)}
+  if s.current.Load().tls == nil {
+    slog.Info("TLS is disabled", slog.String("web_config_file", path))
+    return server.ListenAndServe()
+  }
+  // Certificates and client CA are read from the current configuration
+  // on every handshake, to pick up reloads
+  currentTLS := func() (*tls.Config, error) {
+    loaded := s.current.Load()
+    if loaded.tls == nil {
+      return nil, fmt.Errorf("TLS disabled by the web config")
+    }
+    return loaded.tls, nil
+  }
+  server.TLSConfig = &tls.Config{
+    GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+      return currentTLS()
+    },
+    GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+      config, err := currentTLS()
+      if err != nil {
+        return nil, err
+      }
+      return &config.Certificates[0], nil
+    },
+  }
+  return server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+  "crypto/ecdsa"
+  "crypto/elliptic"
+  "crypto/rand"
+  "crypto/x509"
+  "crypto/x509/pkix"
+  "encoding/pem"
+  "fmt"
+  "math/big"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus/testutil"
+  "golang.org/x/crypto/bcrypt"
+)
+
+// Web configuration file with bcrypt hashed passwords, by user name
+func writeWebConfig(t *testing.T, path string, tlsConfig string, users map[string]string) {
+  t.Helper()
+  content := tlsConfig + "basic_auth_users:\n"
+  for user, password := range users {
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+    if err != nil {
+      t.Fatal(err)
+    }
+    content += fmt.Sprintf("  %s: %s\n", user, hash)
+  }
+  err := os.WriteFile(path, []byte(content), 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+}
+
+// Self-signed certificate and key, written as cert.pem and key.pem in dir
+func writeTestCertificate(t *testing.T, dir string) {
+  t.Helper()
+  key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+  if err != nil {
+    t.Fatal(err)
+  }
+  template := &x509.Certificate{
+    SerialNumber: big.NewInt(1),
+    Subject:      pkix.Name{CommonName: "localhost"},
+    NotBefore:    time.Now().Add(-time.Hour),
+    NotAfter:     time.Now().Add(time.Hour),
+    DNSNames:     []string{"localhost"},
+  }
+  cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+  if err != nil {
+    t.Fatal(err)
+  }
+  der, err := x509.MarshalECPrivateKey(key)
+  if err != nil {
+    t.Fatal(err)
+  }
+  for name, block := range map[string]*pem.Block{
+    "cert.pem": {Type: "CERTIFICATE", Bytes: cert},
+    "key.pem":  {Type: "EC PRIVATE KEY", Bytes: der},
+  } {
+    err := os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+}
+
+// Status of a request to the web server handler, with basic auth
+// credentials unless user is empty
+func webStatus(s *webServer, user string, password string) int {
+  handler := s.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+  req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+  if user != "" {
+    req.SetBasicAuth(user, password)
+  }
+  rec := httptest.NewRecorder()
+  handler.ServeHTTP(rec, req)
+  return rec.Code
+}
+
+func TestWebServerBasicAuth(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "web.yml")
+  writeWebConfig(t, path, "", map[string]string{"prometheus": "secret"})
+  s, err := newWebServer(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  tests := []struct {
+    name     string
+    user     string
+    password string
+    status   int
+  }{
+    {"no credentials", "", "", http.StatusUnauthorized},
+    {"wrong password", "prometheus", "guess", http.StatusUnauthorized},
+    {"unknown user", "grafana", "secret", http.StatusUnauthorized},
+    {"correct password", "prometheus", "secret", http.StatusOK},
+    // Served from the cache of successful checks
+    {"correct password again", "prometheus", "secret", http.StatusOK},
+  }
+  for _, test := range tests {
+    if status := webStatus(s, test.user, test.password); status != test.status {
+      t.Errorf("%s: got status %d, want %d", test.name, status, test.status)
+    }
+  }
+}
+
+func TestWebServerReload(t *testing.T) {
+  dir := t.TempDir()
+  path := filepath.Join(dir, "web.yml")
+  writeWebConfig(t, path, "", map[string]string{"prometheus": "secret"})
+  s, err := newWebServer(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  // Users replaced, the cached checks of the removed ones are forgotten
+  webStatus(s, "prometheus", "secret")
+  writeWebConfig(t, path, "", map[string]string{"grafana": "other"})
+  s.reloadConfig()
+  if status := webStatus(s, "prometheus", "secret"); status != http.StatusUnauthorized {
+    t.Errorf("removed user: got status %d, want 401", status)
+  }
+  if status := webStatus(s, "grafana", "other"); status != http.StatusOK {
+    t.Errorf("added user: got status %d, want 200", status)
+  }
+
+  // An invalid file keeps the current users
+  failures := testutil.ToFloat64(webConfigReloads.WithLabelValues("failure"))
+  err = os.WriteFile(path, []byte("basic_auth_users:\n  grafana: not-a-hash\n"), 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  s.reloadConfig()
+  if status := webStatus(s, "grafana", "other"); status != http.StatusOK {
+    t.Errorf("after an invalid reload: got status %d, want 200", status)
+  }
+
+  // Enabling TLS requires a restart, the reload is rejected
+  writeTestCertificate(t, dir)
+  writeWebConfig(t, path, "tls_server_config:\n  cert_file: cert.pem\n  key_file: key.pem\n", map[string]string{"grafana": "other"})
+  s.reloadConfig()
+  if s.current.Load().tls != nil {
+    t.Error("TLS enabled by a reload")
+  }
+  if value := testutil.ToFloat64(webConfigReloads.WithLabelValues("failure")) - failures; value != 2 {
+    t.Errorf("got %v failed reloads, want 2", value)
+  }
+
+  // Disabling it too
+  tlsServer, err := newWebServer(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  writeWebConfig(t, path, "", map[string]string{"grafana": "other"})
+  tlsServer.reloadConfig()
+  if tlsServer.current.Load().tls == nil {
+    t.Error("TLS disabled by a reload")
+  }
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "log/slog"
+  "net/http"
+  "os"
+  "strconv"
+  "strings"
+  "sync"
+  "text/template"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus"
+  "github.com/spf13/viper"
+  "golang.org/x/time/rate"
+  "gopkg.in/yaml.v3"
+)
+
+// Payload templates of the webhook presets
+var webhookPresets = map[string]string{
+  "discord": `{"embeds": [{"title": {{ json .Title }}, "description": {{ json .Text }}, "timestamp": {{ json .Event.Time }}}]}`,
+  "slack":   `{"text": {{ json (printf "*%s*\n%s" .Title .Text) }}}`,
+}
+
+const webhookNotificationsName = "hde_webhook_notifications_total"
+
+var webhookNotifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+  Name: webhookNotificationsName,
+  Help: "Number of war event notifications handled by a webhook, by result (sent, failed, deduplicated, dropped)",
+}, []string{"webhook", "result"})
+
+// webhookConfig is the configuration of a single webhook,
+// as found in the file pointed by the `webhook_config` key
+type webhookConfig struct {
+  // Name of the webhook, used in logs and metrics
+  Name string `yaml:"name"`
+  URL  string `yaml:"url"`
+  // discord, slack or custom
+  Preset string `yaml:"preset"`
+  // Go template of the JSON payload, required by the custom preset and
+  // overrides the payload of the other ones
+  Template string            `yaml:"template"`
+  Headers  map[string]string `yaml:"headers"`
+  // Types of the events to send, all of them when empty
+  Events []string `yaml:"events"`
+  // Maximum number of messages per second, and burst
+  RateLimit float64 `yaml:"rate_limit"`
+  Burst     int     `yaml:"burst"`
+  // Number of retries of a failed delivery, 0 disables them.
+  // A pointer, so an unset value gets the default.
+  MaxRetries *int `yaml:"max_retries"`
+  // Events with the same subject within this window are only sent once
+  DedupWindow time.Duration `yaml:"dedup_window"`
+  Timeout     time.Duration `yaml:"timeout"`
+}
+
+type webhooksConfig struct {
+  Webhooks []webhookConfig `yaml:"webhooks"`
+}
+
+// Read the webhooks configuration file, and fill the defaults
+func loadWebhooksConfig(path string) ([]webhookConfig, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  config := webhooksConfig{}
+  decoder := yaml.NewDecoder(bytes.NewReader(content))
+  decoder.KnownFields(true)
+  err = decoder.Decode(&config)
+  if err != nil && err != io.EOF {
+    return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+  }
+  for i := range config.Webhooks {
+    w := &config.Webhooks[i]
+    if w.Name == "" {
+      w.Name = fmt.Sprintf("webhook_%d", i)
+    }
+    if w.URL == "" {
+      return nil, fmt.Errorf("webhook %s: missing url", w.Name)
+    }
+    if w.Preset == "" {
+      w.Preset = "custom"
+    }
+    if w.RateLimit == 0 {
+      w.RateLimit = 0.5
+    }
+    if w.Burst == 0 {
+      w.Burst = 5
+    }
+    if w.MaxRetries == nil {
+      maxRetries := 5
+      w.MaxRetries = &maxRetries
+    }
+    if *w.MaxRetries < 0 {
+      return nil, fmt.Errorf("webhook %s: negative max_retries %d", w.Name, *w.MaxRetries)
+    }
+    if w.DedupWindow == 0 {
+      w.DedupWindow = 10 * time.Minute
+    }
+    if w.Timeout == 0 {
+      w.Timeout = 10 * time.Second
+    }
+    for _, eventType := range w.Events {
+      if !isWarEventType(eventType) {
+        return nil, fmt.Errorf("webhook %s: unknown event type %q", w.Name, eventType)
+      }
+    }
+  }
+  return config.Webhooks, nil
+}
+
+func isWarEventType(eventType string) bool {
+  for _, t := range warEventTypes {
+    if t == eventType {
+      return true
+    }
+  }
+  return false
+}
+
+// Data available to the payload templates
+type webhookPayload struct {
+  Event WarEvent
+  // Short title and human readable description of the event
+  Title string
+  Text  string
+}
+
+func newWebhookPayload(e WarEvent) webhookPayload {
+  planet := e.Planet
+  if planet == "" && e.PlanetIndex != nil {
+    planet = fmt.Sprintf("Planet %d", *e.PlanetIndex)
+  }
+  payload := webhookPayload{Event: e}
+  switch e.Type {
+  case eventPlanetLiberated:
+    payload.Title = "Planet liberated"
+    payload.Text = fmt.Sprintf("%s was liberated from the %s", planet, e.PreviousFaction)
+  case eventPlanetLost:
+    payload.Title = "Planet lost"
+    payload.Text = fmt.Sprintf("%s fell to the %s", planet, e.Faction)
+  case eventPlanetOwnerChanged:
+    payload.Title = "Planet changed hands"
+    payload.Text = fmt.Sprintf("%s was taken from the %s by the %s", planet, e.PreviousFaction, e.Faction)
+  case eventDefenseStarted:
+    payload.Title = "Defense started"
+    payload.Text = fmt.Sprintf("The %s are attacking %s", e.Faction, planet)
+  case eventDefenseEnded:
+    payload.Title = "Defense ended"
+    payload.Text = fmt.Sprintf("The defense of %s against the %s is over", planet, e.Faction)
+  case eventSupplyLaneOpened:
+    payload.Title = "Supply lane opened"
+    payload.Text = fmt.Sprintf("%s can now be reached from %s", e.Target, planet)
+  case eventSupplyLaneClosed:
+    payload.Title = "Supply lane closed"
+    payload.Text = fmt.Sprintf("%s can no longer be reached from %s", e.Target, planet)
+  case eventMajorOrderIssued:
+    payload.Title = "New major order"
+    payload.Text = strings.TrimSpace(e.Title + "\n" + e.Message)
+  case eventMajorOrderCompleted:
+    payload.Title = "Major order completed"
+    payload.Text = e.Title
+  case eventMajorOrderExpired:
+    payload.Title = "Major order expired"
+    payload.Text = e.Title
+  default:
+    payload.Title = e.Type
+  }
+  return payload
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+  // Encode a value as JSON, to safely embed strings in payloads
+  "json": func(v any) (string, error) {
+    b, err := json.Marshal(v)
+    return string(b), err
+  },
+}
+
+// webhook delivers war events to a single URL
+type webhook struct {
+  config   webhookConfig
+  template *template.Template
+  limiter  *rate.Limiter
+  http     *http.Client
+  queue    chan WarEvent
+
+  mu   sync.Mutex
+  // Last time an event subject was queued, for deduplication
+  seen map[string]time.Time
+}
+
+func newWebhook(config webhookConfig) (*webhook, error) {
+  payload := config.Template
+  if payload == "" {
+    preset, ok := webhookPresets[config.Preset]
+    if !ok {
+      return nil, fmt.Errorf("webhook %s: preset %q requires a template", config.Name, config.Preset)
+    }
+    payload = preset
+  }
+  tmpl, err := template.New(config.Name).Funcs(webhookTemplateFuncs).Parse(payload)
+  if err != nil {
+    return nil, fmt.Errorf("webhook %s: invalid template: %w", config.Name, err)
+  }
+  return &webhook{
+    config:   config,
+    template: tmpl,
+    limiter:  rate.NewLimiter(rate.Limit(config.RateLimit), config.Burst),
+    http:     &http.Client{Timeout: config.Timeout},
+    queue:    make(chan WarEvent, 100),
+    seen:     map[string]time.Time{},
+  }, nil
+}
+
+// Identifies what an event is about, regardless of when it was detected
+func eventSubject(e WarEvent) string {
+  subject := e.Type
+  for _, id := range []*int32{e.PlanetIndex, e.TargetIndex, e.CampaignID} {
+    if id != nil {
+      subject += ":" + strconv.Itoa(int(*id))
+    }
+  }
+  if e.AssignmentID != nil {
+    subject += ":" + strconv.FormatInt(*e.AssignmentID, 10)
+  }
+  return subject
+}
+
+func (w *webhook) accepts(e WarEvent) bool {
+  if len(w.config.Events) == 0 {
+    return true
+  }
+  for _, t := range w.config.Events {
+    if t == e.Type {
+      return true
+    }
+  }
+  return false
+}
+
+// Queue an event for delivery, without blocking the scraper
+func (w *webhook) notify(e WarEvent) {
+  if !w.accepts(e) {
+    return
+  }
+  subject := eventSubject(e)
+  w.mu.Lock()
+  for s, t := range w.seen {
+    if time.Since(t) > w.config.DedupWindow {
+      delete(w.seen, s)
+    }
+  }
+  _, duplicate := w.seen[subject]
+  if !duplicate {
+    w.seen[subject] = time.Now()
+  }
+  w.mu.Unlock()
+  if duplicate {
+    slog.Info("Skipping duplicate webhook notification", slog.String("webhook", w.config.Name), slog.String("id", e.ID))
+    webhookNotifications.WithLabelValues(w.config.Name, "deduplicated").Inc()
+    return
+  }
+
+  select {
+  case w.queue <- e:
+  default:
+    slog.Warn("Webhook queue full, dropping notification", slog.String("webhook", w.config.Name), slog.String("id", e.ID))
+    webhookNotifications.WithLabelValues(w.config.Name, "dropped").Inc()
+  }
+}
+
+// Deliver the queued events, one at a time
+func (w *webhook) run(ctx context.Context) {
+  for {
+    select {
+    case <-ctx.Done():
+      return
+    case e := <-w.queue:
+      err := w.limiter.Wait(ctx)
+      if err != nil {
+        return
+      }
+      err = w.deliver(ctx, e)
+      if err != nil {
+        slog.Error("Failed to send webhook notification", slog.String("webhook", w.config.Name), slog.String("id", e.ID), slog.Any("error", err))
+        webhookNotifications.WithLabelValues(w.config.Name, "failed").Inc()
+        continue
+      }
+      slog.Info("Sent webhook notification", slog.String("webhook", w.config.Name), slog.String("id", e.ID))
+      webhookNotifications.WithLabelValues(w.config.Name, "sent").Inc()
+    }
+  }
+}
+
+// POST an event, retrying with an exponential backoff on network errors,
+// rate limiting (429) and server errors. Retry-After is honored when present.
+func (w *webhook) deliver(ctx context.Context, e WarEvent) error {
+  body := bytes.Buffer{}
+  err := w.template.Execute(&body, newWebhookPayload(e))
+  if err != nil {
+    return fmt.Errorf("failed to render payload: %w", err)
+  }
+  backoff := time.Second
+  for attempt := 0; ; attempt++ {
+    retryAfter, err := w.post(ctx, body.Bytes())
+    if err == nil {
+      return nil
+    }
+    if retryAfter < 0 || attempt >= *w.config.MaxRetries {
+      return err
+    }
+    wait := backoff
+    if retryAfter > 0 {
+      wait = retryAfter
+    }
+    slog.Warn("Retrying webhook notification", slog.String("webhook", w.config.Name), slog.String("id", e.ID), slog.Duration("wait", wait), slog.Any("error", err))
+    select {
+    case <-ctx.Done():
+      return ctx.Err()
+    case <-time.After(wait):
+    }
+    backoff = min(backoff*2, time.Minute)
+  }
+}
+
+// Send a payload. On failure, returns how long to wait before retrying:
+// 0 to use the default backoff, negative when retrying is pointless
+func (w *webhook) post(ctx context.Context, body []byte) (time.Duration, error) {
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+  if err != nil {
+    return -1, err
+  }
+  req.Header.Set("Content-Type", "application/json")
+  for k, v := range w.config.Headers {
+    req.Header.Set(k, v)
+  }
+  resp, err := w.http.Do(req)
+  if err != nil {
+    return 0, err
+  }
+  defer resp.Body.Close()
+  io.Copy(io.Discard, resp.Body)
+  if resp.StatusCode < 300 {
+    return 0, nil
+  }
+  err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+  if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+    return -1, err
+  }
+  return parseRetryAfter(resp.Header.Get("Retry-After")), err
+}
+
+// Parse a Retry-After header, either a number of seconds or an HTTP date
+func parseRetryAfter(value string) time.Duration {
+  if value == "" {
+    return 0
+  }
+  if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+    return time.Duration(seconds * float64(time.Second))
+  }
+  if date, err := http.ParseTime(value); err == nil && time.Until(date) > 0 {
+    return time.Until(date)
+  }
+  return 0
+}
+
+// Load the webhooks of the `webhook_config` file, and subscribe them
+// to the war events
+func startWebhooks(ctx context.Context, events *warEventLog) error {
+  path := viper.GetString("webhook_config")
+  if path == "" {
+    return nil
+  }
+  configs, err := loadWebhooksConfig(path)
+  if err != nil {
+    return err
+  }
+  for _, config := range configs {
+    w, err := newWebhook(config)
+    if err != nil {
+      return err
+    }
+    for _, result := range []string{"sent", "failed", "deduplicated", "dropped"} {
+      webhookNotifications.WithLabelValues(config.Name, result)
+    }
+    events.subscribe(w.notify)
+    go w.run(ctx)
+    slog.Info("Started webhook", slog.String("webhook", config.Name), slog.String("preset", config.Preset), slog.Any("events", config.Events))
+  }
+  return nil
+}
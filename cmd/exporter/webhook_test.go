@@ -0,0 +1,239 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "sync"
+  "testing"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Webhook receiver answering with the given status codes in turn, the last
+// one is repeated. Returns the URL and the received bodies.
+func startWebhookReceiver(t *testing.T, retryAfter string, statuses ...int) (string, func() [][]byte) {
+  t.Helper()
+  mu := sync.Mutex{}
+  bodies := [][]byte{}
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    body, _ := io.ReadAll(r.Body)
+    mu.Lock()
+    bodies = append(bodies, body)
+    status := statuses[min(len(bodies), len(statuses))-1]
+    mu.Unlock()
+    if status == http.StatusTooManyRequests && retryAfter != "" {
+      w.Header().Set("Retry-After", retryAfter)
+    }
+    w.WriteHeader(status)
+  }))
+  t.Cleanup(server.Close)
+  return server.URL, func() [][]byte {
+    mu.Lock()
+    defer mu.Unlock()
+    return append([][]byte{}, bodies...)
+  }
+}
+
+func writeWebhooksConfig(t *testing.T, content string) string {
+  t.Helper()
+  path := filepath.Join(t.TempDir(), "webhooks.yml")
+  err := os.WriteFile(path, []byte(content), 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return path
+}
+
+func TestLoadWebhooksConfig(t *testing.T) {
+  configs, err := loadWebhooksConfig(writeWebhooksConfig(t, `
+webhooks:
+  - url: http://localhost/default
+  - name: no-retries
+    url: http://localhost/no-retries
+    max_retries: 0
+`))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(configs) != 2 {
+    t.Fatalf("got %d webhooks, want 2", len(configs))
+  }
+  if configs[0].Name != "webhook_0" || configs[0].Preset != "custom" || *configs[0].MaxRetries != 5 || configs[0].DedupWindow != 10*time.Minute {
+    t.Errorf("defaults: got %+v", configs[0])
+  }
+  if *configs[1].MaxRetries != 0 {
+    t.Errorf("max_retries: got %d, want 0", *configs[1].MaxRetries)
+  }
+
+  for name, content := range map[string]string{
+    "missing url":          "webhooks: [{name: hook}]",
+    "negative max_retries": "webhooks: [{url: http://localhost, max_retries: -1}]",
+    "unknown event type":   "webhooks: [{url: http://localhost, events: [planet_exploded]}]",
+    "unknown field":        "webhooks: [{url: http://localhost, retries: 1}]",
+  } {
+    _, err := loadWebhooksConfig(writeWebhooksConfig(t, content))
+    if err == nil {
+      t.Errorf("%s: got no error", name)
+    }
+  }
+}
+
+func TestWebhookPresets(t *testing.T) {
+  planet := int32(5)
+  event := WarEvent{
+    ID:              "planet_liberated-5",
+    Type:            eventPlanetLiberated,
+    Time:            time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+    PlanetIndex:     &planet,
+    Planet:          `Fenrir "III"`,
+    PreviousFaction: "Terminids",
+  }
+  tests := []struct {
+    preset string
+    want   map[string]any
+  }{
+    {"discord", map[string]any{"embeds": []any{map[string]any{
+      "title":       "Planet liberated",
+      "description": `Fenrir "III" was liberated from the Terminids`,
+      "timestamp":   "2024-03-01T12:00:00Z",
+    }}}},
+    {"slack", map[string]any{"text": "*Planet liberated*\nFenrir \"III\" was liberated from the Terminids"}},
+  }
+  for _, test := range tests {
+    t.Run(test.preset, func(t *testing.T) {
+      url, bodies := startWebhookReceiver(t, "", http.StatusNoContent)
+      retries := 0
+      w, err := newWebhook(webhookConfig{Name: test.preset, URL: url, Preset: test.preset, MaxRetries: &retries, Timeout: time.Second})
+      if err != nil {
+        t.Fatal(err)
+      }
+      err = w.deliver(context.Background(), event)
+      if err != nil {
+        t.Fatal(err)
+      }
+      received := bodies()
+      if len(received) != 1 {
+        t.Fatalf("got %d requests, want 1", len(received))
+      }
+      got := map[string]any{}
+      err = json.Unmarshal(received[0], &got)
+      if err != nil {
+        t.Fatalf("invalid payload %s: %v", received[0], err)
+      }
+      gotJSON, _ := json.Marshal(got)
+      wantJSON, _ := json.Marshal(test.want)
+      if string(gotJSON) != string(wantJSON) {
+        t.Errorf("got payload %s, want %s", gotJSON, wantJSON)
+      }
+    })
+  }
+
+  _, err := newWebhook(webhookConfig{Name: "custom", Preset: "custom"})
+  if err == nil {
+    t.Error("the custom preset without a template must be rejected")
+  }
+}
+
+func TestWebhookRetries(t *testing.T) {
+  tests := []struct {
+    name       string
+    maxRetries int
+    retryAfter string
+    statuses   []int
+    requests   int
+    failed     bool
+  }{
+    {"rate limited with retry-after", 5, "0.05", []int{http.StatusTooManyRequests, http.StatusOK}, 2, false},
+    {"retries disabled", 0, "", []int{http.StatusServiceUnavailable}, 1, true},
+    {"client error not retried", 5, "", []int{http.StatusBadRequest}, 1, true},
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      url, bodies := startWebhookReceiver(t, test.retryAfter, test.statuses...)
+      w, err := newWebhook(webhookConfig{Name: "test", URL: url, Preset: "slack", MaxRetries: &test.maxRetries, Timeout: time.Second})
+      if err != nil {
+        t.Fatal(err)
+      }
+      start := time.Now()
+      err = w.deliver(context.Background(), WarEvent{Type: eventDefenseStarted})
+      if (err != nil) != test.failed {
+        t.Errorf("got error %v, want failure %v", err, test.failed)
+      }
+      if requests := len(bodies()); requests != test.requests {
+        t.Errorf("got %d requests, want %d", requests, test.requests)
+      }
+      // Retry-After is used instead of the 1s backoff
+      if test.retryAfter != "" {
+        if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed >= time.Second {
+          t.Errorf("retried after %v, want the 50ms of Retry-After", elapsed)
+        }
+      }
+    })
+  }
+}
+
+func TestWebhookDeduplication(t *testing.T) {
+  url, bodies := startWebhookReceiver(t, "", http.StatusOK)
+  retries := 0
+  w, err := newWebhook(webhookConfig{
+    Name: "dedup", URL: url, Preset: "slack", MaxRetries: &retries, Timeout: time.Second,
+    RateLimit: 100, Burst: 10, DedupWindow: time.Hour,
+    Events: []string{eventPlanetLost, eventDefenseStarted},
+  })
+  if err != nil {
+    t.Fatal(err)
+  }
+  deduplicated := testutil.ToFloat64(webhookNotifications.WithLabelValues("dedup", "deduplicated"))
+  sent := testutil.ToFloat64(webhookNotifications.WithLabelValues("dedup", "sent"))
+
+  planet, other := int32(1), int32(2)
+  // Detected twice, e.g. after a restart: same subject, different IDs
+  w.notify(WarEvent{ID: "a", Type: eventPlanetLost, PlanetIndex: &planet})
+  w.notify(WarEvent{ID: "b", Type: eventPlanetLost, PlanetIndex: &planet})
+  w.notify(WarEvent{ID: "c", Type: eventPlanetLost, PlanetIndex: &other})
+  // Filtered out by the event types
+  w.notify(WarEvent{ID: "d", Type: eventPlanetLiberated, PlanetIndex: &planet})
+
+  if value := testutil.ToFloat64(webhookNotifications.WithLabelValues("dedup", "deduplicated")) - deduplicated; value != 1 {
+    t.Errorf("got %v deduplicated notifications, want 1", value)
+  }
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+  go w.run(ctx)
+  deadline := time.Now().Add(5 * time.Second)
+  for testutil.ToFloat64(webhookNotifications.WithLabelValues("dedup", "sent"))-sent < 2 && time.Now().Before(deadline) {
+    time.Sleep(10 * time.Millisecond)
+  }
+  if requests := len(bodies()); requests != 2 {
+    t.Errorf("got %d requests, want 2", requests)
+  }
+}
+
+func TestParseRetryAfter(t *testing.T) {
+  tests := []struct {
+    value string
+    want  time.Duration
+  }{
+    {"", 0},
+    {"3", 3 * time.Second},
+    {"0.5", 500 * time.Millisecond},
+    {"-1", 0},
+    {"soon", 0},
+    {time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+  }
+  for _, test := range tests {
+    if got := parseRetryAfter(test.value); got != test.want {
+      t.Errorf("parseRetryAfter(%q): got %v, want %v", test.value, got, test.want)
+    }
+  }
+  future := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+  if future <= 58*time.Minute || future > time.Hour {
+    t.Errorf("parseRetryAfter of a date in an hour: got %v", future)
+  }
+}
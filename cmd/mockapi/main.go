@@ -0,0 +1,114 @@
+package main
+
+import (
+  "context"
+  "log/slog"
+  "os"
+  "time"
+
+  "github.com/labstack/echo/v4"
+  "github.com/spf13/pflag"
+  "github.com/spf13/viper"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/mockapi"
+)
+
+var flags *pflag.FlagSet = pflag.NewFlagSet("hde", pflag.ExitOnError)
+
+func initLogger() {
+  logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+  slog.SetDefault(logger)
+}
+
+func init() {
+  viper.SetEnvPrefix("hde")
+  viper.AutomaticEnv()
+
+  flags.String("expose_address", ":8000", "Address to serve the mock API")
+  flags.String("fixtures_dir", "", "Directory of the fixtures served by the mock API, the embedded fixtures are used when empty")
+  flags.Int("news_page_size", 10, "Maximum number of news feed entries per response")
+  flags.Bool("simulate", false, "Evolve a war of the fixtures with the war simulator")
+  flags.Int("simulation_war_id", 801, "ID of the simulated war")
+  flags.Int64("simulation_seed", 1, "Seed of the war simulator, a seed always produces the same war")
+  flags.Float64("simulation_speed", 60, "Simulated time elapsed per second of wall time")
+  flags.Duration("simulation_tick", time.Second, "Interval between two updates of the simulated war")
+  flags.String("fault_profiles", "", "YAML file of fault profiles, available along with the builtin ones")
+  flags.String("fault_profile", "none", "Fault profile active at startup, can be changed through the /admin/faults endpoint")
+  flags.Int64("fault_seed", 1, "Seed of the fault injection")
+  err := viper.BindPFlags(flags)
+  if err != nil {
+    panic(err)
+  }
+  initLogger()
+}
+
+// Log every request
+func logRequests(next echo.HandlerFunc) echo.HandlerFunc {
+  return func(ctx echo.Context) error {
+    err := next(ctx)
+    if err != nil {
+      ctx.Error(err)
+    }
+    slog.Info("Served request",
+      slog.String("method", ctx.Request().Method),
+      slog.String("path", ctx.Request().URL.RequestURI()),
+      slog.Int("status", ctx.Response().Status),
+      slog.String("request_id", ctx.Request().Header.Get("X-Request-Id")),
+    )
+    return nil
+  }
+}
+
+func main() {
+  fixtures := mockapi.DefaultFixtures()
+  if dir := viper.GetString("fixtures_dir"); dir != "" {
+    fixtures = os.DirFS(dir)
+  }
+  wars, err := mockapi.LoadFixtures(fixtures)
+  if err != nil {
+    slog.Error("Failed to load fixtures", slog.Any("error", err))
+    os.Exit(1)
+  }
+  for warID, war := range wars {
+    slog.Info("Loaded war", slog.Int("war_id", warID), slog.Int("planets", len(war.Status.PlanetStatus)), slog.Int("news_languages", len(war.News)))
+  }
+
+  server := mockapi.NewServer(wars, viper.GetInt("news_page_size"))
+  if viper.GetBool("simulate") {
+    warID := viper.GetInt("simulation_war_id")
+    war, ok := wars[warID]
+    if !ok {
+      slog.Error("Simulated war not found in the fixtures", slog.Int("war_id", warID))
+      os.Exit(1)
+    }
+    simulator := mockapi.NewSimulator(viper.GetInt64("simulation_seed"), warID, war)
+    slog.Info("Simulating war", slog.Int("war_id", warID), slog.Int64("seed", viper.GetInt64("simulation_seed")), slog.Float64("speed", viper.GetFloat64("simulation_speed")))
+    go simulator.Run(context.Background(), server, viper.GetDuration("simulation_tick"), viper.GetFloat64("simulation_speed"))
+  }
+
+  profiles := map[string]mockapi.Profile{}
+  if path := viper.GetString("fault_profiles"); path != "" {
+    profiles, err = mockapi.LoadProfiles(path)
+    if err != nil {
+      slog.Error("Failed to load fault profiles", slog.Any("error", err))
+      os.Exit(1)
+    }
+  }
+  faults := mockapi.NewFaults(viper.GetInt64("fault_seed"), profiles)
+  err = faults.Activate(viper.GetString("fault_profile"))
+  if err != nil {
+    slog.Error("Invalid fault profile", slog.Any("error", err))
+    os.Exit(1)
+  }
+  slog.Info("Fault injection", slog.String("profile", viper.GetString("fault_profile")))
+
+  e := server.Handler()
+  e.Use(logRequests, faults.Middleware)
+  faults.RegisterAdmin(e)
+  slog.Info("Starting mock API", slog.String("address", viper.GetString("expose_address")))
+  err = e.Start(viper.GetString("expose_address"))
+  if err != nil {
+    slog.Error("Error starting server", slog.Any("error", err))
+    os.Exit(1)
+  }
+}
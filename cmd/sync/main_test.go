@@ -0,0 +1,278 @@
+package main
+
+import (
+  "context"
+  "database/sql"
+  "path/filepath"
+  "testing"
+
+  _ "github.com/golang-migrate/migrate/v4/database/sqlite"
+  "golang.org/x/time/rate"
+  _ "modernc.org/sqlite"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+  migrate "github.com/Xide/helldivers2-dashboard/pkg/migrations"
+  "github.com/Xide/helldivers2-dashboard/pkg/mockapi"
+)
+
+// Local sqlite database, with the migrations applied
+func openTestDB(t *testing.T) *sql.DB {
+  t.Helper()
+  path := filepath.Join(t.TempDir(), "helldivers2.db")
+  migrations, err := filepath.Abs(filepath.Join("..", "..", "pkg", "migrations"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = migrate.Migrate("sqlite://"+path, migrations)
+  if err != nil {
+    t.Fatal(err)
+  }
+  db, err := sql.Open("sqlite", path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t.Cleanup(func() { db.Close() })
+  return db
+}
+
+// State of the managers, reading from an in-process mock API serving the
+// embedded fixtures, `pageSize` news feed entries at a time
+func startManagers(t *testing.T, pageSize int) (ManagerSharedState, *mockapi.Server, *mockapi.Faults) {
+  t.Helper()
+  api := mockapi.NewTestServer(t, pageSize)
+  cl, err := client.NewClientWithResponses(api.URL)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return ManagerSharedState{
+    limiter: rate.NewLimiter(rate.Inf, 0),
+    client:  cl,
+    db:      openTestDB(t),
+  }, api.Server, api.Faults
+}
+
+type newsRow struct {
+  ID          int32
+  PublishedAt int64
+  Message     string
+}
+
+func newsRows(t *testing.T, db *sql.DB, warID int) []newsRow {
+  t.Helper()
+  rows, err := db.Query("SELECT id, published_at, message FROM news WHERE war_id = $1 ORDER BY published_at", warID)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer rows.Close()
+  news := []newsRow{}
+  for rows.Next() {
+    row := newsRow{}
+    err := rows.Scan(&row.ID, &row.PublishedAt, &row.Message)
+    if err != nil {
+      t.Fatal(err)
+    }
+    news = append(news, row)
+  }
+  if err := rows.Err(); err != nil {
+    t.Fatal(err)
+  }
+  return news
+}
+
+// The news table must hold the English news feed of the war, in order
+func assertNews(t *testing.T, db *sql.DB, entries []client.NewsEntry) {
+  t.Helper()
+  rows := newsRows(t, db, 801)
+  if len(rows) != len(entries) {
+    t.Fatalf("got %d news, want %d: %+v", len(rows), len(entries), rows)
+  }
+  for i, entry := range entries {
+    want := newsRow{ID: entry.Id, PublishedAt: entry.Published, Message: entry.Message}
+    if rows[i] != want {
+      t.Errorf("news %d: got %+v, want %+v", i, rows[i], want)
+    }
+  }
+}
+
+func TestNewsManagerReconcile(t *testing.T) {
+  state, server, _ := startManagers(t, 2)
+  ctx := context.Background()
+  request := NewsManagerRequest{WarID: 801}
+  var entries []client.NewsEntry
+  server.Update(801, func(war *mockapi.War) {
+    entries = append(entries, war.News[mockapi.DefaultLanguage]...)
+  })
+
+  // 5 news, 2 per page
+  for i := 0; i < 3; i++ {
+    err := NewsManagerReconcile(ctx, state, request)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  assertNews(t, state.db, entries)
+
+  // Nothing left to reconcile
+  err := NewsManagerReconcile(ctx, state, request)
+  if err != nil {
+    t.Fatal(err)
+  }
+  assertNews(t, state.db, entries)
+
+  // A news is published
+  published := client.NewsEntry{Id: 2900, Published: 8650000, Message: "Angel's Venture has been defended.", TagIds: []interface{}{}}
+  server.Update(801, func(war *mockapi.War) {
+    war.News[mockapi.DefaultLanguage] = append(war.News[mockapi.DefaultLanguage], published)
+  })
+  entries = append(entries, published)
+  err = NewsManagerReconcile(ctx, state, request)
+  if err != nil {
+    t.Fatal(err)
+  }
+  assertNews(t, state.db, entries)
+}
+
+func TestNewsManagerReconcileFaults(t *testing.T) {
+  state, _, faults := startManagers(t, 10)
+  ctx := context.Background()
+  request := NewsManagerRequest{WarID: 801}
+
+  profiles := map[string]mockapi.Profile{
+    "server error":   {"news": {ErrorRate: 1}},
+    "rate limited":   {"news": {RateLimitRate: 1}},
+    "malformed body": {"news": {MalformedRate: 1}},
+  }
+  for name, profile := range profiles {
+    t.Run(name, func(t *testing.T) {
+      faults.Activate("none")
+      err := faults.Set(profile)
+      if err != nil {
+        t.Fatal(err)
+      }
+      err = NewsManagerReconcile(ctx, state, request)
+      if err == nil {
+        t.Fatal("reconcile succeeded, want an error")
+      }
+      assertNews(t, state.db, nil)
+    })
+  }
+}
+
+type globalEventRow struct {
+  EventID       int32
+  Revision      int
+  Title         string
+  Message       string
+  PlanetIndices string
+  FirstSeenAt   int64
+  LastSeenAt    int64
+}
+
+func globalEventRows(t *testing.T, db *sql.DB, warID int) []globalEventRow {
+  t.Helper()
+  rows, err := db.Query("SELECT event_id, revision, title, message, planet_indices, first_seen_at, last_seen_at FROM global_events WHERE war_id = $1 ORDER BY event_id, revision", warID)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer rows.Close()
+  events := []globalEventRow{}
+  for rows.Next() {
+    row := globalEventRow{}
+    err := rows.Scan(&row.EventID, &row.Revision, &row.Title, &row.Message, &row.PlanetIndices, &row.FirstSeenAt, &row.LastSeenAt)
+    if err != nil {
+      t.Fatal(err)
+    }
+    events = append(events, row)
+  }
+  if err := rows.Err(); err != nil {
+    t.Fatal(err)
+  }
+  return events
+}
+
+func assertGlobalEvents(t *testing.T, db *sql.DB, want []globalEventRow) {
+  t.Helper()
+  rows := globalEventRows(t, db, 801)
+  if len(rows) != len(want) {
+    t.Fatalf("got %d global event revisions, want %d: %+v", len(rows), len(want), rows)
+  }
+  for i := range want {
+    if rows[i] != want[i] {
+      t.Errorf("global event revision %d: got %+v, want %+v", i, rows[i], want[i])
+    }
+  }
+}
+
+func TestGlobalEventsManagerReconcile(t *testing.T) {
+  state, server, _ := startManagers(t, 10)
+  ctx := context.Background()
+  request := GlobalEventsManagerRequest{WarID: 801}
+  const start = 8641200
+  briefing := "Liberate Atrama and hold Angel's Venture against the Automatons."
+  story := "Automaton forces are massing around Angel's Venture. Reinforce the defense before the planet falls."
+
+  err := GlobalEventsManagerReconcile(ctx, state, request)
+  if err != nil {
+    t.Fatal(err)
+  }
+  assertGlobalEvents(t, state.db, []globalEventRow{
+    {1225, 1, "MAJOR ORDER", briefing, "[41,127]", start, start},
+    {1226, 1, "BRIEFING", story, "[127]", start, start},
+  })
+
+  // The story event is rewritten
+  rewritten := "Angel's Venture is holding. Push the Automatons back."
+  server.Update(801, func(war *mockapi.War) {
+    war.Status.Time += 60
+    war.Status.GlobalEvents[1].Message = rewritten
+  })
+  err = GlobalEventsManagerReconcile(ctx, state, request)
+  if err != nil {
+    t.Fatal(err)
+  }
+  assertGlobalEvents(t, state.db, []globalEventRow{
+    {1225, 1, "MAJOR ORDER", briefing, "[41,127]", start, start + 60},
+    {1226, 1, "BRIEFING", story, "[127]", start, start},
+    {1226, 2, "BRIEFING", rewritten, "[127]", start + 60, start + 60},
+  })
+
+  // The major order briefing is withdrawn, its history is kept
+  server.Update(801, func(war *mockapi.War) {
+    war.Status.Time += 60
+    war.Status.GlobalEvents = war.Status.GlobalEvents[1:]
+  })
+  err = GlobalEventsManagerReconcile(ctx, state, request)
+  if err != nil {
+    t.Fatal(err)
+  }
+  assertGlobalEvents(t, state.db, []globalEventRow{
+    {1225, 1, "MAJOR ORDER", briefing, "[41,127]", start, start + 60},
+    {1226, 1, "BRIEFING", story, "[127]", start, start},
+    {1226, 2, "BRIEFING", rewritten, "[127]", start + 60, start + 120},
+  })
+}
+
+func TestGlobalEventsManagerReconcileFaults(t *testing.T) {
+  state, _, faults := startManagers(t, 10)
+  ctx := context.Background()
+  request := GlobalEventsManagerRequest{WarID: 801}
+
+  profiles := map[string]mockapi.Profile{
+    "server error":   {"war_status": {ErrorRate: 1}},
+    "malformed body": {"war_status": {MalformedRate: 1}},
+  }
+  for name, profile := range profiles {
+    t.Run(name, func(t *testing.T) {
+      faults.Activate("none")
+      err := faults.Set(profile)
+      if err != nil {
+        t.Fatal(err)
+      }
+      err = GlobalEventsManagerReconcile(ctx, state, request)
+      if err == nil {
+        t.Fatal("reconcile succeeded, want an error")
+      }
+      assertGlobalEvents(t, state.db, nil)
+    })
+  }
+}
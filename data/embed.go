@@ -0,0 +1,15 @@
+// Package data embeds the static reference data shipped with the dashboard,
+// so the binaries work without the json files on disk.
+package data
+
+import _ "embed"
+
+// Planet reference data (name, sector, biome, hazards), indexed by planet index
+//
+//go:embed planets.json
+var Planets []byte
+
+// Sector names, indexed by sector index (`PlanetInfo.Sector` of the war info)
+//
+//go:embed sectors.json
+var Sectors []byte
@@ -0,0 +1,61 @@
+package data
+
+import "encoding/json"
+
+// Reference data of a planet, from planets.json.
+// Entries are either a plain name (legacy format) or an object.
+type Planet struct {
+  Name    string   `json:"name"`
+  Sector  string   `json:"sector,omitempty"`
+  Biome   string   `json:"biome,omitempty"`
+  Hazards []string `json:"hazards,omitempty"`
+}
+
+func (p *Planet) UnmarshalJSON(b []byte) error {
+  name := ""
+  if err := json.Unmarshal(b, &name); err == nil {
+    *p = Planet{Name: name}
+    return nil
+  }
+  // helldivers-2/json names the hazards "environmentals"
+  type object Planet
+  obj := struct {
+    object
+    Environmentals []string `json:"environmentals,omitempty"`
+  }{}
+  if err := json.Unmarshal(b, &obj); err != nil {
+    return err
+  }
+  *p = Planet(obj.object)
+  if len(p.Hazards) == 0 {
+    p.Hazards = obj.Environmentals
+  }
+  return nil
+}
+
+// Overlay the non empty attributes of `other` on top of the reference
+func (p Planet) Merge(other Planet) Planet {
+  if other.Name != "" {
+    p.Name = other.Name
+  }
+  if other.Sector != "" {
+    p.Sector = other.Sector
+  }
+  if other.Biome != "" {
+    p.Biome = other.Biome
+  }
+  if len(other.Hazards) > 0 {
+    p.Hazards = other.Hazards
+  }
+  return p
+}
+
+// Decode the planet reference data embedded in the binary
+func EmbeddedPlanets() (map[int32]Planet, error) {
+  planets := map[int32]Planet{}
+  err := json.Unmarshal(Planets, &planets)
+  if err != nil {
+    return nil, err
+  }
+  return planets, nil
+}
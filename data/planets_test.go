@@ -0,0 +1,38 @@
+package data
+
+import (
+  "encoding/json"
+  "reflect"
+  "testing"
+)
+
+func TestPlanetUnmarshal(t *testing.T) {
+  tests := []struct {
+    name    string
+    content string
+    want    Planet
+  }{
+    {"legacy name", `"Klen Dahth II"`, Planet{Name: "Klen Dahth II"}},
+    {"object", `{"name": "Hellmire", "sector": "Severin", "biome": "Volcanic", "hazards": ["Intense Heat"]}`,
+      Planet{Name: "Hellmire", Sector: "Severin", Biome: "Volcanic", Hazards: []string{"Intense Heat"}}},
+    {"environmentals", `{"name": "Hellmire", "environmentals": ["Fire Tornadoes"]}`,
+      Planet{Name: "Hellmire", Hazards: []string{"Fire Tornadoes"}}},
+    {"hazards first", `{"name": "Hellmire", "hazards": ["Intense Heat"], "environmentals": ["Fire Tornadoes"]}`,
+      Planet{Name: "Hellmire", Hazards: []string{"Intense Heat"}}},
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      got := Planet{}
+      err := json.Unmarshal([]byte(test.content), &got)
+      if err != nil {
+        t.Fatal(err)
+      }
+      if !reflect.DeepEqual(got, test.want) {
+        t.Errorf("got %+v, want %+v", got, test.want)
+      }
+    })
+  }
+  if err := json.Unmarshal([]byte(`42`), &Planet{}); err == nil {
+    t.Error("a number must be rejected")
+  }
+}
@@ -163,8 +163,45 @@ type EventTypeEnum int
 // * `3`: Automatons
 type FactionEnum int
 
-// GlobalEvent Placeholder object from WarSeasonStatus, purpose unknown
-type GlobalEvent = map[string]interface{}
+// GlobalEvent In-game event message, e.g. a major order briefing or a story event
+type GlobalEvent struct {
+	// AssignmentId32 ID of the assignment the event is about, 0 when there is none
+	AssignmentId32 *int64 `json:"assignmentId32,omitempty"`
+
+	// EffectIds Purpose unknown
+	EffectIds *[]int32 `json:"effectIds,omitempty"`
+	EventId   int32    `json:"eventId"`
+
+	// Flag Purpose unknown
+	Flag int32 `json:"flag"`
+
+	// Id32 Purpose unknown
+	Id32 *int64 `json:"id32,omitempty"`
+
+	// Message Event message, in the language of the accept-language header
+	Message string `json:"message"`
+
+	// MessageId32 Identifier of the message translation
+	MessageId32 *int64 `json:"messageId32,omitempty"`
+
+	// PlanetIndices Planets the event is about
+	PlanetIndices []int32 `json:"planetIndices"`
+
+	// PortraitId32 Identifier of the portrait shown with the message, purpose unknown
+	PortraitId32 *int64 `json:"portraitId32,omitempty"`
+
+	// Race Identifier for a given Faction.
+	// * `1`: Super Earth
+	// * `2`: Terminids
+	// * `3`: Automatons
+	Race FactionEnum `json:"race"`
+
+	// Title Title of the event, may be empty
+	Title string `json:"title"`
+
+	// TitleId32 Identifier of the title translation
+	TitleId32 *int64 `json:"titleId32,omitempty"`
+}
 
 // JointOperation defines model for JointOperation.
 type JointOperation struct {
@@ -291,8 +328,9 @@ type SuperEarthWarResult = map[string]interface{}
 
 // WarSeasonInfo defines model for WarSeasonInfo.
 type WarSeasonInfo struct {
-	EndDate    int64 `json:"endDate"`
-	HomeWorlds []struct {
+	CapitalInfos []map[string]interface{} `json:"capitalInfos"`
+	EndDate      int64                    `json:"endDate"`
+	HomeWorlds   []struct {
 		PlanetIndices []int32 `json:"planetIndices"`
 
 		// Race Identifier for a given Faction.
@@ -303,10 +341,11 @@ type WarSeasonInfo struct {
 	} `json:"homeWorlds"`
 
 	// MinimumClientVersion Minimum client version. Does not seems to match client version. Follows semver format.
-	MinimumClientVersion string       `json:"minimumClientVersion"`
-	PlanetInfos          []PlanetInfo `json:"planetInfos"`
-	StartDate            int64        `json:"startDate"`
-	WarId                int32        `json:"warId"`
+	MinimumClientVersion   string                   `json:"minimumClientVersion"`
+	PermanentPlanetEffects []map[string]interface{} `json:"permanentPlanetEffects"`
+	PlanetInfos            []PlanetInfo             `json:"planetInfos"`
+	StartDate              int64                    `json:"startDate"`
+	WarId                  int32                    `json:"warId"`
 }
 
 // WarSeasonStatus defines model for WarSeasonStatus.
@@ -1249,65 +1288,69 @@ func RegisterHandlersWithBaseURL(router EchoRouter, si ServerInterface, baseURL
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/+RbW3Pbtpf/Kme4+7DbYWRd3MTRS8dJnMbd2MnYSv0Qe9Ij8khCAwIsAErRdPLddwDw",
-	"Lkqm0jZ7+eepFYGDc/mdK+A/g0gmqRQkjA6mfwY6WlGC7j/PI8PWdMEpMkyK95KzaHuxWFBk7NeYdKRY",
-	"aj8F0+A9x4hWksekQM5/p8jAQskE7lDdEmopbg2aTIeQZiqVmiATn4XciCAMzDalYBr4XcHXMDjXmi1F",
-	"QsKdkyqZkjKMHE/0JWWKLsUuB7cUSRFryIRhHLCkAW4LulVhsJAqQRNMAybM09PqdCYMLUnZ41lsifdY",
-	"mCq5VKQdX8xQoneZKqRFEcNcZp6/QvD6EZNx5xH5L6gUbu3/azKGieWuXhYclx3nv9/Rdo9D5ZqUYjG9",
-	"UIwWuzQr80D9QxjQF0xSbvn5GNwRrHBNEDMdWXoUw3lmZIJGCkg5Cg0LqUDLhMyKiSVEyDnFcB/MVgQ3",
-	"FHFMnM0G9wG8xNRkyq4yK2IKYqL0iU4xIohkkmhw+gEpYKak1AYS3IKiNSGHSDHDIuRgBeSAc5mZnIxj",
-	"YxA8lDrQxh5SV8GMGU4HVWC3gnHLmhq4Ov/l3Q28u3l1cdN5hKINKge1f1dWzcG/nVSeeJK74Ul10o1f",
-	"b0GB+vOrOj9/Ng5+y+ak0FCujG4BLY0mcPtxMUP9uQuY/v97U7Grvzol/JExRbHl25Foq76Nxl3pC1lK",
-	"jYa5MzzshJbWgcyuLZ04rGJL5WcPB8PTTWnCJjz877BUKAzFkKVS1AOSzqKItF5k3MI35ZTL0fRoTGQm",
-	"OiKtWRH4byAX4GX2KGyclx/Sz+NZPBnvnmQXKGFdJyZh2IKR6orfvcPYcUg/hBHHb1ioqI+RZjkHTQnt",
-	"r1aLUaYUiWgLJ4VC99muMti9eLNNpVmRZnp6LwB+gNHU5ru5FDEkFCO/d1FRZEkw/Th6eFxTYfDliV1+",
-	"jUkeRc9vPr14d/0qeGiI5JxwJwUc6YKoP3sVh8EaeUbNaPANuclTsTT/Iqlum+dMNs85bPpSxoMBHPVn",
-	"5z8hIOfgqQMqAiENOJDDlsy9+Dkjram09WgKZaS1mcQuqZl79PC4ed9evri4OZ9dfHr/9vz6Yta28qOc",
-	"H8306RSu8HepQKqYVJ3f0x7suoz2Kc9oX8PgBRrDyRZ2TBsW6V1IYmQ9C6NtZ8G4JQVuBUbbEBSKJWlf",
-	"NQ7BSBgNhwOYbVMJTLvsLexW5CEwY39KFWmrBiZsPocs1UYRJnD+/rJHoWelW8onAhP763nOhsUPFnXK",
-	"fzHOdc9acJ4tj1vOORn92mN8JyxJgxz8GljYNQP41Zs4lqSdjRM00Qo2zKzyhW+YAV3aIgTUvrpiCWmr",
-	"IEUOH4lUVJBeMWM/ocgP6Vcf57y/YeYRzi35How7JXwf1mNCs+ook19mScbRdjsgsmRue5gFrIjzmK1J",
-	"afD7bIEZZ2bb76yFYiRinntC87zr8pB81RY+W/TAT/BB6ExRT3kY51nCBBo6BnwJ05pJcesrhBs0HWHG",
-	"/mr5y9cW5cQAbqybtry0H7M5qRlL6DhG9VupzZFb7nxd3GOHojVbHzaSXwLaKDS4pERDpq1PfhARJ1QW",
-	"r2wBm5XkBIYw0cUOXBgb42DDUg/hyBYsveFqHcDFyY4YEXVBVuc9sE5tYJSiMF+f41pZt67IliWapqzF",
-	"vp3YuYvQVuhrRJOGwKWzVhZq+FQnjMNaxukqDV7KJMkEM9sZqiX9w2OMi3WexC9cij10lE2zYWcWbxaQ",
-	"uxB5jW44033EZVm3u2YbYcnWJCDfM7gXP8Bvo9+mcJulpOAClVm538a/TWFGKmGCxdr9MvltWvXvulHo",
-	"hONw0qN4uP3w/uLm08X5zexNEAazi5ury+vLV7dBGJx/mL27Op+9u751VcXPXM6RO+X9s/b5RTJh3qWU",
-	"z4Z2ipfVH9cypksR05c+g5VaCz586Nl1tVr308lk3G+rLzhL3mo0Jqd9KHR2wjWaYUP6Lle6po2+EEZt",
-	"dxW3I9f42fNn/eRKSGtcduQjhwcoPjcnLdcXd1CbttzfCze5dCOQHMXwUgqjJIfbrTaUdI5E0mzOmV51",
-	"FmS2EjGYpBATN+gxt0FlqxZl4KcmQ+PT0emPTycP/WI8Li/jQyPE146GmyC2QX1gDtNqGvgGtxqG39S+",
-	"d2Kl1FUpQVg0aoWRulDz3mEsHyx/h1Fyfp4xGH3uAFW8JNs+IMRMUWT7/aXCdDWAGyq6C52lKd+CpeNH",
-	"lrhGxnHOi64PuO8CbXoc7ExxYtLGpr88wrTi8yubtDEn1G+UomWmIvo7aLXsmhMOGyzvt2EZoJvyRpik",
-	"yJbish0DfhwOJ2f9ggCta93voWlGM8N+LSZ4RYX53Xx4RcjNqqutUMpiyH+39rGNag4biyWzYhqcuM3T",
-	"R8/PTk/H/3gW+b2RAfMw1BThLROfte/WLO8srrfdHhN5z24/37dI3gewYMTjokdXpFMpNIGS0lhn+Svz",
-	"pgS/vPm7FX86tP++Tw4OA4XRoxivF3jW/S1YvzPAHy8WKo/NhSp9om6mOvcNXw3rYaMLlvvD0KVYyN0o",
-	"FDNtI3T8aN0WAvrUuECuKYSELVcG5uQaPIeURWYyRR4rulLOXEpO6FIwKxDQx1kFMwz5u40gdaTlD8D9",
-	"Cr+wJEs64d4vq6RSs+4quCnZgks0FQXfeVoC2z7LWkCyyOnu0zRFRqrO0sB6sP8MLEdfn6zp73P0G9Sr",
-	"Y29J9wT9DW5TC1S9l03Hn5tgoYEIhcWVIoxWFlo+ZDJdmelvG5gXemkIXbNxnfdS101HLR2oBdn9juiL",
-	"so4u6htCdAiaKNFgpFVZhGlKMaCBkYvOQ1+GcV7b4SqvWnQ7m0xGo3GYb+iZSLs7vVmVOer3YC5AoAYE",
-	"RQtSJCJXSkqzImXznKum2mz1TQzyG+JD6gbqeq+m+RbQ1d31waYULRD2yVq0JPGelH9wsXueBxG4ZXkM",
-	"b5p3cC+upXGXEj/AldQGVlmCojBlZXz3ggCblFQ+mBxNzs4GZ2dnz2GzIgFCeumY2cIKNUg3B8pjOMJm",
-	"xTi5gUft0q4w6y79pYQshTlaG+esG5ZQPldDAylhRJbcufFf5AI2ill3C0EKvnV7TouRTq1mKtDgxjwO",
-	"RFI4MBfS/8fH08Hoqf33YwjjwTP77yy0vVsp8sN/3rcmDtWWcscwrNaHx4bmIoR4INayecv4Fey6IoOb",
-	"K7mx0h2qG9IZ/4fbvXJPd1lAIn6VT7v7VPUyoTupeJw3NikzyC3hQ936o9JYAjuyDOAiSc0WzmdXOy3k",
-	"1w4526VwyUxT3rJIY9Ffv949ulhtQSqvC5s8daEmJZWgpZs3mm5M8L9P5zvtCBO2CHvJGQnzKynd2fJf",
-	"+VUQuWWw9usG8Kq4HiuDn78na697LTmXGxsjk7Uf6yZoGtcJ/kvQNeAqK+b+L39qVXbXczRbzx/hUhtU",
-	"l3Ev/LXA4zfWDwxLb96j+qa8DXcOm968F3IPh0LMvoIH976aPOK91f6Xlx1WiJq3Gv2PaV+HdNBeVtP4",
-	"/nTrI/yuaJWkGJmrjBuWckbq2On6YDiePB09ffb0WY/E1m4n+0vRuh/oECTdmWYe61iNSej+E9z88mja",
-	"fuq5l+qRRq2P/fbSrJziCJr5ps74ItX2BaG57HygdhAm46fj8WQ8nvz4rN8QT+8WK/0F6ap0unJG5+Cm",
-	"aIVMOcApB2pzWjIhmFgWZegGFfwEt0WSYCIi1OQHdAijIWhDabvreP787Nnz4UN4VIje7YLs2VUL1Gq4",
-	"hqNvuHcqwrrxU6CdwNBGwK4zt8C8x4ytOBYeDNLdft32xY6w23KCfdnjwHupJXL8sv2kDZpHIbfz+qp0",
-	"Ql0RKMGLnL9bBNOPe0vEniOslgHr2zvkPVaCh8dGGw0FteXtePDr2nrfCrSf6NqSioDEkgly79MjKYzC",
-	"xjz7TdUpj0EuFixiyPMHZv7Nt213qzVBGKyLyi8YDoaDkevmUxKYsmAaTAajwdDyXTxDOrmmjX5NFJ/8",
-	"uUH1icVfHQz80wRZjUCDafAzmWLxXe42KSpMyLiu/+M+j718VXlqMD0bjqxKgqnjIQiD/AGcPz2oa9uo",
-	"jML870Gq96V1KOyfQJfj0xSX+V1SCCSMYqRhTgupbMziHOYEbClk/oSrZHOYM/lHRmpbcWkNUx4SHMfc",
-	"WxTLDJfk2KL6dbKunxyQePLhNsjPXxHGLg7lDGAUUWqe8JxWg4X6lXRBZKGevHbP/yvH0vLJ08nzJ6Pd",
-	"Cv3rg1W/vyFx6BgPh671lO7xo3PjNOUscvo8+V37BqPioFeqqi7ud53NPZGrK+0OFWhX7oKgjYYFUWzX",
-	"fQ2DE+u4+mSDqsTuic6SBP2TgH0YdrvuUDkM3+br/8eh/Fc1f0jhzZi/T8mNFVa9ZaNRqbeqrvZpt9zk",
-	"1evX/7/Xbr0fOwxi7RYN3HMQZEKDqyeg1KMOi6Ggv/AJwdUTQLagcFpSvqQIwVcU5bp8tkp5SQGpqymA",
-	"fPlQki2WtX/2VUUIZVEBxlcV/uFHfu/huT+AEGv2PN/1g0ix4V8DI36OcRAhtlyoA6QY/QsfwV0iW8mE",
-	"YONmCSHkowS/0VurmCaUWPLWLg23Htf+EMNy1yv7/zquNhUB9P++3Y78+7O+Sav6kx0fUN0doFoXWsoU",
-	"t9ndmFRPT04wZQPO1jRIlYwHZkXVDckSExpEMrFrbH363wEAAP//1pV0zC87AAA=",
+	"H4sIAAAAAAAC/+R7XXPbuJL2X+ni+17sTjGyJDuJo5spJ3EmPhs7KdsZX8SuTJtsSZiAAAcApaim/N+3",
+	"APCblEwlmZza3dycMyLQaHQ//Q3/HUQySaUgYXQw+zvQ0ZISdP/3JDJsRaecIsOk+CA5izan8zlFxn6N",
+	"SUeKpfZTMAs+cIxoKXlMCuT9nxQZmCuZwA2qK0ItxZVBk+kQ0kylUhNk4ouQaxGEgdmkFMwCvyt4CIMT",
+	"rdlCJCTcOamSKSnDyPFEX1Om6Ex0ObiiSIpYQyYM44AlDXBb0K0Kg7lUCZpgFjBhnh1VpzNhaEHKHs9i",
+	"S3zAwlTJhSLt+GKGEt1lqrgtihjuZeb5Ky5eP+Jw2ntE/gsqhRv735qMYWLRlcuc46Ln/A8daQ84VK5I",
+	"KRbTS8Vo3qVZqQfqH8KAvmKScsvPp+CGYIkrgpjpyNKjGE4yIxM0UkDKUWiYSwVaJmSWTCwgQs4phtvg",
+	"eklwSRHHxOlsdBvAK0xNpuwqsySmICZKn+gUI4JIJokGJx+QAq6VlNpAghtQtCLkEClmWIQc7AU54L3M",
+	"TE7GsTEK7koZaGMPqYvgmhlOO0Vgt4Jxy5oSOD/51/tLeH/5+vSy9whFa1QOav9fWTEH/++gssSD3AwP",
+	"qpMu/XoLCtRfXtf5+btx8Dt2TwoN5cLov6Cl0QTuMC6uUX/pA6b/78FU7OoHJ4S/MqYotnw7Em3Rt9HY",
+	"vX1xl1KiYW4Mdx3X0jqQxUHNiMPKt1R2drfTPV2WKmzCw/8OC4XCUAxZKkXdIeksikjrecYtfFNO+T2a",
+	"Fo2JzESPpzVLAv8N5Bz8nT0KG+flhwyzeBYfTrsn2QVKWNOJSRg2Z6T6/PdgN7Yf0ndhxPEbFiIaoqTr",
+	"nIPmDe2vVopRphSJaAMHhUC36a5S2K14u0mlWZJmenYrAH6ByczGu3spYkgoRn7rvKLIkmD2aXL3uKTC",
+	"4OsTu/wCk9yLnlx+fvn+4nVw17iSM8JOCNjTBFF/8SIOgxXyjJre4Btik6diaX4nqX6d50w2z9mt+vKO",
+	"Ox046i/OfkJAzsFTB1QEQhpwIIcNmVvxW0ZaU6nryQxKT2sjCZmGuid3j6v33dnL08uT69PPH96dXJxe",
+	"t7X8KOd7M300g3P8UyqQKiZV5/doALsuon3OI9pDGLxEYzjZxI5pwyLdhSRG1rIw2vQmjBtS4FZgtAlB",
+	"oViQ9lnjGIyEyXg8gutNKoFpF72F3Yo8BGbsT6kibcXAhI3nkKXaKMIETj6cDUj07O0W8onAxP56krNh",
+	"8YNFnvJfjHM9MBe8zxb7LeecjH7jMd5xS9IgB78G5nbNCH73Ko4laafjBE20hDUzy3zhW2ZAl7oIAbXP",
+	"rlhC2gpIkcNHIhUVpJfM2E8o8kOG5cc572+ZeYRzS34A404IP4f1mNAse9LkV1mScbTVDogsuSdlg8KS",
+	"OI/ZipQGv88mmHFmNsPOmitGIuaMes67KA/JV23gi0UP/Aofhc4UDbwP4zxLmEBD+4AvYVozKa58hnCJ",
+	"psfN2F8tf/naIp0YwaU105aVDmM2J3XNEtqPUf1OarPnlhspBu5QtGKr3UryS0AbhQYXlGjItLXJjyLi",
+	"hMrilc1hvZScwBAmutiBc2N9HKxZ6iEc2YRlMFytATg/2eMjoj7I6rwG1inZ9FAU6htyXCvq1gXZ0kRT",
+	"lTXf1/GdXYS2XF/DmzQuXBprpaGGTfXCOKxFnL7U4JVMkkwws7lGtaB/uI1xusqD+KkLsbuOsmE27I3i",
+	"zQSyC5E36Joz/UeclXm7K7YRFmxFAvI9o1vxC/wx+WMGV1lKCk5RmaX7bfrHDK5JJUywWLtfDv+YVfW7",
+	"biQ64TQ8HJA8XH38cHr5+fTk8vptEAbXp5fnZxdnr6+CMDj5eP3+/OT6/cWVyyp+4/IeuRNez43EkwUm",
+	"BGQ/Q0Ja44JCoNFiBAhJldvAvWI0ty0Dd3FtpNr4Xd1Cq8yoznrLoLPX1rRc2VXLvZYFE0z7pkIIY1gv",
+	"SeQBi9loJ2iYoZPrqp3Fgzo435OjO5bP4lbLYDKdPr0bVMjZyvoHdZn6a87dpLY5fY+DLrXTJkzyTJGj",
+	"WGS4oFKtUUSpeVL+vCSMSVUnVV2TnNIWnFTWlhPOl4NRKDTfowXp64kzEbOoLzJ9cJ91Dwi/Ex6pVEYh",
+	"M0MvWKwHvbQVh0vqahd/rFuwLSJj9GghW/d7LlL29ulcD6lg1kkqdL3BewJKUpfJ1cwgeHl5dvrm7OK3",
+	"/o6ZpTVUMG7xvnpvReDCVovbVTjPRZSbYxsufYHvX5IJ8z6lvA/eKdSWf13ImM5ETF+H2GRNaOO7gdbe",
+	"8jlHh4fTYVvL29HXFo3DoyEUert+NZph4/Z90rugtT4VRm26guvca/r8xfNh9xrmtVoQvTi9gVpn+fZW",
+	"uCmNa/fmERteSWGU5HC10YaSXjCn2T1netlbfLKEtMEkhZi4QZ//rFHZCk0Z+LXJ0PRocvT02eHdsHwW",
+	"F3mM2zIueeNouGlJ23Ps6Dm3GiR8jRsN429qVfZipZRVeYOwaEoVSupDjffS+RDtJ4zN8vOMwehLD6ji",
+	"BdkAiBAzRZEh1+NMlyO4pKKTorM05RsbH8mPZ3CFjOM9LzpcwH3Hy5YCo04iFZM2TJQepi+LwpzQsBRB",
+	"y0xF9CNotfSaEw4bLG/XYZmMNu8bYZIiW4hOPvV0PD48HuYEaFXr9O0KeM1q4qGYVhTV9E+z4SUhN8u+",
+	"FopSFkP+e5kgeNhYLJkl02UGXk8+XxwfHU3/8SjyZyMC9qba75j4oqskhsX1FqPHRN6ftJ9vWyRvA5gz",
+	"4nGRZSrSqRSaQElpRt+ZmCX49e2PFvzR2P77OTH4m5I6B9afDPDHk4XKYss0LLeJuprq3DdsNay7jT5Y",
+	"bndDZ2Iuu14oZtp66PjRvM2OClxonCPXFELCFktjU2HbzHJImWcmU3mirCvh3EvJCV0IZgUChhirYIYh",
+	"f78WpPbU/A64n+NXlmRJL9yHRZVUatafBTdvNucSTUXBd9ksgc2QZS0gWeT096Q0RUaqbUUe+M/AcvQN",
+	"iZp+dq3fol7+oAJ7jZvUAnVrLer5c916NBChsLhShNGSYm+czguVavphw8FCLo1L13Rc572UddNQSwNq",
+	"QXa7IfqkrKeK+gYXHYImSrRtpd8TRJimFAMa21e3/3waxnlthx41vdvx4eFkMg3zDQMDaX+ld11FjvrM",
+	"3zkI1ICgaE6KRORSSTv8VjbOuWyqzdbQwCC/wT+kbniot0qabwBd3l0f4kjRAuGQqEULEh9I+cdl3fM8",
+	"iMAty314U72jW3EhjRvA/gLnUhtYZgmKQpWV8t1rKWxSUvkQZnJ4fDw6Pj5+4RuMQvrbMbOBJWqQrued",
+	"+3CE9ZJxcs3d2gOFQq1d+gsJWQr3aHVcdi8SymcIaCAljMiSOzH+i5zDWjFrbiFIwTduz1HRvq7lTAUa",
+	"XEvbgUgKB+bi9v/x6Wg0eWb/PQ1hOnpu/x2HtnYrr3z3n7etjkO1pdwxDqv14b6uuXAhHoi1aN5SfgW7",
+	"Ps/geuiuhX6D6pJ0xv/hcq/c058WRJgyg9x+3FVxP8qRJdDhZwSntn0GJ9fnoz7eOm1nEb/Ox4xDSgyZ",
+	"0I1UvNUqaN6v0yL9nvR67+S0BaE8D3y8D9c+OGHCZjOvOCNhfiele2vnc78KIrcMVn7dCF4XM/XSi/jh",
+	"envdG8m5XFtnk6z8LChB05hB+i99Le+UVIJWEnkp7BoZ/35EpWVGPPwVYy2L7qHo8vU9ULpGdRYPwlsL",
+	"LH5j/cDKQLYgonnfhoWETUvfqrC7XS5kW0KDW1+A7/F2dCuNPi1EzQnt8GPao90e2otqsjicbn0c2UOT",
+	"JSlG5jzjhqWckdq3ez4aTw+fTZ49f/Z8QOBql4vDb9Hq/281qHq3cl/Dqu/dcYLrT+5N2+3aTnVPpdbb",
+	"eltpVkaxB818U69/kWrzknDLcG0nTKbPptPD6fTw6fNhTTrdTUaGX6Qvk+m5j+ltzBSljikbNGXD7J4W",
+	"TAg3kvdppu3X/ApXRexiIiLU5BtwCJMxaENpu6p48eL4+YvxXbiXi+5WOfbsqsRpFVTjyTfMlQq3bnyX",
+	"p+MY2gjoGnMLzFvU2PJj4U4n3W/XbVvscbstI9gWPXa8/Vwgx6+bz9qgeRRynZekpRHqikAJXuT8/TyY",
+	"fdqaEg5sUbUUWN/ec999b3D3WOuiIaD2fbsMPLiyfS67eL4km+kRkFgwQe5vbSIpjMJGv/ptVQlPQc7n",
+	"LGLI88ey+fg8qK8JwmBVJKTBeDQeTVy1npLAlAWz4HA0GY0t38WTygM7Jn1DFB/8vUb1mcUPDgb+mZWs",
+	"WpzBLPiNTLH4JjebFBUmZFxV/2mbxZ69riw1mB2PJ1YkwczxEIRB/pjXnx7UpW1URmH+t23VW/k6FLZ3",
+	"mMv2aIqLfFYUAgmjGGm4p7lU1mdxbvs3bCFk/hy1ZHOcM/lXRmpTcWkVUx4S7Mfcu+KpimWr8RZK108O",
+	"SDz5eBXk55fPWnIGWs9eGizUR84Fkbl68sb9KVNlWFo+eXb44smkWzg83IVBMQFx6JiOx/Z/LDDzYRqm",
+	"KWeRk+fBn9rXPRUHg0JVNZjvGttD2BLajR0PuHQXBK01zIliu+4hDA6s4eqDNaoSuwc6SxJUm10Ydrtu",
+	"UDkMX+Xr/+1Q/l7J7xJ40+dvE3JjhRVvWWhU4q2yq23SLTd58fr1/+ulW6/HdoNYu0Uj99wDmdDg8gko",
+	"5WjbSL7p5wc6Ibh8AsgmFE5KyqcUIfiMolyX904pTykgdTkF+FeKFdliWftnn1WEUCYVYHxW4R92+FU5",
+	"9zsQYtWex7thECk2/N/AiO9j7ESITRfqAEmLWY334C6Q2VYCrF0vIYS8leA3em0V3YRic67tUnGrae2P",
+	"yix3g6L/79NqU+FA/+frbc+/pR0atKpnx96huhmfWhVSyhS30d2YVM8ODjBlI85WNEqVjEdmSdUExL6c",
+	"HkUysWtsfvrfAwCSqxWx+z8AAA==",
 }
 
 // GetSwagger returns the content of the embedded swagger specification file
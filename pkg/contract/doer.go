@@ -0,0 +1,99 @@
+package contract
+
+import (
+  "bytes"
+  "context"
+  "io"
+  "log/slog"
+  "net/http"
+  "sync"
+  "time"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Options of the checks of a Doer
+type Options struct {
+  // Log the differences between the responses and the spec
+  Validate bool
+  // Record the shapes of the fields missing from the spec, when set
+  Drift *DriftTracker
+}
+
+// Doer wraps an HttpRequestDoer and checks the successful responses of the
+// routes of the spec. Responses are passed through unchanged.
+type Doer struct {
+  next      client.HttpRequestDoer
+  validator *Validator
+  opts      Options
+  mu        sync.Mutex
+  // Problems already reported, logged again at debug level only
+  seen map[string]bool
+}
+
+// New wraps `next`, checking its responses with `validator`
+func New(next client.HttpRequestDoer, validator *Validator, opts Options) *Doer {
+  return &Doer{next: next, validator: validator, opts: opts, seen: map[string]bool{}}
+}
+
+// Wrap `next` with a checking Doer when any check is enabled, returns `next` otherwise
+func Wrap(next client.HttpRequestDoer, opts Options) (client.HttpRequestDoer, error) {
+  if !opts.Validate && opts.Drift == nil {
+    return next, nil
+  }
+  validator, err := NewValidator()
+  if err != nil {
+    return nil, err
+  }
+  return New(next, validator, opts), nil
+}
+
+func (d *Doer) Do(req *http.Request) (*http.Response, error) {
+  resp, err := d.next.Do(req)
+  if err != nil || resp.StatusCode != http.StatusOK {
+    return resp, err
+  }
+  route, ok := d.validator.Route(req.URL.Path)
+  if !ok {
+    return resp, nil
+  }
+  body, err := io.ReadAll(resp.Body)
+  resp.Body.Close()
+  if err != nil {
+    return nil, err
+  }
+  resp.Body = io.NopCloser(bytes.NewReader(body))
+
+  result, err := d.validator.Check(route, body)
+  if err != nil {
+    slog.Error("Failed to validate response", slog.String("route", route), slog.Any("error", err))
+    return resp, nil
+  }
+  if d.opts.Validate {
+    d.report(result.Problems)
+  }
+  if d.opts.Drift != nil {
+    d.opts.Drift.Observe(route, result.Fields, time.Now())
+  }
+  return resp, nil
+}
+
+// Log the problems of a response, at warning level the first time they are seen
+func (d *Doer) report(problems []Problem) {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+  for _, problem := range problems {
+    level := slog.LevelDebug
+    if !d.seen[problem.Key()] {
+      d.seen[problem.Key()] = true
+      level = slog.LevelWarn
+    }
+    slog.Log(context.Background(), level, "Response does not match the API spec",
+      slog.String("route", problem.Route),
+      slog.String("kind", string(problem.Kind)),
+      slog.String("field", problem.Field),
+      slog.String("message", problem.Message),
+      slog.Int("count", problem.Count),
+    )
+  }
+}
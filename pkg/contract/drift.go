@@ -0,0 +1,189 @@
+package contract
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/fs"
+  "log/slog"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "sync"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus"
+)
+
+// Shape of a field missing from the spec, as observed in the responses of a route
+type Shape struct {
+  Route string `json:"route"`
+  ObservedField
+  FirstSeen time.Time `json:"first_seen"`
+  LastSeen  time.Time `json:"last_seen"`
+  // Number of responses the shape was seen in
+  Responses int64 `json:"responses"`
+}
+
+func (s Shape) key() string {
+  return s.Route + " " + s.ObservedField.Key()
+}
+
+// DriftReport lists every shape observed, to grow spec.yaml from
+type DriftReport struct {
+  Shapes []Shape `json:"shapes"`
+}
+
+// Minimum interval between two writes of the report file, when no new shape appears
+const driftWriteInterval = time.Minute
+
+// Name of the metric exported by the DriftTracker
+const DriftFirstSeenName = "hde_schema_drift_first_seen_timestamp_seconds"
+
+var driftFirstSeenDesc = prometheus.NewDesc(
+  DriftFirstSeenName,
+  "Timestamp of the first appearance of a field missing from spec.yaml in the API responses, by route, kind (placeholder, undocumented), field and JSON type",
+  []string{"route", "kind", "field", "type"}, nil,
+)
+
+// DriftTracker records the shapes of the fields missing from the spec:
+// the keys and value types of the placeholder objects, and the undocumented
+// fields of the other objects. First appearances are logged, and exported
+// by the tracker as a Prometheus collector.
+type DriftTracker struct {
+  mu     sync.Mutex
+  shapes map[string]*Shape
+  // Report file, empty when the report is only kept in memory
+  path      string
+  lastWrite time.Time
+}
+
+// Tracker persisting its report to `path` when set. Shapes of an existing
+// report are loaded, and not logged as new again.
+func NewDriftTracker(path string) (*DriftTracker, error) {
+  tracker := &DriftTracker{shapes: map[string]*Shape{}, path: path}
+  if path == "" {
+    return tracker, nil
+  }
+  content, err := os.ReadFile(path)
+  if errors.Is(err, fs.ErrNotExist) {
+    return tracker, nil
+  }
+  if err != nil {
+    return nil, err
+  }
+  report := DriftReport{}
+  err = json.Unmarshal(content, &report)
+  if err != nil {
+    return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+  }
+  for _, shape := range report.Shapes {
+    shape := shape
+    tracker.shapes[shape.key()] = &shape
+  }
+  slog.Info("Loaded schema drift report", slog.String("file", path), slog.Int("shapes", len(report.Shapes)))
+  return tracker, nil
+}
+
+// Record the fields of a response of `route`, observed at `at`
+func (t *DriftTracker) Observe(route string, fields []ObservedField, at time.Time) {
+  added := []Shape{}
+  t.mu.Lock()
+  for _, field := range fields {
+    observed := Shape{Route: route, ObservedField: field}
+    shape, ok := t.shapes[observed.key()]
+    if !ok {
+      observed.FirstSeen = at
+      shape = &observed
+      t.shapes[observed.key()] = shape
+      added = append(added, observed)
+    }
+    shape.LastSeen = at
+    shape.Responses++
+  }
+  if t.path != "" && len(fields) > 0 && (len(added) > 0 || at.Sub(t.lastWrite) >= driftWriteInterval) {
+    err := t.write()
+    if err != nil {
+      slog.Error("Failed to write the schema drift report", slog.String("file", t.path), slog.Any("error", err))
+    }
+    t.lastWrite = at
+  }
+  t.mu.Unlock()
+
+  for _, shape := range added {
+    slog.Warn("New field in the API responses",
+      slog.String("route", shape.Route),
+      slog.String("kind", string(shape.Kind)),
+      slog.String("field", shape.Field),
+      slog.String("type", shape.Type),
+      slog.String("example", shape.Example),
+    )
+  }
+}
+
+func (t *DriftTracker) Describe(ch chan<- *prometheus.Desc) {
+  ch <- driftFirstSeenDesc
+}
+
+// Export the first appearance of every shape
+func (t *DriftTracker) Collect(ch chan<- prometheus.Metric) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  for _, shape := range t.shapes {
+    ch <- prometheus.MustNewConstMetric(
+      driftFirstSeenDesc, prometheus.GaugeValue, float64(shape.FirstSeen.Unix()),
+      shape.Route, string(shape.Kind), shape.Field, shape.Type,
+    )
+  }
+}
+
+// Report of the shapes observed, sorted by route, kind and field
+func (t *DriftTracker) Report() DriftReport {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  return t.report()
+}
+
+func (t *DriftTracker) report() DriftReport {
+  report := DriftReport{Shapes: make([]Shape, 0, len(t.shapes))}
+  for _, shape := range t.shapes {
+    report.Shapes = append(report.Shapes, *shape)
+  }
+  sort.Slice(report.Shapes, func(i, j int) bool {
+    return report.Shapes[i].key() < report.Shapes[j].key()
+  })
+  return report
+}
+
+// Write the report file, replacing it atomically
+func (t *DriftTracker) write() error {
+  content, err := json.MarshalIndent(t.report(), "", "  ")
+  if err != nil {
+    return err
+  }
+  tmp, err := os.CreateTemp(filepath.Dir(t.path), filepath.Base(t.path)+".*")
+  if err != nil {
+    return err
+  }
+  defer os.Remove(tmp.Name())
+  _, err = tmp.Write(content)
+  if closeErr := tmp.Close(); err == nil {
+    err = closeErr
+  }
+  if err != nil {
+    return err
+  }
+  return os.Rename(tmp.Name(), t.path)
+}
+
+// Serve the report as JSON
+func (t *DriftTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  w.Header().Set("Content-Type", "application/json")
+  encoder := json.NewEncoder(w)
+  encoder.SetIndent("", "  ")
+  err := encoder.Encode(t.Report())
+  if err != nil {
+    slog.Error("Failed to write the schema drift report", slog.Any("error", err))
+  }
+}
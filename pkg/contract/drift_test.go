@@ -0,0 +1,159 @@
+package contract
+
+import (
+  "bytes"
+  "encoding/json"
+  "log/slog"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Capture the logs of the test in a buffer
+func captureLogs(t *testing.T) *bytes.Buffer {
+  t.Helper()
+  logs := &bytes.Buffer{}
+  previous := slog.Default()
+  slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+  t.Cleanup(func() { slog.SetDefault(previous) })
+  return logs
+}
+
+// Report of the file written by a tracker
+func readReport(t *testing.T, path string) DriftReport {
+  t.Helper()
+  content, err := os.ReadFile(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  report := DriftReport{}
+  err = json.Unmarshal(content, &report)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return report
+}
+
+var (
+  regenField = ObservedField{Kind: UndocumentedObjectField, Field: "planetStatus[].regen", Type: "number", Example: "1.5"}
+  flagField  = ObservedField{Kind: PlaceholderField, Field: "flags.enabled", Type: "boolean", Example: "true"}
+)
+
+func TestDriftTrackerFirstSeen(t *testing.T) {
+  logs := captureLogs(t)
+  tracker, err := NewDriftTracker("")
+  if err != nil {
+    t.Fatal(err)
+  }
+  t0 := time.Unix(1700000000, 0)
+  tracker.Observe("/WarSeason/{war_id}/Status", []ObservedField{regenField}, t0)
+  tracker.Observe("/WarSeason/{war_id}/Status", []ObservedField{regenField}, t0.Add(time.Minute))
+  tracker.Observe("/WarSeason/{war_id}/Status", []ObservedField{regenField, flagField}, t0.Add(2*time.Minute))
+
+  if count := strings.Count(logs.String(), "New field in the API responses"); count != 2 {
+    t.Errorf("got %d new field logs, want 2:\n%s", count, logs)
+  }
+  report := tracker.Report()
+  if len(report.Shapes) != 2 {
+    t.Fatalf("got %d shapes, want 2", len(report.Shapes))
+  }
+  // Sorted by route, kind and field
+  flag, regen := report.Shapes[0], report.Shapes[1]
+  if flag.ObservedField != flagField || !flag.FirstSeen.Equal(t0.Add(2*time.Minute)) || flag.Responses != 1 {
+    t.Errorf("placeholder field: got %+v", flag)
+  }
+  if regen.ObservedField != regenField || !regen.FirstSeen.Equal(t0) || !regen.LastSeen.Equal(t0.Add(2*time.Minute)) || regen.Responses != 3 {
+    t.Errorf("undocumented field: got %+v", regen)
+  }
+
+  expected := `
+# HELP hde_schema_drift_first_seen_timestamp_seconds Timestamp of the first appearance of a field missing from spec.yaml in the API responses, by route, kind (placeholder, undocumented), field and JSON type
+# TYPE hde_schema_drift_first_seen_timestamp_seconds gauge
+hde_schema_drift_first_seen_timestamp_seconds{field="flags.enabled",kind="placeholder",route="/WarSeason/{war_id}/Status",type="boolean"} 1.70000012e+09
+hde_schema_drift_first_seen_timestamp_seconds{field="planetStatus[].regen",kind="undocumented",route="/WarSeason/{war_id}/Status",type="number"} 1.7e+09
+`
+  err = testutil.CollectAndCompare(tracker, strings.NewReader(expected))
+  if err != nil {
+    t.Error(err)
+  }
+}
+
+func TestDriftTrackerPersistence(t *testing.T) {
+  logs := captureLogs(t)
+  path := filepath.Join(t.TempDir(), "drift.json")
+  tracker, err := NewDriftTracker(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t0 := time.Unix(1700000000, 0)
+  tracker.Observe("/WarSeason/{war_id}/Status", []ObservedField{regenField}, t0)
+
+  // Restarted: the shapes are loaded, exported and not logged as new again
+  restarted, err := NewDriftTracker(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if count := testutil.CollectAndCount(restarted); count != 1 {
+    t.Errorf("got %d series after a restart, want 1", count)
+  }
+  logs.Reset()
+  restarted.Observe("/WarSeason/{war_id}/Status", []ObservedField{regenField}, t0.Add(2*time.Minute))
+  if strings.Contains(logs.String(), "New field in the API responses") {
+    t.Errorf("a loaded shape was logged as new:\n%s", logs)
+  }
+  shapes := restarted.Report().Shapes
+  if len(shapes) != 1 || !shapes[0].FirstSeen.Equal(t0) || shapes[0].Responses != 2 {
+    t.Errorf("got shapes %+v after a restart", shapes)
+  }
+
+  err = os.WriteFile(path, []byte(`{"shapes": `), 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  _, err = NewDriftTracker(path)
+  if err == nil {
+    t.Error("an invalid report must be rejected")
+  }
+}
+
+// Known shapes only rewrite the report once per driftWriteInterval,
+// new shapes are written right away
+func TestDriftTrackerThrottledWrites(t *testing.T) {
+  captureLogs(t)
+  path := filepath.Join(t.TempDir(), "drift.json")
+  tracker, err := NewDriftTracker(path)
+  if err != nil {
+    t.Fatal(err)
+  }
+  t0 := time.Unix(1700000000, 0)
+  route := "/WarSeason/{war_id}/Status"
+  responses := func() int64 {
+    return readReport(t, path).Shapes[0].Responses
+  }
+
+  tracker.Observe(route, []ObservedField{regenField}, t0)
+  if value := responses(); value != 1 {
+    t.Errorf("first write: got %d responses, want 1", value)
+  }
+  tracker.Observe(route, []ObservedField{regenField}, t0.Add(10*time.Second))
+  if value := responses(); value != 1 {
+    t.Errorf("throttled write: got %d responses, want 1", value)
+  }
+  // Responses without undocumented fields do not write the report
+  tracker.Observe(route, nil, t0.Add(2*driftWriteInterval))
+  if value := responses(); value != 1 {
+    t.Errorf("write without fields: got %d responses, want 1", value)
+  }
+  tracker.Observe(route, []ObservedField{regenField, flagField}, t0.Add(20*time.Second))
+  if shapes := readReport(t, path).Shapes; len(shapes) != 2 {
+    t.Errorf("new shape: got %d shapes written, want 2", len(shapes))
+  }
+  tracker.Observe(route, []ObservedField{regenField}, t0.Add(20*time.Second+driftWriteInterval))
+  if value := readReport(t, path).Shapes[1].Responses; value != 4 {
+    t.Errorf("write after the interval: got %d responses, want 4", value)
+  }
+}
@@ -0,0 +1,411 @@
+// Package contract checks the responses of the upstream API against the
+// schemas of spec.yaml, to catch upstream drift before it reaches the metrics.
+package contract
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "math"
+  "sort"
+  "strings"
+
+  "github.com/getkin/kin-openapi/openapi3"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Kind of difference between a response and its schema
+type Kind string
+
+const (
+  // A required field is absent
+  MissingField Kind = "missing_field"
+  // A value does not have the type of its schema, e.g. a string instead of an integer
+  TypeMismatch Kind = "type_mismatch"
+  // A field is not documented in the schema
+  UndocumentedField Kind = "undocumented_field"
+  // The body is not JSON
+  InvalidJSON Kind = "invalid_json"
+)
+
+// Problem is a difference between a response and the schema of its route
+type Problem struct {
+  // Path of the route in the spec, e.g. /WarSeason/{war_id}/Status
+  Route string `json:"route"`
+  Kind  Kind   `json:"kind"`
+  // Field of the body, array items are written [], e.g. planetStatus[].health.
+  // Empty for the body itself.
+  Field   string `json:"field"`
+  Message string `json:"message,omitempty"`
+  // Number of occurrences in the response, e.g. once per array item
+  Count int `json:"count"`
+}
+
+func (p Problem) String() string {
+  field := p.Field
+  if field == "" {
+    field = "(body)"
+  }
+  description := fmt.Sprintf("%s %s %s", p.Route, p.Kind, field)
+  if p.Message != "" {
+    description += ": " + p.Message
+  }
+  return description
+}
+
+// Key identifying the same problem across responses
+func (p Problem) Key() string {
+  return p.Route + " " + string(p.Kind) + " " + p.Field
+}
+
+// Kind of a field missing from the spec
+type FieldKind string
+
+const (
+  // Field of a placeholder object, an object of the spec without documented properties
+  PlaceholderField FieldKind = "placeholder"
+  // Field of an object with documented properties
+  UndocumentedObjectField FieldKind = "undocumented"
+)
+
+// ObservedField is the shape of a field missing from the spec, or of a value
+// nested in such a field
+type ObservedField struct {
+  Kind FieldKind `json:"kind"`
+  // Path of the field, as in Problem
+  Field string `json:"field"`
+  // JSON type of the value: object, array, string, integer, number, boolean or null
+  Type string `json:"type"`
+  // Value of the field when it is a scalar, JSON encoded
+  Example string `json:"example,omitempty"`
+}
+
+// Key identifying the same shape across responses
+func (f ObservedField) Key() string {
+  return string(f.Kind) + " " + f.Field + " " + f.Type
+}
+
+// Result of the check of a response
+type Result struct {
+  // Problems, sorted by field and counted once per distinct field
+  Problems []Problem
+  // Shapes of the fields missing from the spec, sorted by kind and field
+  Fields []ObservedField
+}
+
+// A route of the spec and the schema of its successful responses
+type route struct {
+  path     string
+  segments []string
+  schema   *openapi3.Schema
+}
+
+// Validator checks response bodies against the schemas of the spec
+type Validator struct {
+  routes []route
+}
+
+// Validator of the spec embedded in the generated client
+func NewValidator() (*Validator, error) {
+  spec, err := client.GetSwagger()
+  if err != nil {
+    return nil, fmt.Errorf("failed to load the API spec: %w", err)
+  }
+  return newValidator(spec), nil
+}
+
+// Validator of the JSON responses of the GET routes of a spec
+func newValidator(spec *openapi3.T) *Validator {
+  validator := &Validator{}
+  for path, item := range spec.Paths.Map() {
+    if item.Get == nil || item.Get.Responses == nil {
+      continue
+    }
+    response := item.Get.Responses.Status(200)
+    if response == nil || response.Value == nil {
+      continue
+    }
+    media := response.Value.Content.Get("application/json")
+    if media == nil || media.Schema == nil {
+      continue
+    }
+    validator.routes = append(validator.routes, route{
+      path:     path,
+      segments: strings.Split(strings.Trim(path, "/"), "/"),
+      schema:   media.Schema.Value,
+    })
+  }
+  sort.Slice(validator.routes, func(i, j int) bool {
+    return validator.routes[i].path < validator.routes[j].path
+  })
+  return validator
+}
+
+// Paths of the routes of the spec
+func (v *Validator) Routes() []string {
+  paths := make([]string, 0, len(v.routes))
+  for _, route := range v.routes {
+    paths = append(paths, route.path)
+  }
+  return paths
+}
+
+// Route of the spec matching the path of a request URL, which may have a base
+// path, e.g. /api/WarSeason/801/Status matches /WarSeason/{war_id}/Status
+func (v *Validator) Route(urlPath string) (string, bool) {
+  segments := strings.Split(strings.Trim(urlPath, "/"), "/")
+  for _, route := range v.routes {
+    offset := len(segments) - len(route.segments)
+    if offset < 0 {
+      continue
+    }
+    matches := true
+    for i, segment := range route.segments {
+      if !strings.HasPrefix(segment, "{") && segment != segments[offset+i] {
+        matches = false
+        break
+      }
+    }
+    if matches {
+      return route.path, true
+    }
+  }
+  return "", false
+}
+
+// Check a successful response body of a route of the spec, returned by Route.
+// Problems are sorted by field, and counted once per distinct field.
+func (v *Validator) Validate(routePath string, body []byte) ([]Problem, error) {
+  result, err := v.Check(routePath, body)
+  if err != nil {
+    return nil, err
+  }
+  return result.Problems, nil
+}
+
+// Check a successful response body of a route of the spec, returned by Route,
+// and collect the shapes of the fields missing from the spec
+func (v *Validator) Check(routePath string, body []byte) (*Result, error) {
+  var schema *openapi3.Schema
+  for _, route := range v.routes {
+    if route.path == routePath {
+      schema = route.schema
+    }
+  }
+  if schema == nil {
+    return nil, fmt.Errorf("unknown route %q", routePath)
+  }
+
+  decoder := json.NewDecoder(bytes.NewReader(body))
+  decoder.UseNumber()
+  var value interface{}
+  err := decoder.Decode(&value)
+  if err == nil && decoder.More() {
+    err = fmt.Errorf("trailing data after the JSON value")
+  }
+  if err != nil {
+    return &Result{Problems: []Problem{{Route: routePath, Kind: InvalidJSON, Message: err.Error(), Count: 1}}}, nil
+  }
+
+  w := &walker{route: routePath, problems: map[string]*Problem{}, fields: map[string]ObservedField{}}
+  w.walk(schema, value, "")
+  result := &Result{
+    Problems: make([]Problem, 0, len(w.problems)),
+    Fields:   make([]ObservedField, 0, len(w.fields)),
+  }
+  for _, problem := range w.problems {
+    result.Problems = append(result.Problems, *problem)
+  }
+  sort.Slice(result.Problems, func(i, j int) bool {
+    if result.Problems[i].Field != result.Problems[j].Field {
+      return result.Problems[i].Field < result.Problems[j].Field
+    }
+    return result.Problems[i].Kind < result.Problems[j].Kind
+  })
+  for _, field := range w.fields {
+    result.Fields = append(result.Fields, field)
+  }
+  sort.Slice(result.Fields, func(i, j int) bool {
+    return result.Fields[i].Key() < result.Fields[j].Key()
+  })
+  return result, nil
+}
+
+// Walks a decoded body along its schema, collecting the problems and the
+// shapes of the fields missing from the spec
+type walker struct {
+  route    string
+  problems map[string]*Problem
+  fields   map[string]ObservedField
+}
+
+func (w *walker) report(kind Kind, field string, message string) {
+  key := string(kind) + " " + field
+  if problem, ok := w.problems[key]; ok {
+    problem.Count++
+    return
+  }
+  w.problems[key] = &Problem{Route: w.route, Kind: kind, Field: field, Message: message, Count: 1}
+}
+
+// Longest example of an observed field
+const maxExampleLength = 100
+
+// Record the shape of a field missing from the spec, and of its nested values
+func (w *walker) observe(kind FieldKind, field string, value interface{}) {
+  observed := ObservedField{Kind: kind, Field: field, Type: jsonType(value)}
+  switch v := value.(type) {
+  case []interface{}:
+    for _, item := range v {
+      w.observe(kind, field+"[]", item)
+    }
+  case map[string]interface{}:
+    for name, item := range v {
+      w.observe(kind, join(field, name), item)
+    }
+  default:
+    example, _ := json.Marshal(value)
+    if len(example) > maxExampleLength {
+      example = append(example[:maxExampleLength-3], "..."...)
+    }
+    observed.Example = string(example)
+  }
+  if _, ok := w.fields[observed.Key()]; !ok {
+    w.fields[observed.Key()] = observed
+  }
+}
+
+// Type of a schema, from its allOf members when it has none of its own
+func schemaType(schema *openapi3.Schema) string {
+  if schema.Type != "" {
+    return schema.Type
+  }
+  for _, member := range schema.AllOf {
+    if member.Value != nil {
+      if t := schemaType(member.Value); t != "" {
+        return t
+      }
+    }
+  }
+  return ""
+}
+
+// Properties and required fields of an object schema, merged with its allOf members
+func objectFields(schema *openapi3.Schema, properties map[string]*openapi3.Schema, required map[string]bool) {
+  for name, property := range schema.Properties {
+    if property.Value != nil {
+      properties[name] = property.Value
+    }
+  }
+  for _, name := range schema.Required {
+    required[name] = true
+  }
+  for _, member := range schema.AllOf {
+    if member.Value != nil {
+      objectFields(member.Value, properties, required)
+    }
+  }
+}
+
+// Schema of the fields of an object that are not among its properties,
+// nil when they are undocumented
+func additionalProperties(schema *openapi3.Schema) (*openapi3.Schema, bool) {
+  if schema.AdditionalProperties.Schema != nil {
+    return schema.AdditionalProperties.Schema.Value, true
+  }
+  if schema.AdditionalProperties.Has != nil && *schema.AdditionalProperties.Has {
+    return nil, true
+  }
+  return nil, false
+}
+
+// Name of the JSON type of a decoded value
+func jsonType(value interface{}) string {
+  switch v := value.(type) {
+  case nil:
+    return "null"
+  case bool:
+    return "boolean"
+  case json.Number:
+    if _, err := v.Int64(); err == nil {
+      return "integer"
+    }
+    return "number"
+  case string:
+    return "string"
+  case []interface{}:
+    return "array"
+  case map[string]interface{}:
+    return "object"
+  }
+  return fmt.Sprintf("%T", value)
+}
+
+func (w *walker) walk(schema *openapi3.Schema, value interface{}, field string) {
+  if schema == nil {
+    return
+  }
+  expected := schemaType(schema)
+  if expected == "" {
+    // Any value
+    return
+  }
+  actual := jsonType(value)
+  if actual == "null" {
+    if !schema.Nullable {
+      w.report(TypeMismatch, field, fmt.Sprintf("expected %s, got null", expected))
+    }
+    return
+  }
+  if actual != expected && !(expected == "number" && actual == "integer") {
+    w.report(TypeMismatch, field, fmt.Sprintf("expected %s, got %s", expected, actual))
+    return
+  }
+
+  switch v := value.(type) {
+  case json.Number:
+    if schema.Format == "int32" {
+      if n, _ := v.Int64(); n < math.MinInt32 || n > math.MaxInt32 {
+        w.report(TypeMismatch, field, fmt.Sprintf("%s overflows int32", v))
+      }
+    }
+  case []interface{}:
+    if schema.Items == nil {
+      return
+    }
+    for _, item := range v {
+      w.walk(schema.Items.Value, item, field+"[]")
+    }
+  case map[string]interface{}:
+    properties, required := map[string]*openapi3.Schema{}, map[string]bool{}
+    objectFields(schema, properties, required)
+    for name := range required {
+      if _, ok := v[name]; !ok {
+        w.report(MissingField, join(field, name), "")
+      }
+    }
+    additional, allowed := additionalProperties(schema)
+    kind := UndocumentedObjectField
+    if len(properties) == 0 {
+      kind = PlaceholderField
+    }
+    for name, item := range v {
+      if property, ok := properties[name]; ok {
+        w.walk(property, item, join(field, name))
+      } else if allowed {
+        w.walk(additional, item, join(field, name))
+      } else {
+        w.report(UndocumentedField, join(field, name), "")
+        w.observe(kind, join(field, name), item)
+      }
+    }
+  }
+}
+
+func join(field string, name string) string {
+  if field == "" {
+    return name
+  }
+  return field + "." + name
+}
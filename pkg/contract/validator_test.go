@@ -0,0 +1,222 @@
+package contract
+
+import (
+  "reflect"
+  "testing"
+
+  "github.com/getkin/kin-openapi/openapi3"
+)
+
+const testSpec = `
+openapi: 3.0.0
+info:
+  title: test
+  version: "1"
+paths:
+  /WarSeason/{war_id}/Status:
+    get:
+      parameters:
+        - {name: war_id, in: path, required: true, schema: {type: integer}}
+      responses:
+        "200":
+          description: Status
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Status"
+  /Stats/war/{war_id}/summary:
+    get:
+      parameters:
+        - {name: war_id, in: path, required: true, schema: {type: integer}}
+      responses:
+        "200":
+          description: Summary
+          content:
+            application/json:
+              schema:
+                type: object
+components:
+  schemas:
+    Status:
+      type: object
+      required: [warId, planets]
+      properties:
+        warId: {type: integer, format: int32}
+        message: {type: string, nullable: true}
+        planets:
+          type: array
+          items:
+            $ref: "#/components/schemas/Planet"
+        placeholder: {type: object}
+        labels:
+          type: object
+          additionalProperties: {type: string}
+    Planet:
+      allOf:
+        - $ref: "#/components/schemas/PlanetIndex"
+        - type: object
+          required: [health]
+          properties:
+            health: {type: integer, format: int64}
+    PlanetIndex:
+      type: object
+      required: [index]
+      properties:
+        index: {type: integer, format: int32}
+`
+
+func newTestValidator(t *testing.T) *Validator {
+  t.Helper()
+  spec, err := openapi3.NewLoader().LoadFromData([]byte(testSpec))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return newValidator(spec)
+}
+
+func TestValidatorCheck(t *testing.T) {
+  v := newTestValidator(t)
+  const status = "/WarSeason/{war_id}/Status"
+  tests := []struct {
+    name     string
+    body     string
+    problems []Problem
+    fields   []ObservedField
+  }{
+    {
+      name: "valid",
+      body: `{"warId": 801, "message": "hello", "planets": [{"index": 0, "health": 1000000}], "labels": {"a": "b"}}`,
+    },
+    {
+      name:     "missing required field",
+      body:     `{"warId": 801}`,
+      problems: []Problem{{Route: status, Kind: MissingField, Field: "planets", Count: 1}},
+    },
+    {
+      name: "int32 overflow",
+      body: `{"warId": 3000000000, "planets": [{"index": 0, "health": 3000000000}]}`,
+      problems: []Problem{
+        {Route: status, Kind: TypeMismatch, Field: "warId", Message: "3000000000 overflows int32", Count: 1},
+      },
+    },
+    {
+      name:     "type mismatch",
+      body:     `{"warId": "801", "planets": []}`,
+      problems: []Problem{{Route: status, Kind: TypeMismatch, Field: "warId", Message: "expected integer, got string", Count: 1}},
+    },
+    {
+      name:     "nullable",
+      body:     `{"warId": null, "message": null, "planets": []}`,
+      problems: []Problem{{Route: status, Kind: TypeMismatch, Field: "warId", Message: "expected integer, got null", Count: 1}},
+    },
+    {
+      name: "allOf members merged",
+      body: `{"warId": 801, "planets": [{"index": 0}, {"health": 1}, {"index": 2}]}`,
+      problems: []Problem{
+        {Route: status, Kind: MissingField, Field: "planets[].health", Count: 2},
+        {Route: status, Kind: MissingField, Field: "planets[].index", Count: 1},
+      },
+    },
+    {
+      name: "undocumented field",
+      body: `{"warId": 801, "planets": [{"index": 0, "health": 1, "regen": 1.5}], "extra": {"id": 1}}`,
+      problems: []Problem{
+        {Route: status, Kind: UndocumentedField, Field: "extra", Count: 1},
+        {Route: status, Kind: UndocumentedField, Field: "planets[].regen", Count: 1},
+      },
+      fields: []ObservedField{
+        {Kind: UndocumentedObjectField, Field: "extra", Type: "object"},
+        {Kind: UndocumentedObjectField, Field: "extra.id", Type: "integer", Example: "1"},
+        {Kind: UndocumentedObjectField, Field: "planets[].regen", Type: "number", Example: "1.5"},
+      },
+    },
+    {
+      name: "placeholder object",
+      body: `{"warId": 801, "planets": [], "placeholder": {"enabled": true}}`,
+      problems: []Problem{
+        {Route: status, Kind: UndocumentedField, Field: "placeholder.enabled", Count: 1},
+      },
+      fields: []ObservedField{
+        {Kind: PlaceholderField, Field: "placeholder.enabled", Type: "boolean", Example: "true"},
+      },
+    },
+    {
+      name:     "additionalProperties",
+      body:     `{"warId": 801, "planets": [], "labels": {"a": "b", "c": 1}}`,
+      problems: []Problem{{Route: status, Kind: TypeMismatch, Field: "labels.c", Message: "expected string, got integer", Count: 1}},
+    },
+    {
+      name:     "invalid JSON",
+      body:     `{"warId": 801`,
+      problems: []Problem{{Route: status, Kind: InvalidJSON, Message: "unexpected EOF", Count: 1}},
+    },
+    {
+      name:     "trailing data",
+      body:     `{"warId": 801, "planets": []} {}`,
+      problems: []Problem{{Route: status, Kind: InvalidJSON, Message: "trailing data after the JSON value", Count: 1}},
+    },
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      result, err := v.Check(status, []byte(test.body))
+      if err != nil {
+        t.Fatal(err)
+      }
+      if len(result.Problems) != 0 || len(test.problems) != 0 {
+        if !reflect.DeepEqual(result.Problems, test.problems) {
+          t.Errorf("problems: got %+v, want %+v", result.Problems, test.problems)
+        }
+      }
+      if len(result.Fields) != 0 || len(test.fields) != 0 {
+        if !reflect.DeepEqual(result.Fields, test.fields) {
+          t.Errorf("fields: got %+v, want %+v", result.Fields, test.fields)
+        }
+      }
+    })
+  }
+
+  _, err := v.Check("/Unknown", []byte(`{}`))
+  if err == nil {
+    t.Error("an unknown route must be rejected")
+  }
+}
+
+func TestValidatorRoute(t *testing.T) {
+  v := newTestValidator(t)
+  tests := []struct {
+    path  string
+    route string
+    found bool
+  }{
+    {"/WarSeason/801/Status", "/WarSeason/{war_id}/Status", true},
+    {"/api/WarSeason/801/Status", "/WarSeason/{war_id}/Status", true},
+    {"/api/Stats/war/801/summary/", "/Stats/war/{war_id}/summary", true},
+    {"/api/WarSeason/801/WarInfo", "", false},
+    {"/801/Status", "", false},
+    {"/", "", false},
+  }
+  for _, test := range tests {
+    route, found := v.Route(test.path)
+    if route != test.route || found != test.found {
+      t.Errorf("Route(%q): got %q %v, want %q %v", test.path, route, found, test.route, test.found)
+    }
+  }
+}
+
+// The validator of the embedded spec knows every route of the client
+func TestNewValidator(t *testing.T) {
+  v, err := NewValidator()
+  if err != nil {
+    t.Fatal(err)
+  }
+  want := []string{
+    "/NewsFeed/{war_id}",
+    "/Stats/war/{war_id}/summary",
+    "/WarSeason/{war_id}/Status",
+    "/WarSeason/{war_id}/WarInfo",
+    "/v2/Assignment/War/{war_id}",
+  }
+  if routes := v.Routes(); !reflect.DeepEqual(routes, want) {
+    t.Errorf("got routes %v, want %v", routes, want)
+  }
+}
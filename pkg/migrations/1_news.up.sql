@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS news (
+  war_id INT NOT NULL,
+  id INT NOT NULL,
+  published_at BIGINT NOT NULL,
+  message TEXT,
+  PRIMARY KEY (war_id, id)
+);
@@ -0,0 +1 @@
+DROP TABLE global_events;
@@ -0,0 +1,19 @@
+-- Revisions of the global events of the war status (major order briefings,
+-- story events). A new revision is inserted whenever the content of an event
+-- changes, the times are war status times.
+CREATE TABLE IF NOT EXISTS global_events (
+  war_id INT NOT NULL,
+  event_id INT NOT NULL,
+  revision INT NOT NULL,
+  title TEXT NOT NULL,
+  message TEXT NOT NULL,
+  race INT NOT NULL,
+  flag INT NOT NULL,
+  assignment_id BIGINT NOT NULL,
+  -- JSON arrays of integers
+  effect_ids TEXT NOT NULL,
+  planet_indices TEXT NOT NULL,
+  first_seen_at BIGINT NOT NULL,
+  last_seen_at BIGINT NOT NULL,
+  PRIMARY KEY (war_id, event_id, revision)
+);
@@ -0,0 +1,502 @@
+package mockapi
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "math/rand"
+  "net"
+  "net/http"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/labstack/echo/v4"
+  "gopkg.in/yaml.v3"
+)
+
+// Route names of the API, as used in the fault profiles, by route path
+var routeNames = map[string]string{
+  "/WarSeason/:war_id/Status":  "war_status",
+  "/WarSeason/:war_id/WarInfo": "war_info",
+  "/Stats/war/:war_id/summary": "war_stats",
+  "/v2/Assignment/War/:war_id": "assignments",
+  "/NewsFeed/:war_id":          "news",
+}
+
+// Name of the route of a request, empty if it is not an API route
+func routeName(path string) string {
+  for suffix, name := range routeNames {
+    if strings.HasSuffix(path, suffix) {
+      return name
+    }
+  }
+  return ""
+}
+
+// Fault injected in the responses of a route.
+// Rates are probabilities, from 0 to 1, drawn independently for every request.
+type Fault struct {
+  // Added to every response, plus a random duration up to Jitter
+  Latency Duration `json:"latency,omitempty" yaml:"latency,omitempty"`
+  Jitter  Duration `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+  // Connection closed without a response
+  ResetRate float64 `json:"reset_rate,omitempty" yaml:"reset_rate,omitempty"`
+  // Server error, with ErrorStatus (503 by default)
+  ErrorRate   float64 `json:"error_rate,omitempty" yaml:"error_rate,omitempty"`
+  ErrorStatus int     `json:"error_status,omitempty" yaml:"error_status,omitempty"`
+  // 429 Too Many Requests, with a Retry-After of RetryAfter seconds (5 by default)
+  RateLimitRate float64 `json:"rate_limit_rate,omitempty" yaml:"rate_limit_rate,omitempty"`
+  RetryAfter    int     `json:"retry_after,omitempty" yaml:"retry_after,omitempty"`
+  // Body cut at a random offset
+  TruncateRate float64 `json:"truncate_rate,omitempty" yaml:"truncate_rate,omitempty"`
+  // Body that is not JSON
+  MalformedRate float64 `json:"malformed_rate,omitempty" yaml:"malformed_rate,omitempty"`
+  // A random field removed from the body objects
+  MissingFieldRate float64 `json:"missing_field_rate,omitempty" yaml:"missing_field_rate,omitempty"`
+  // Every array of the body emptied
+  EmptyArraysRate float64 `json:"empty_arrays_rate,omitempty" yaml:"empty_arrays_rate,omitempty"`
+}
+
+// time.Duration written as a string, e.g. 1.5s
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+  return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+  value := ""
+  if err := json.Unmarshal(b, &value); err != nil {
+    return err
+  }
+  parsed, err := time.ParseDuration(value)
+  *d = Duration(parsed)
+  return err
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+  parsed, err := time.ParseDuration(node.Value)
+  *d = Duration(parsed)
+  return err
+}
+
+func (f Fault) validate() error {
+  rates := map[string]float64{
+    "reset_rate":         f.ResetRate,
+    "error_rate":         f.ErrorRate,
+    "rate_limit_rate":    f.RateLimitRate,
+    "truncate_rate":      f.TruncateRate,
+    "malformed_rate":     f.MalformedRate,
+    "missing_field_rate": f.MissingFieldRate,
+    "empty_arrays_rate":  f.EmptyArraysRate,
+  }
+  for name, rate := range rates {
+    if rate < 0 || rate > 1 {
+      return fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+    }
+  }
+  if f.ErrorStatus != 0 && (f.ErrorStatus < 500 || f.ErrorStatus > 599) {
+    return fmt.Errorf("error_status must be a 5xx status, got %d", f.ErrorStatus)
+  }
+  if f.Latency < 0 || f.Jitter < 0 || f.RetryAfter < 0 {
+    return fmt.Errorf("latency, jitter and retry_after must be positive")
+  }
+  return nil
+}
+
+// Profile is a set of faults by route name, `*` applies to every route
+// without a fault of its own
+type Profile map[string]Fault
+
+func (p Profile) validate() error {
+  for route, fault := range p {
+    if route != "*" && !isRouteName(route) {
+      return fmt.Errorf("unknown route %q", route)
+    }
+    if err := fault.validate(); err != nil {
+      return fmt.Errorf("%s: %w", route, err)
+    }
+  }
+  return nil
+}
+
+func isRouteName(name string) bool {
+  for _, route := range routeNames {
+    if route == name {
+      return true
+    }
+  }
+  return false
+}
+
+// Fault profiles available without a profiles file
+var BuiltinProfiles = map[string]Profile{
+  "slow":         {"*": {Latency: Duration(2 * time.Second), Jitter: Duration(3 * time.Second)}},
+  "flaky":        {"*": {ErrorRate: 0.3}},
+  "rate_limited": {"*": {RateLimitRate: 0.5, RetryAfter: 10}},
+  "broken_json":  {"*": {TruncateRate: 0.2, MalformedRate: 0.2}},
+  "schema_drift": {"*": {MissingFieldRate: 0.5, EmptyArraysRate: 0.2}},
+  "resets":       {"*": {ResetRate: 0.3}},
+  // A bad game server evening: every fault at once
+  "bad_evening": {
+    "*": {
+      Latency:          Duration(500 * time.Millisecond),
+      Jitter:           Duration(4 * time.Second),
+      ResetRate:        0.05,
+      ErrorRate:        0.15,
+      RateLimitRate:    0.1,
+      RetryAfter:       15,
+      TruncateRate:     0.05,
+      MalformedRate:    0.05,
+      MissingFieldRate: 0.05,
+      EmptyArraysRate:  0.05,
+    },
+  },
+}
+
+// Load fault profiles from a YAML file, mapping profile names to profiles
+func LoadProfiles(path string) (map[string]Profile, error) {
+  content, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  profiles := map[string]Profile{}
+  decoder := yaml.NewDecoder(bytes.NewReader(content))
+  decoder.KnownFields(true)
+  err = decoder.Decode(&profiles)
+  if err != nil {
+    return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+  }
+  for name, profile := range profiles {
+    if err := profile.validate(); err != nil {
+      return nil, fmt.Errorf("profile %s: %w", name, err)
+    }
+  }
+  return profiles, nil
+}
+
+// Faults injects the faults of the active profile in the API responses
+type Faults struct {
+  mu       sync.Mutex
+  rng      *rand.Rand
+  profiles map[string]Profile
+  // Name of the active profile, empty for a custom or no profile
+  name   string
+  active Profile
+}
+
+// Faults drawing from a generator seeded with `seed`, `profiles` are
+// available along with the builtin ones
+func NewFaults(seed int64, profiles map[string]Profile) *Faults {
+  all := map[string]Profile{}
+  for name, profile := range BuiltinProfiles {
+    all[name] = profile
+  }
+  for name, profile := range profiles {
+    all[name] = profile
+  }
+  return &Faults{rng: rand.New(rand.NewSource(seed)), profiles: all, active: Profile{}}
+}
+
+// Activate a named profile, `none` disables the faults
+func (f *Faults) Activate(name string) error {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  if name == "none" || name == "" {
+    f.name, f.active = "", Profile{}
+    return nil
+  }
+  profile, ok := f.profiles[name]
+  if !ok {
+    return fmt.Errorf("unknown fault profile %q", name)
+  }
+  f.name, f.active = name, profile
+  return nil
+}
+
+// Set the faults of routes, on top of the active ones
+func (f *Faults) Set(profile Profile) error {
+  if err := profile.validate(); err != nil {
+    return err
+  }
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  active := Profile{}
+  for route, fault := range f.active {
+    active[route] = fault
+  }
+  for route, fault := range profile {
+    active[route] = fault
+  }
+  f.name, f.active = "", active
+  return nil
+}
+
+// Faults drawn for a request
+type drawnFaults struct {
+  latency      time.Duration
+  reset        bool
+  errorStatus  int
+  retryAfter   int
+  truncate     float64
+  malformed    bool
+  missingField int
+  emptyArrays  bool
+}
+
+func (f *Faults) draw(route string) (drawnFaults, bool) {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  fault, ok := f.active[route]
+  if !ok {
+    fault, ok = f.active["*"]
+  }
+  if !ok {
+    return drawnFaults{}, false
+  }
+  drawn := drawnFaults{latency: time.Duration(fault.Latency)}
+  if fault.Jitter > 0 {
+    drawn.latency += time.Duration(f.rng.Int63n(int64(fault.Jitter)))
+  }
+  chance := func(rate float64) bool {
+    return rate > 0 && f.rng.Float64() < rate
+  }
+  drawn.reset = chance(fault.ResetRate)
+  if chance(fault.RateLimitRate) {
+    drawn.errorStatus = http.StatusTooManyRequests
+    drawn.retryAfter = fault.RetryAfter
+    if drawn.retryAfter == 0 {
+      drawn.retryAfter = 5
+    }
+  } else if chance(fault.ErrorRate) {
+    drawn.errorStatus = fault.ErrorStatus
+    if drawn.errorStatus == 0 {
+      drawn.errorStatus = http.StatusServiceUnavailable
+    }
+  }
+  if chance(fault.TruncateRate) {
+    drawn.truncate = f.rng.Float64()
+  }
+  drawn.malformed = chance(fault.MalformedRate)
+  if chance(fault.MissingFieldRate) {
+    drawn.missingField = 1 + f.rng.Intn(1<<30)
+  }
+  drawn.emptyArrays = chance(fault.EmptyArraysRate)
+  return drawn, true
+}
+
+// Buffers a response, so its body can be altered before it is sent
+type bufferedResponse struct {
+  header http.Header
+  status int
+  body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+  return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+  b.status = status
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+  return b.body.Write(p)
+}
+
+// Middleware injecting the faults in the API routes
+func (f *Faults) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+  return func(ctx echo.Context) error {
+    // Other requests never draw from the generator, so a seed gives the
+    // same faults to the API requests whatever the admin requests
+    route := routeName(ctx.Path())
+    if route == "" {
+      return next(ctx)
+    }
+    drawn, ok := f.draw(route)
+    if !ok {
+      return next(ctx)
+    }
+
+    if drawn.latency > 0 {
+      select {
+      case <-time.After(drawn.latency):
+      case <-ctx.Request().Context().Done():
+        return nil
+      }
+    }
+    if drawn.reset {
+      slog.Info("Injecting fault", slog.String("route", route), slog.String("fault", "reset"))
+      return resetConnection(ctx)
+    }
+    if drawn.errorStatus != 0 {
+      slog.Info("Injecting fault", slog.String("route", route), slog.Int("status", drawn.errorStatus))
+      if drawn.retryAfter > 0 {
+        ctx.Response().Header().Set("Retry-After", strconv.Itoa(drawn.retryAfter))
+      }
+      return ctx.JSON(drawn.errorStatus, map[string]string{"error": http.StatusText(drawn.errorStatus)})
+    }
+
+    // Alter the body of the response
+    writer := ctx.Response().Writer
+    buffer := &bufferedResponse{header: writer.Header(), status: http.StatusOK}
+    ctx.Response().Writer = buffer
+    err := next(ctx)
+    ctx.Response().Writer = writer
+    if err != nil {
+      return err
+    }
+    body := buffer.body.Bytes()
+    if buffer.status == http.StatusOK {
+      body = drawn.alter(route, body)
+    }
+    writer.WriteHeader(buffer.status)
+    _, err = writer.Write(body)
+    return err
+  }
+}
+
+// Apply the body faults drawn for a request
+func (d drawnFaults) alter(route string, body []byte) []byte {
+  faults := []string{}
+  if d.emptyArrays || d.missingField != 0 {
+    var decoded interface{}
+    if json.Unmarshal(body, &decoded) == nil {
+      if d.emptyArrays {
+        decoded = emptyArrays(decoded)
+        faults = append(faults, "empty_arrays")
+      }
+      if d.missingField != 0 {
+        decoded = removeField(decoded, d.missingField)
+        faults = append(faults, "missing_field")
+      }
+      body, _ = json.Marshal(decoded)
+    }
+  }
+  if d.malformed {
+    body = append([]byte("<html><body>Bad Gateway</body></html>"), body...)
+    faults = append(faults, "malformed")
+  }
+  if d.truncate > 0 {
+    body = body[:int(float64(len(body))*d.truncate)]
+    faults = append(faults, "truncated")
+  }
+  if len(faults) > 0 {
+    slog.Info("Injecting fault", slog.String("route", route), slog.String("fault", strings.Join(faults, ",")))
+  }
+  return body
+}
+
+// Empty the arrays of the top level object, or the top level array
+func emptyArrays(value interface{}) interface{} {
+  switch v := value.(type) {
+  case []interface{}:
+    return []interface{}{}
+  case map[string]interface{}:
+    for key, field := range v {
+      if _, ok := field.([]interface{}); ok {
+        v[key] = []interface{}{}
+      }
+    }
+  }
+  return value
+}
+
+// Remove a field, chosen by `pick`, from the top level object,
+// or from every object of the top level array
+func removeField(value interface{}, pick int) interface{} {
+  remove := func(object map[string]interface{}) {
+    keys := make([]string, 0, len(object))
+    for key := range object {
+      keys = append(keys, key)
+    }
+    if len(keys) == 0 {
+      return
+    }
+    sort.Strings(keys)
+    delete(object, keys[pick%len(keys)])
+  }
+  switch v := value.(type) {
+  case []interface{}:
+    for _, item := range v {
+      if object, ok := item.(map[string]interface{}); ok {
+        remove(object)
+      }
+    }
+  case map[string]interface{}:
+    remove(v)
+  }
+  return value
+}
+
+// Close the connection of a request without a response. The connection is
+// reset rather than closed gracefully when possible.
+func resetConnection(ctx echo.Context) error {
+  conn, _, err := ctx.Response().Hijack()
+  if err != nil {
+    return err
+  }
+  if tcp, ok := conn.(*net.TCPConn); ok {
+    tcp.SetLinger(0)
+  }
+  return conn.Close()
+}
+
+// Active faults, served by the admin endpoint
+type faultsState struct {
+  Profile  string   `json:"profile"`
+  Faults   Profile  `json:"faults"`
+  Profiles []string `json:"profiles"`
+}
+
+func (f *Faults) state() faultsState {
+  f.mu.Lock()
+  defer f.mu.Unlock()
+  state := faultsState{Profile: f.name, Faults: f.active, Profiles: []string{"none"}}
+  for name := range f.profiles {
+    state.Profiles = append(state.Profiles, name)
+  }
+  sort.Strings(state.Profiles[1:])
+  return state
+}
+
+// Register the admin endpoint toggling the faults at runtime:
+// * GET /admin/faults: active faults and available profiles
+// * PUT /admin/faults/profile/:name: activate a profile, `none` disables the faults
+// * PATCH /admin/faults: set the faults of some routes, a JSON Profile
+// * DELETE /admin/faults: disable the faults
+func (f *Faults) RegisterAdmin(e *echo.Echo) {
+  e.GET("/admin/faults", func(ctx echo.Context) error {
+    return ctx.JSON(http.StatusOK, f.state())
+  })
+  e.PUT("/admin/faults/profile/:name", func(ctx echo.Context) error {
+    if err := f.Activate(ctx.Param("name")); err != nil {
+      return echo.NewHTTPError(http.StatusNotFound, err.Error())
+    }
+    slog.Info("Activated fault profile", slog.String("profile", ctx.Param("name")))
+    return ctx.JSON(http.StatusOK, f.state())
+  })
+  e.PATCH("/admin/faults", func(ctx echo.Context) error {
+    profile := Profile{}
+    decoder := json.NewDecoder(ctx.Request().Body)
+    decoder.DisallowUnknownFields()
+    if err := decoder.Decode(&profile); err != nil {
+      return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+    }
+    if err := f.Set(profile); err != nil {
+      return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+    }
+    slog.Info("Updated faults", slog.Any("faults", profile))
+    return ctx.JSON(http.StatusOK, f.state())
+  })
+  e.DELETE("/admin/faults", func(ctx echo.Context) error {
+    f.Activate("none")
+    slog.Info("Disabled faults")
+    return ctx.JSON(http.StatusOK, f.state())
+  })
+}
@@ -0,0 +1,177 @@
+package mockapi
+
+import (
+  "encoding/json"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/labstack/echo/v4"
+)
+
+func TestBuiltinProfiles(t *testing.T) {
+  faults := NewFaults(1, nil)
+  for name, profile := range BuiltinProfiles {
+    if err := profile.validate(); err != nil {
+      t.Errorf("profile %s: %v", name, err)
+    }
+    if err := faults.Activate(name); err != nil {
+      t.Errorf("profile %s: %v", name, err)
+    }
+  }
+}
+
+func TestLoadProfiles(t *testing.T) {
+  tests := []struct {
+    name    string
+    content string
+    valid   bool
+  }{
+    {"valid", "down:\n  war_status:\n    error_rate: 1\n    latency: 1.5s\n", true},
+    {"unknown route", "down:\n  war_summary:\n    error_rate: 1\n", false},
+    {"unknown field", "down:\n  '*':\n    failure_rate: 1\n", false},
+    {"invalid rate", "down:\n  '*':\n    error_rate: 2\n", false},
+    {"invalid status", "down:\n  '*':\n    error_status: 404\n", false},
+  }
+  for _, test := range tests {
+    t.Run(test.name, func(t *testing.T) {
+      path := filepath.Join(t.TempDir(), "faults.yaml")
+      err := os.WriteFile(path, []byte(test.content), 0o644)
+      if err != nil {
+        t.Fatal(err)
+      }
+      profiles, err := LoadProfiles(path)
+      if test.valid && err != nil {
+        t.Fatal(err)
+      }
+      if !test.valid && err == nil {
+        t.Fatalf("got profiles %v, want an error", profiles)
+      }
+    })
+  }
+}
+
+// Response of a request to the test server
+func request(t *testing.T, e *echo.Echo, method string, path string, body string) (int, faultsState) {
+  t.Helper()
+  rec := httptest.NewRecorder()
+  req := httptest.NewRequest(method, path, strings.NewReader(body))
+  req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+  e.ServeHTTP(rec, req)
+  state := faultsState{}
+  if rec.Code == http.StatusOK && strings.HasPrefix(path, "/admin/") {
+    err := json.Unmarshal(rec.Body.Bytes(), &state)
+    if err != nil {
+      t.Fatal(err)
+    }
+  }
+  return rec.Code, state
+}
+
+func TestFaultsAdmin(t *testing.T) {
+  faults := NewFaults(1, map[string]Profile{"down": {"*": {ErrorRate: 1}}})
+  e := echo.New()
+  faults.RegisterAdmin(e)
+  ok := func(ctx echo.Context) error {
+    return ctx.JSON(http.StatusOK, map[string]bool{"ok": true})
+  }
+  e.GET("/api/WarSeason/:war_id/Status", ok, faults.Middleware)
+  e.GET("/api/NewsFeed/:war_id", ok, faults.Middleware)
+  // Status of the war status and news routes
+  statuses := func() [2]int {
+    status, _ := request(t, e, http.MethodGet, "/api/WarSeason/801/Status", "")
+    news, _ := request(t, e, http.MethodGet, "/api/NewsFeed/801", "")
+    return [2]int{status, news}
+  }
+
+  status, state := request(t, e, http.MethodGet, "/admin/faults", "")
+  profiles := []string{"none", "bad_evening", "broken_json", "down", "flaky", "rate_limited", "resets", "schema_drift", "slow"}
+  if status != http.StatusOK || state.Profile != "" || len(state.Faults) != 0 || !reflect.DeepEqual(state.Profiles, profiles) {
+    t.Fatalf("initial state: got %d %+v", status, state)
+  }
+  if got := statuses(); got != [2]int{200, 200} {
+    t.Errorf("without faults: got %v", got)
+  }
+
+  status, state = request(t, e, http.MethodPut, "/admin/faults/profile/down", "")
+  if status != http.StatusOK || state.Profile != "down" {
+    t.Fatalf("activate down: got %d %+v", status, state)
+  }
+  if got := statuses(); got != [2]int{503, 503} {
+    t.Errorf("down profile: got %v", got)
+  }
+  status, state = request(t, e, http.MethodPut, "/admin/faults/profile/unknown", "")
+  if status != http.StatusNotFound {
+    t.Errorf("activate an unknown profile: got %d", status)
+  }
+  if _, state = request(t, e, http.MethodGet, "/admin/faults", ""); state.Profile != "down" {
+    t.Errorf("an unknown profile replaced the active one: %+v", state)
+  }
+
+  // Route faults are set on top of the active ones
+  status, state = request(t, e, http.MethodPatch, "/admin/faults", `{"war_status": {"error_rate": 1, "error_status": 502}}`)
+  if status != http.StatusOK || state.Profile != "" || len(state.Faults) != 2 {
+    t.Fatalf("patch war_status: got %d %+v", status, state)
+  }
+  if got := statuses(); got != [2]int{502, 503} {
+    t.Errorf("patched war_status: got %v", got)
+  }
+  invalid := map[string]string{
+    "unknown route": `{"war_summary": {"error_rate": 1}}`,
+    "unknown field": `{"war_status": {"failure_rate": 1}}`,
+    "invalid rate":  `{"war_status": {"error_rate": 1.5}}`,
+    "invalid JSON":  `{"war_status": `,
+  }
+  for name, body := range invalid {
+    if status, _ := request(t, e, http.MethodPatch, "/admin/faults", body); status != http.StatusBadRequest {
+      t.Errorf("patch with an %s: got %d, want 400", name, status)
+    }
+  }
+  if _, state = request(t, e, http.MethodGet, "/admin/faults", ""); state.Faults["war_status"].ErrorStatus != 502 {
+    t.Errorf("an invalid patch changed the faults: %+v", state)
+  }
+
+  status, state = request(t, e, http.MethodDelete, "/admin/faults", "")
+  if status != http.StatusOK || state.Profile != "" || len(state.Faults) != 0 {
+    t.Fatalf("delete: got %d %+v", status, state)
+  }
+  if got := statuses(); got != [2]int{200, 200} {
+    t.Errorf("after delete: got %v", got)
+  }
+}
+
+// The faults drawn for the API requests only depend on the seed, not on the
+// other requests served in between
+func TestFaultsReproducible(t *testing.T) {
+  statuses := func(interleaved bool) []int {
+    faults := NewFaults(7, nil)
+    err := faults.Set(Profile{"*": {ErrorRate: 0.5}})
+    if err != nil {
+      t.Fatal(err)
+    }
+    e := echo.New()
+    e.Use(faults.Middleware)
+    faults.RegisterAdmin(e)
+    e.GET("/api/WarSeason/:war_id/Status", func(ctx echo.Context) error {
+      return ctx.JSON(http.StatusOK, map[string]bool{"ok": true})
+    })
+    got := []int{}
+    for i := 0; i < 20; i++ {
+      if interleaved {
+        request(t, e, http.MethodGet, "/admin/faults", "")
+        request(t, e, http.MethodGet, "/unknown", "")
+      }
+      status, _ := request(t, e, http.MethodGet, "/api/WarSeason/801/Status", "")
+      got = append(got, status)
+    }
+    return got
+  }
+  alone, interleaved := statuses(false), statuses(true)
+  if !reflect.DeepEqual(alone, interleaved) {
+    t.Errorf("statuses with other requests in between: got %v, want %v", interleaved, alone)
+  }
+}
@@ -0,0 +1,109 @@
+package mockapi
+
+import (
+  "embed"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/fs"
+  "path"
+  "sort"
+  "strconv"
+  "strings"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Default fixtures, a snapshot of war 801
+//
+//go:embed fixtures
+var embeddedFixtures embed.FS
+
+// Fixtures embedded in the binary
+func DefaultFixtures() fs.FS {
+  fixtures, err := fs.Sub(embeddedFixtures, "fixtures")
+  if err != nil {
+    panic(err)
+  }
+  return fixtures
+}
+
+// Load the wars of a fixtures directory.
+// Every sub directory named after a war ID holds the responses of that war:
+// * status.json: WarSeasonStatus
+// * war_info.json: WarSeasonInfo
+// * summary.json: WarStatistics
+// * assignments.json: list of Assignment
+// * news/<language>.json: list of NewsEntry, e.g. news/en-US.json
+// Missing files are served as empty responses.
+func LoadFixtures(fixtures fs.FS) (map[int]*War, error) {
+  dirs, err := fs.ReadDir(fixtures, ".")
+  if err != nil {
+    return nil, err
+  }
+  wars := map[int]*War{}
+  for _, dir := range dirs {
+    warID, err := strconv.Atoi(dir.Name())
+    if !dir.IsDir() || err != nil {
+      continue
+    }
+    war, err := loadWar(fixtures, dir.Name(), warID)
+    if err != nil {
+      return nil, fmt.Errorf("war %d: %w", warID, err)
+    }
+    wars[warID] = war
+  }
+  if len(wars) == 0 {
+    return nil, fmt.Errorf("no war found in the fixtures")
+  }
+  return wars, nil
+}
+
+// Decode `name` into `out`, leaving it untouched if the file does not exist
+func readFixture(fixtures fs.FS, name string, out interface{}) error {
+  content, err := fs.ReadFile(fixtures, name)
+  if errors.Is(err, fs.ErrNotExist) {
+    return nil
+  }
+  if err != nil {
+    return err
+  }
+  err = json.Unmarshal(content, out)
+  if err != nil {
+    return fmt.Errorf("%s: %w", name, err)
+  }
+  return nil
+}
+
+func loadWar(fixtures fs.FS, dir string, warID int) (*War, error) {
+  war := NewWar(warID)
+  files := map[string]interface{}{
+    "status.json":      &war.Status,
+    "war_info.json":    &war.Info,
+    "summary.json":     &war.Stats,
+    "assignments.json": &war.Assignments,
+  }
+  for name, out := range files {
+    err := readFixture(fixtures, path.Join(dir, name), out)
+    if err != nil {
+      return nil, err
+    }
+  }
+
+  news, err := fs.Glob(fixtures, path.Join(dir, "news", "*.json"))
+  if err != nil {
+    return nil, err
+  }
+  for _, file := range news {
+    entries := []client.NewsEntry{}
+    err := readFixture(fixtures, file, &entries)
+    if err != nil {
+      return nil, err
+    }
+    sort.SliceStable(entries, func(i, j int) bool {
+      return entries[i].Published < entries[j].Published
+    })
+    war.News[strings.TrimSuffix(path.Base(file), ".json")] = entries
+  }
+  return war, nil
+}
@@ -0,0 +1,183 @@
+// Package mockapi implements the Helldivers 2 API (client.ServerInterface)
+// over an in-memory state, to develop and test without the live API.
+package mockapi
+
+import (
+  "net/http"
+  "sort"
+  "strings"
+  "sync"
+
+  "github.com/labstack/echo/v4"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Language of the news feed when the requested one is not available
+const DefaultLanguage = "en-US"
+
+// War is the state of a war served by the mock
+type War struct {
+  Status      client.WarSeasonStatus
+  Info        client.WarSeasonInfo
+  Stats       client.WarStatistics
+  Assignments []client.Assignment
+  // News feed entries by language, sorted by publication time
+  News map[string][]client.NewsEntry
+}
+
+// An empty war, with non nil lists so they are served as empty JSON arrays
+func NewWar(warID int) *War {
+  war := &War{
+    Assignments: []client.Assignment{},
+    News:        map[string][]client.NewsEntry{},
+  }
+  war.Status = client.WarSeasonStatus{
+    WarId:                       int32(warID),
+    ActiveElectionPolicyEffects: []client.ActiveElectionPolicyEffect{},
+    CommunityTargets:            []client.CommunityTarget{},
+    GlobalEvents:                []client.GlobalEvent{},
+    JointOperations:             []client.JointOperation{},
+    PlanetActiveEffects:         []client.PlanetActiveEffect{},
+    PlanetAttacks:               []client.PlanetAttack{},
+    PlanetEvents:                []client.PlanetEvent{},
+    PlanetStatus:                []client.PlanetStatus{},
+    SuperEarthWarResults:        []client.SuperEarthWarResult{},
+  }
+  war.Info.WarId = int32(warID)
+  war.Info.PlanetInfos = []client.PlanetInfo{}
+  war.Info.CapitalInfos = []map[string]interface{}{}
+  war.Info.PermanentPlanetEffects = []map[string]interface{}{}
+  return war
+}
+
+// Server serves the wars it holds. Wars can be updated while serving.
+type Server struct {
+  mu   sync.RWMutex
+  wars map[int]*War
+  // Maximum number of news feed entries per response
+  pageSize int
+}
+
+var _ client.ServerInterface = (*Server)(nil)
+
+func NewServer(wars map[int]*War, pageSize int) *Server {
+  return &Server{wars: wars, pageSize: pageSize}
+}
+
+// Update a war under the server lock, creating it if needed
+func (s *Server) Update(warID int, update func(war *War)) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  war, ok := s.wars[warID]
+  if !ok {
+    war = NewWar(warID)
+    s.wars[warID] = war
+  }
+  update(war)
+}
+
+// Handlers of the server, under the /api base path of the official API
+func (s *Server) Handler() *echo.Echo {
+  e := echo.New()
+  e.HideBanner = true
+  e.HidePort = true
+  client.RegisterHandlersWithBaseURL(e, s, "/api")
+  return e
+}
+
+// Serve a part of a war, read under the server lock
+func (s *Server) serveWar(ctx echo.Context, warID int, response func(war *War) interface{}) error {
+  s.mu.RLock()
+  defer s.mu.RUnlock()
+  war, ok := s.wars[warID]
+  if !ok {
+    return echo.NewHTTPError(http.StatusNotFound, "unknown war")
+  }
+  return ctx.JSON(http.StatusOK, response(war))
+}
+
+func (s *Server) GetWarSeasonWarIdStatus(ctx echo.Context, warId int) error {
+  return s.serveWar(ctx, warId, func(war *War) interface{} {
+    return war.Status
+  })
+}
+
+func (s *Server) GetWarSeasonWarIdWarInfo(ctx echo.Context, warId int) error {
+  return s.serveWar(ctx, warId, func(war *War) interface{} {
+    return war.Info
+  })
+}
+
+func (s *Server) GetStatsWarWarIdSummary(ctx echo.Context, warId int) error {
+  return s.serveWar(ctx, warId, func(war *War) interface{} {
+    return war.Stats
+  })
+}
+
+func (s *Server) GetV2AssignmentWarWarId(ctx echo.Context, warId int) error {
+  return s.serveWar(ctx, warId, func(war *War) interface{} {
+    return war.Assignments
+  })
+}
+
+// Pick the news language matching an accept-language header, e.g.
+// `fr-FR,fr;q=0.9`: the first exact match, then the first language with the
+// same primary tag, then DefaultLanguage.
+func newsLanguage(war *War, acceptLanguage *string) string {
+  if acceptLanguage == nil {
+    return DefaultLanguage
+  }
+  requested := []string{}
+  for _, tag := range strings.Split(*acceptLanguage, ",") {
+    tag, _, _ = strings.Cut(tag, ";")
+    if tag = strings.TrimSpace(tag); tag != "" {
+      requested = append(requested, tag)
+    }
+  }
+  for _, tag := range requested {
+    for language := range war.News {
+      if strings.EqualFold(language, tag) {
+        return language
+      }
+    }
+  }
+  available := make([]string, 0, len(war.News))
+  for language := range war.News {
+    available = append(available, language)
+  }
+  sort.Strings(available)
+  for _, tag := range requested {
+    primary, _, _ := strings.Cut(tag, "-")
+    for _, language := range available {
+      if other, _, _ := strings.Cut(language, "-"); strings.EqualFold(primary, other) {
+        return language
+      }
+    }
+  }
+  return DefaultLanguage
+}
+
+// News feed entries published at or after fromTimestamp, oldest first,
+// at most pageSize of them
+func (s *Server) GetNewsFeedWarId(ctx echo.Context, warId int, params client.GetNewsFeedWarIdParams) error {
+  return s.serveWar(ctx, warId, func(war *War) interface{} {
+    language := newsLanguage(war, params.AcceptLanguage)
+    ctx.Response().Header().Set("Content-Language", language)
+    from := int64(0)
+    if params.FromTimestamp != nil {
+      from = int64(*params.FromTimestamp)
+    }
+    page := []client.NewsEntry{}
+    for _, entry := range war.News[language] {
+      if entry.Published < from {
+        continue
+      }
+      if s.pageSize > 0 && len(page) >= s.pageSize {
+        break
+      }
+      page = append(page, entry)
+    }
+    return page
+  })
+}
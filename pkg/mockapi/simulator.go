@@ -0,0 +1,581 @@
+package mockapi
+
+import (
+  "context"
+  "fmt"
+  "math"
+  "math/rand"
+  "sort"
+  "strings"
+  "time"
+
+  "github.com/Xide/helldivers2-dashboard/data"
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+const (
+  superEarth = client.FactionEnum(1)
+  terminids  = client.FactionEnum(2)
+  automatons = client.FactionEnum(3)
+
+  // Longest step of the simulation, longer advances are split
+  simulationStep = time.Minute
+  // Damage dealt to a planet per second and per player, scaled by the
+  // impact multiplier of the war. 10000 players liberate a planet in about a day.
+  playerDamage = 0.4
+  // Health regeneration of the planets, per second
+  terminidRegen   = 4.1666665
+  automatonRegen  = 2.777778
+  superEarthRegen = 1388.8889
+  // Chance that the enemies launch a defense campaign, per simulated hour
+  defenseChance     = 0.08
+  defenseDuration   = 24 * time.Hour
+  defenseMaxHealth  = 1200000
+  majorOrderLength  = 72 * time.Hour
+  majorOrderReward  = 45
+  defaultMaxHealth  = 1000000
+  playersPerMission = 4
+  missionDuration   = 30 * time.Minute
+)
+
+// Simulator evolves a war over simulated time: planet health and regen,
+// player distribution, supply lanes, defense campaigns, major orders and news.
+// Every random decision comes from a generator seeded at creation, so a
+// simulation advanced by the same durations always produces the same war.
+type Simulator struct {
+  warID int
+  rng   *rand.Rand
+  names map[int32]string
+  // Players of the war at the start of the simulation, before the daily cycle
+  basePlayers float64
+  // Appeal of every planet to the players, drifting over time
+  appeal map[int32]float64
+  // Fractional missions played on every planet, not yet counted
+  missions map[int32]float64
+  nextID   int32
+}
+
+// Simulate the war `warID` of the server, starting from its current state
+func NewSimulator(seed int64, warID int, war *War) *Simulator {
+  s := &Simulator{
+    warID:    warID,
+    rng:      rand.New(rand.NewSource(seed)),
+    names:    planetNames(),
+    appeal:   map[int32]float64{},
+    missions: map[int32]float64{},
+  }
+  for _, planet := range war.Status.PlanetStatus {
+    s.basePlayers += float64(planet.Players)
+    s.appeal[planet.Index] = 0.5 + s.rng.Float64()
+  }
+  if s.basePlayers == 0 {
+    s.basePlayers = 50000
+  }
+  // Identifiers of the generated events, assignments and news
+  s.nextID = 1
+  for _, event := range war.Status.PlanetEvents {
+    s.nextID = max(s.nextID, event.Id+1, event.CampaignId+1)
+  }
+  for _, news := range war.News {
+    for _, entry := range news {
+      s.nextID = max(s.nextID, entry.Id+1)
+    }
+  }
+  for _, event := range war.Status.GlobalEvents {
+    s.nextID = max(s.nextID, event.EventId+1)
+  }
+  return s
+}
+
+// Planet names, from the reference data embedded in the binaries.
+// Planets missing from the data are named after their index.
+func planetNames() map[int32]string {
+  planets, _ := data.EmbeddedPlanets()
+  names := map[int32]string{}
+  for index, planet := range planets {
+    names[index] = planet.Name
+  }
+  return names
+}
+
+func (s *Simulator) planetName(index int32) string {
+  if name := s.names[index]; name != "" {
+    return name
+  }
+  return fmt.Sprintf("Planet %d", index)
+}
+
+func (s *Simulator) id() int32 {
+  s.nextID++
+  return s.nextID - 1
+}
+
+// Run the simulation on the server: every tick of wall time advances the
+// war by `speed` ticks of simulated time, until the context is cancelled
+func (s *Simulator) Run(ctx context.Context, server *Server, tick time.Duration, speed float64) {
+  ticker := time.NewTicker(tick)
+  defer ticker.Stop()
+  for {
+    select {
+    case <-ctx.Done():
+      return
+    case <-ticker.C:
+      server.Update(s.warID, func(war *War) {
+        s.Advance(war, time.Duration(float64(tick)*speed))
+      })
+    }
+  }
+}
+
+// Advance the war by `d` of simulated time
+func (s *Simulator) Advance(war *War, d time.Duration) {
+  for d > 0 {
+    step := min(d, simulationStep)
+    s.step(war, step)
+    d -= step
+  }
+}
+
+func (s *Simulator) step(war *War, d time.Duration) {
+  seconds := d.Seconds()
+  war.Status.Time += int64(seconds)
+
+  s.distributePlayers(war)
+  s.fight(war, seconds)
+  s.expireDefenses(war)
+  if s.rng.Float64() < defenseChance*d.Hours() {
+    s.startDefense(war)
+  }
+  s.updateMajorOrder(war, seconds)
+  s.play(war, seconds)
+}
+
+// Index of the planets in the war status
+func statusIndex(war *War) map[int32]int {
+  index := map[int32]int{}
+  for i, planet := range war.Status.PlanetStatus {
+    index[planet.Index] = i
+  }
+  return index
+}
+
+func maxHealth(war *War, index int32) int32 {
+  for _, planet := range war.Info.PlanetInfos {
+    if planet.Index == index && planet.MaxHealth > 0 {
+      return planet.MaxHealth
+    }
+  }
+  return defaultMaxHealth
+}
+
+func regen(owner client.FactionEnum) float32 {
+  switch owner {
+  case terminids:
+    return terminidRegen
+  case automatons:
+    return automatonRegen
+  default:
+    return superEarthRegen
+  }
+}
+
+func factionName(race client.FactionEnum) string {
+  switch race {
+  case terminids:
+    return "Terminids"
+  case automatons:
+    return "Automatons"
+  default:
+    return "Super Earth"
+  }
+}
+
+// Is a planet the destination of a supply lane
+func attacked(war *War, index int32) bool {
+  for _, attack := range war.Status.PlanetAttacks {
+    if attack.Destination == index {
+      return true
+    }
+  }
+  return false
+}
+
+// Defense campaign of a planet, nil if it has none
+func defense(war *War, index int32) *client.PlanetEvent {
+  for i := range war.Status.PlanetEvents {
+    if war.Status.PlanetEvents[i].PlanetIndex == index {
+      return &war.Status.PlanetEvents[i]
+    }
+  }
+  return nil
+}
+
+// Planets targeted by the current major order
+func majorOrderTargets(war *War) map[int32]bool {
+  targets := map[int32]bool{}
+  for _, assignment := range war.Assignments {
+    for _, task := range assignment.Setting.Tasks {
+      if len(task.Values) > 2 {
+        targets[task.Values[2]] = true
+      }
+    }
+  }
+  return targets
+}
+
+// Spread the players of the war, following a daily cycle, on the planets
+// they can fight on: liberation targets, defenses and the major order
+func (s *Simulator) distributePlayers(war *War) {
+  day := 2 * math.Pi * float64(war.Status.Time%86400) / 86400
+  total := s.basePlayers * (0.75 + 0.25*math.Sin(day)) * (0.95 + 0.1*s.rng.Float64())
+  targets := majorOrderTargets(war)
+
+  weights := make([]float64, len(war.Status.PlanetStatus))
+  sum := 0.0
+  for i, planet := range war.Status.PlanetStatus {
+    // Appeal drifts as a bounded random walk
+    appeal := s.appeal[planet.Index] + (s.rng.Float64()-0.5)*0.05
+    appeal = math.Max(0.2, math.Min(2, appeal))
+    s.appeal[planet.Index] = appeal
+
+    weight := 0.01
+    if planet.Owner != superEarth && attacked(war, planet.Index) {
+      weight = appeal
+    }
+    if defense(war, planet.Index) != nil {
+      weight = 2 * appeal
+    }
+    if targets[planet.Index] {
+      weight *= 2
+    }
+    weights[i] = weight
+    sum += weight
+  }
+  for i := range war.Status.PlanetStatus {
+    war.Status.PlanetStatus[i].Players = int32(total * weights[i] / sum)
+  }
+}
+
+// Players damage the planets and defenses they fight on, enemy planets regenerate
+func (s *Simulator) fight(war *War, seconds float64) {
+  impact := float64(war.Status.ImpactMultiplier)
+  if impact == 0 {
+    impact = 0.0035
+  }
+  for i := range war.Status.PlanetStatus {
+    planet := &war.Status.PlanetStatus[i]
+    damage := float64(planet.Players) * impact * playerDamage * seconds
+
+    if event := defense(war, planet.Index); event != nil {
+      event.Health = int32(math.Max(0, float64(event.Health)-damage))
+      if event.Health == 0 {
+        s.removeDefense(war, planet.Index)
+        s.publish(war, fmt.Sprintf("%s has been successfully defended against the %s. Well done, Helldivers!", s.planetName(planet.Index), factionName(event.Race)))
+      }
+      continue
+    }
+    if planet.Owner == superEarth {
+      continue
+    }
+    health := float64(planet.Health) + float64(planet.RegenPerSecond)*seconds
+    if attacked(war, planet.Index) {
+      health -= damage
+    }
+    planet.Health = int32(math.Max(0, math.Min(float64(maxHealth(war, planet.Index)), health)))
+    if planet.Health == 0 {
+      s.liberate(war, planet)
+    }
+  }
+}
+
+// A planet is liberated: supply lanes now start from it
+func (s *Simulator) liberate(war *War, planet *client.PlanetStatus) {
+  previous := planet.Owner
+  planet.Owner = superEarth
+  planet.Health = maxHealth(war, planet.Index)
+  planet.RegenPerSecond = regen(superEarth)
+
+  lanes := []client.PlanetAttack{}
+  for _, attack := range war.Status.PlanetAttacks {
+    if attack.Destination != planet.Index {
+      lanes = append(lanes, attack)
+    }
+  }
+  war.Status.PlanetAttacks = lanes
+  for _, target := range s.pick(war, 2, func(p client.PlanetStatus) bool {
+    return p.Owner != superEarth && !attacked(war, p.Index)
+  }) {
+    war.Status.PlanetAttacks = append(war.Status.PlanetAttacks, client.PlanetAttack{Source: planet.Index, Destination: target})
+  }
+  s.publish(war, fmt.Sprintf("%s has been liberated from the %s!", s.planetName(planet.Index), factionName(previous)))
+}
+
+// Pick up to `n` random planets matching `filter`
+func (s *Simulator) pick(war *War, n int, filter func(p client.PlanetStatus) bool) []int32 {
+  candidates := []int32{}
+  for _, planet := range war.Status.PlanetStatus {
+    if filter(planet) {
+      candidates = append(candidates, planet.Index)
+    }
+  }
+  sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+  s.rng.Shuffle(len(candidates), func(i, j int) {
+    candidates[i], candidates[j] = candidates[j], candidates[i]
+  })
+  return candidates[:min(n, len(candidates))]
+}
+
+// The enemies attack a Super Earth planet, which must be defended before the campaign expires
+func (s *Simulator) startDefense(war *War) {
+  homeWorlds := map[int32]bool{}
+  for _, home := range war.Info.HomeWorlds {
+    for _, index := range home.PlanetIndices {
+      homeWorlds[index] = true
+    }
+  }
+  targets := s.pick(war, 1, func(p client.PlanetStatus) bool {
+    return p.Owner == superEarth && !homeWorlds[p.Index] && defense(war, p.Index) == nil
+  })
+  if len(targets) == 0 {
+    return
+  }
+  race := []client.FactionEnum{terminids, automatons}[s.rng.Intn(2)]
+  event := client.PlanetEvent{
+    Id:                s.id(),
+    CampaignId:        s.id(),
+    EventType:         1,
+    PlanetIndex:       targets[0],
+    Race:              race,
+    Health:            defenseMaxHealth,
+    MaxHealth:         defenseMaxHealth,
+    StartTime:         war.Status.Time,
+    ExpireTime:        war.Status.Time + int64(defenseDuration.Seconds()),
+    JointOperationIds: []int32{},
+  }
+  war.Status.PlanetEvents = append(war.Status.PlanetEvents, event)
+  s.publish(war, fmt.Sprintf("The %s are attacking %s! Defend it before they take it.", factionName(race), s.planetName(targets[0])))
+}
+
+func (s *Simulator) removeDefense(war *War, index int32) {
+  events := []client.PlanetEvent{}
+  for _, event := range war.Status.PlanetEvents {
+    if event.PlanetIndex != index {
+      events = append(events, event)
+    }
+  }
+  war.Status.PlanetEvents = events
+  operations := []client.JointOperation{}
+  for _, operation := range war.Status.JointOperations {
+    if operation.PlanetIndex != index {
+      operations = append(operations, operation)
+    }
+  }
+  war.Status.JointOperations = operations
+}
+
+// Expired defense campaigns are lost, their planet falls to the enemy
+func (s *Simulator) expireDefenses(war *War) {
+  planets := statusIndex(war)
+  for _, event := range append([]client.PlanetEvent{}, war.Status.PlanetEvents...) {
+    if event.ExpireTime > war.Status.Time {
+      continue
+    }
+    s.removeDefense(war, event.PlanetIndex)
+    i, ok := planets[event.PlanetIndex]
+    if !ok {
+      continue
+    }
+    planet := &war.Status.PlanetStatus[i]
+    planet.Owner = event.Race
+    planet.RegenPerSecond = regen(event.Race)
+    planet.Health = maxHealth(war, planet.Index) * 9 / 10
+
+    // Lanes no longer start from the lost planet, another one must retake it
+    lanes := []client.PlanetAttack{}
+    for _, attack := range war.Status.PlanetAttacks {
+      if attack.Source != planet.Index {
+        lanes = append(lanes, attack)
+      }
+    }
+    war.Status.PlanetAttacks = lanes
+    for _, source := range s.pick(war, 1, func(p client.PlanetStatus) bool {
+      return p.Owner == superEarth
+    }) {
+      war.Status.PlanetAttacks = append(war.Status.PlanetAttacks, client.PlanetAttack{Source: source, Destination: planet.Index})
+    }
+    s.publish(war, fmt.Sprintf("%s has fallen to the %s.", s.planetName(planet.Index), factionName(event.Race)))
+  }
+}
+
+// Track the progress of the major order, and issue a new one when it is
+// completed or expires
+func (s *Simulator) updateMajorOrder(war *War, seconds float64) {
+  owners := map[int32]client.FactionEnum{}
+  for _, planet := range war.Status.PlanetStatus {
+    owners[planet.Index] = planet.Owner
+  }
+  orders := []client.Assignment{}
+  for _, assignment := range war.Assignments {
+    assignment.ExpireIn -= int64(seconds)
+    completed := len(assignment.Setting.Tasks) > 0
+    assignment.Progress = make([]int32, len(assignment.Setting.Tasks))
+    for i, task := range assignment.Setting.Tasks {
+      if len(task.Values) > 2 && owners[task.Values[2]] == superEarth {
+        assignment.Progress[i] = 1
+      } else {
+        completed = false
+      }
+    }
+    switch {
+    case completed:
+      s.publish(war, fmt.Sprintf("MAJOR ORDER COMPLETE\nHelldivers, you have been rewarded %d medals for your service.", assignment.Setting.Reward.Amount))
+      withdrawBriefing(war, assignment.Id)
+    case assignment.ExpireIn <= 0:
+      s.publish(war, "MAJOR ORDER FAILED\nSuper Earth is disappointed.")
+      withdrawBriefing(war, assignment.Id)
+    default:
+      orders = append(orders, assignment)
+    }
+  }
+  war.Assignments = orders
+  if len(war.Assignments) == 0 {
+    s.issueMajorOrder(war)
+  }
+}
+
+func (s *Simulator) issueMajorOrder(war *War) {
+  // Planets the players can reach first, then any enemy planet
+  count := 1 + s.rng.Intn(2)
+  targets := s.pick(war, count, func(p client.PlanetStatus) bool {
+    return p.Owner != superEarth && attacked(war, p.Index)
+  })
+  if len(targets) == 0 {
+    targets = s.pick(war, count, func(p client.PlanetStatus) bool {
+      return p.Owner != superEarth
+    })
+  }
+  if len(targets) == 0 {
+    return
+  }
+  assignment := client.Assignment{
+    Id:       int64(s.id()),
+    ExpireIn: int64(majorOrderLength.Seconds()),
+    Progress: make([]int32, len(targets)),
+  }
+  names := []string{}
+  for _, target := range targets {
+    assignment.Setting.Tasks = append(assignment.Setting.Tasks, client.AssignmentTask{
+      Type:        11,
+      Values:      []int32{1, 1, target},
+      ValuesTypes: []int32{3, 11, 12},
+    })
+    names = append(names, s.planetName(target))
+  }
+  assignment.Setting.Type = 4
+  assignment.Setting.Flags = 1
+  assignment.Setting.OverrideTitle = "MAJOR ORDER"
+  assignment.Setting.OverrideBrief = fmt.Sprintf("Liberate %s.", strings.Join(names, " and "))
+  assignment.Setting.Reward = client.AssignmentReward{Type: 1, Amount: majorOrderReward}
+  war.Assignments = append(war.Assignments, assignment)
+  s.publish(war, "MAJOR ORDER\n"+assignment.Setting.OverrideBrief)
+
+  // Briefing of the major order, shown in game until the order is over
+  assignmentID := assignment.Id
+  war.Status.GlobalEvents = append(war.Status.GlobalEvents, client.GlobalEvent{
+    EventId:        s.id(),
+    Title:          assignment.Setting.OverrideTitle,
+    Message:        assignment.Setting.OverrideBrief,
+    Race:           superEarth,
+    AssignmentId32: &assignmentID,
+    EffectIds:      &[]int32{},
+    PlanetIndices:  targets,
+  })
+}
+
+// Remove the briefing of a major order from the global events
+func withdrawBriefing(war *War, assignmentID int64) {
+  events := []client.GlobalEvent{}
+  for _, event := range war.Status.GlobalEvents {
+    if event.AssignmentId32 == nil || *event.AssignmentId32 != assignmentID {
+      events = append(events, event)
+    }
+  }
+  war.Status.GlobalEvents = events
+}
+
+// Count the missions played on every planet in the war statistics
+func (s *Simulator) play(war *War, seconds float64) {
+  players := map[int32]float64{}
+  owners := map[int32]client.FactionEnum{}
+  for _, planet := range war.Status.PlanetStatus {
+    players[planet.Index] = float64(planet.Players)
+    owners[planet.Index] = planet.Owner
+    if event := defense(war, planet.Index); event != nil {
+      owners[planet.Index] = event.Race
+    }
+  }
+  galaxy := &war.Stats.GalaxyStats
+  for i := range war.Stats.PlanetsStats {
+    planet := &war.Stats.PlanetsStats[i]
+    s.missions[planet.PlanetIndex] += players[planet.PlanetIndex] / playersPerMission * seconds / missionDuration.Seconds()
+    missions := int64(s.missions[planet.PlanetIndex])
+    if missions == 0 {
+      continue
+    }
+    s.missions[planet.PlanetIndex] -= float64(missions)
+
+    won := int64(math.Round(float64(missions) * (0.75 + 0.2*s.rng.Float64())))
+    kills := missions * int64(80+s.rng.Intn(60))
+    fired := missions * int64(900+s.rng.Intn(200))
+    hit := fired * int64(70+s.rng.Intn(20)) / 100
+    deaths := missions * int64(2+s.rng.Intn(3))
+    friendlies := missions * int64(s.rng.Intn(2))
+    played := int64(players[planet.PlanetIndex] * seconds)
+
+    planet.MissionsWon += won
+    planet.MissionsLost += missions - won
+    planet.MissionTime += missions * int64(missionDuration.Seconds())
+    planet.TimePlayed += played
+    planet.BulletsFired += fired
+    planet.BulletsHit += hit
+    planet.Deaths += deaths
+    planet.Friendlies += friendlies
+    galaxy.MissionsWon += won
+    galaxy.MissionsLost += missions - won
+    galaxy.MissionTime += missions * int64(missionDuration.Seconds())
+    galaxy.TimePlayed += played
+    galaxy.BulletsFired += fired
+    galaxy.BulletsHit += hit
+    galaxy.Deaths += deaths
+    galaxy.Friendlies += friendlies
+    switch owners[planet.PlanetIndex] {
+    case terminids:
+      planet.BugKills += kills
+      galaxy.BugKills += kills
+    case automatons:
+      planet.AutomatonKills += kills
+      galaxy.AutomatonKills += kills
+    }
+    planet.MissionSuccessRate = 100 * planet.MissionsWon / (planet.MissionsWon + planet.MissionsLost)
+  }
+  if total := galaxy.MissionsWon + galaxy.MissionsLost; total > 0 {
+    galaxy.MissionSuccessRate = 100 * galaxy.MissionsWon / total
+  }
+}
+
+// Publish a news feed entry, in every language of the feed.
+// Generated messages are in English.
+func (s *Simulator) publish(war *War, message string) {
+  entry := client.NewsEntry{
+    Id:        s.id(),
+    Published: war.Status.Time,
+    Message:   message,
+    TagIds:    []interface{}{},
+  }
+  if len(war.News) == 0 {
+    war.News[DefaultLanguage] = []client.NewsEntry{}
+  }
+  for language := range war.News {
+    war.News[language] = append(war.News[language], entry)
+  }
+}
@@ -0,0 +1,221 @@
+package mockapi
+
+import (
+  "encoding/json"
+  "math"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// War 801 of the default fixtures, a fresh copy on every call
+func fixtureWar(t *testing.T) *War {
+  t.Helper()
+  wars, err := LoadFixtures(DefaultFixtures())
+  if err != nil {
+    t.Fatal(err)
+  }
+  return wars[801]
+}
+
+// Advance a simulation of the fixture war and return its state after each step
+func simulate(t *testing.T, seed int64, steps []time.Duration) []string {
+  t.Helper()
+  war := fixtureWar(t)
+  simulator := NewSimulator(seed, 801, war)
+  states := []string{}
+  for _, d := range steps {
+    simulator.Advance(war, d)
+    state, err := json.Marshal(war)
+    if err != nil {
+      t.Fatal(err)
+    }
+    states = append(states, string(state))
+  }
+  return states
+}
+
+func TestSimulatorDeterminism(t *testing.T) {
+  // Three simulated days, long enough for defenses and major orders
+  steps := []time.Duration{time.Second, 10 * time.Minute, 90 * time.Minute}
+  for i := 0; i < 24; i++ {
+    steps = append(steps, 3*time.Hour)
+  }
+  first := simulate(t, 42, steps)
+  second := simulate(t, 42, steps)
+  for i := range first {
+    if first[i] != second[i] {
+      t.Fatalf("step %d: the same seed produced different wars", i+1)
+    }
+  }
+
+  initial, err := json.Marshal(fixtureWar(t))
+  if err != nil {
+    t.Fatal(err)
+  }
+  if first[len(first)-1] == string(initial) {
+    t.Error("the war did not evolve")
+  }
+  other := simulate(t, 43, steps)
+  if other[len(other)-1] == first[len(first)-1] {
+    t.Error("another seed produced the same war")
+  }
+}
+
+func TestSimulatorPlanetNames(t *testing.T) {
+  simulator := NewSimulator(1, 801, fixtureWar(t))
+  if name := simulator.planetName(0); name != "Super Earth" {
+    t.Errorf("name of planet 0: got %q, want Super Earth", name)
+  }
+  if name := simulator.planetName(-1); name != "Planet -1" {
+    t.Errorf("name of an unknown planet: got %q, want Planet -1", name)
+  }
+}
+
+// Planet of the war status with the given index
+func planetStatus(t *testing.T, war *War, index int32) client.PlanetStatus {
+  t.Helper()
+  for _, planet := range war.Status.PlanetStatus {
+    if planet.Index == index {
+      return planet
+    }
+  }
+  t.Fatalf("planet %d not found", index)
+  return client.PlanetStatus{}
+}
+
+func lanes(war *War, filter func(lane client.PlanetAttack) bool) []client.PlanetAttack {
+  matching := []client.PlanetAttack{}
+  for _, lane := range war.Status.PlanetAttacks {
+    if filter(lane) {
+      matching = append(matching, lane)
+    }
+  }
+  return matching
+}
+
+func lastNews(war *War) string {
+  news := war.News[DefaultLanguage]
+  if len(news) == 0 {
+    return ""
+  }
+  return news[len(news)-1].Message
+}
+
+// Advance the fixture war by one simulation step, with the health of planet
+// `index` set beforehand. Players are spread the same way whatever the health.
+func stepWithHealth(t *testing.T, index int32, health int32) *War {
+  t.Helper()
+  war := fixtureWar(t)
+  simulator := NewSimulator(42, 801, war)
+  for i := range war.Status.PlanetStatus {
+    if war.Status.PlanetStatus[i].Index == index {
+      war.Status.PlanetStatus[i].Health = health
+    }
+  }
+  simulator.Advance(war, simulationStep)
+  return war
+}
+
+// A planet is liberated in the step its health would drop below zero: the
+// remaining health is its liberation ETA at the current damage rate
+func TestSimulatorLiberation(t *testing.T) {
+  // Planet 79 is attacked from planet 5, and regenerates
+  const index = 79
+  war := stepWithHealth(t, index, 100000)
+  planet := planetStatus(t, war, index)
+  loss := 100000 - planet.Health
+  damage := float64(planet.Players) * float64(war.Status.ImpactMultiplier) * playerDamage * simulationStep.Seconds()
+  regenerated := float64(planet.RegenPerSecond) * simulationStep.Seconds()
+  if planet.Players == 0 || math.Abs(float64(loss)-(damage-regenerated)) > 1 {
+    t.Fatalf("health loss in a step: got %d with %d players, want %v", loss, planet.Players, damage-regenerated)
+  }
+
+  war = stepWithHealth(t, index, loss+1000)
+  if planet := planetStatus(t, war, index); planet.Owner != terminids || planet.Health != 1000 {
+    t.Errorf("planet with health left after the step: got owner %d, health %d", planet.Owner, planet.Health)
+  }
+
+  war = stepWithHealth(t, index, loss)
+  planet = planetStatus(t, war, index)
+  if planet.Owner != superEarth || planet.Health != maxHealth(war, index) || planet.RegenPerSecond != regen(superEarth) {
+    t.Errorf("liberated planet: got %+v", planet)
+  }
+  if to := lanes(war, func(l client.PlanetAttack) bool { return l.Destination == index }); len(to) != 0 {
+    t.Errorf("supply lanes still attacking the liberated planet: %v", to)
+  }
+  if from := lanes(war, func(l client.PlanetAttack) bool { return l.Source == index }); len(from) == 0 {
+    t.Error("no supply lane starts from the liberated planet")
+  }
+  if news := lastNews(war); !strings.Contains(news, "liberated") {
+    t.Errorf("news: got %q, want a liberation", news)
+  }
+}
+
+// Advance the fixture war by one simulation step, with the defense campaign
+// of planet 127 expiring `expireIn` seconds later and at `health`
+func stepDefense(t *testing.T, expireIn int64, health int32) *War {
+  t.Helper()
+  war := fixtureWar(t)
+  simulator := NewSimulator(42, 801, war)
+  war.Status.PlanetEvents[0].ExpireTime = war.Status.Time + expireIn
+  war.Status.PlanetEvents[0].Health = health
+  simulator.Advance(war, simulationStep)
+  return war
+}
+
+func defenseOf(war *War, index int32) *client.PlanetEvent {
+  for i, event := range war.Status.PlanetEvents {
+    if event.PlanetIndex == index {
+      return &war.Status.PlanetEvents[i]
+    }
+  }
+  return nil
+}
+
+func TestSimulatorDefense(t *testing.T) {
+  // Planet 127 is defended against the automatons
+  const index = 127
+
+  war := stepDefense(t, 120, defenseMaxHealth)
+  event := defenseOf(war, index)
+  if event == nil || event.Health >= defenseMaxHealth {
+    t.Fatalf("ongoing defense: got %+v", event)
+  }
+  if owner := planetStatus(t, war, index).Owner; owner != superEarth {
+    t.Errorf("owner during the defense: got %d, want Super Earth", owner)
+  }
+
+  // Expired before the defense health is depleted: the planet falls
+  war = stepDefense(t, 30, defenseMaxHealth)
+  if event := defenseOf(war, index); event != nil {
+    t.Errorf("expired defense still listed: %+v", event)
+  }
+  planet := planetStatus(t, war, index)
+  if planet.Owner != automatons || planet.Health != maxHealth(war, index)*9/10 || planet.RegenPerSecond != regen(automatons) {
+    t.Errorf("lost planet: got %+v", planet)
+  }
+  if from := lanes(war, func(l client.PlanetAttack) bool { return l.Source == index }); len(from) != 0 {
+    t.Errorf("supply lanes still start from the lost planet: %v", from)
+  }
+  if to := lanes(war, func(l client.PlanetAttack) bool { return l.Destination == index }); len(to) != 1 {
+    t.Errorf("supply lanes retaking the lost planet: got %v, want 1", to)
+  }
+  if news := lastNews(war); !strings.Contains(news, "has fallen") {
+    t.Errorf("news: got %q, want a fallen planet", news)
+  }
+
+  // Defense health depleted before the expiration: the planet is kept
+  war = stepDefense(t, 30, 1)
+  if event := defenseOf(war, index); event != nil {
+    t.Errorf("won defense still listed: %+v", event)
+  }
+  if owner := planetStatus(t, war, index).Owner; owner != superEarth {
+    t.Errorf("owner after a won defense: got %d, want Super Earth", owner)
+  }
+  if news := lastNews(war); !strings.Contains(news, "successfully defended") {
+    t.Errorf("news: got %q, want a won defense", news)
+  }
+}
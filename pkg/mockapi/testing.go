@@ -0,0 +1,33 @@
+package mockapi
+
+import (
+  "net/http/httptest"
+  "testing"
+)
+
+// TestServer is a mock API serving the default fixtures, for the tests of
+// the API clients
+type TestServer struct {
+  *Server
+  // Faults injected in the responses, none until the test sets some
+  Faults *Faults
+  // Base URL of the API, with the /api base path
+  URL string
+}
+
+// Start a mock API serving the default fixtures, with up to `pageSize`
+// news feed entries per response. The server is closed when the test ends.
+func NewTestServer(t testing.TB, pageSize int) *TestServer {
+  t.Helper()
+  wars, err := LoadFixtures(DefaultFixtures())
+  if err != nil {
+    t.Fatal(err)
+  }
+  server := NewServer(wars, pageSize)
+  faults := NewFaults(1, nil)
+  e := server.Handler()
+  e.Use(faults.Middleware)
+  api := httptest.NewServer(e)
+  t.Cleanup(api.Close)
+  return &TestServer{Server: server, Faults: faults, URL: api.URL + "/api"}
+}
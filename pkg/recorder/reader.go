@@ -0,0 +1,78 @@
+package recorder
+
+import (
+  "compress/gzip"
+  "encoding/json"
+  "errors"
+  "io"
+  "log/slog"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+)
+
+// Read the entries of a recording file, or of every recording file of a
+// directory, sorted by time.
+// A file truncated by a process that did not exit cleanly is read up to
+// its last complete entry.
+func Read(path string) ([]Entry, error) {
+  info, err := os.Stat(path)
+  if err != nil {
+    return nil, err
+  }
+  files := []string{path}
+  if info.IsDir() {
+    files, err = filepath.Glob(filepath.Join(path, "*.jsonl.gz"))
+    if err != nil {
+      return nil, err
+    }
+  }
+  entries := []Entry{}
+  for _, file := range files {
+    fileEntries, err := readFile(file)
+    if err != nil {
+      return nil, err
+    }
+    entries = append(entries, fileEntries...)
+  }
+  sort.SliceStable(entries, func(i, j int) bool {
+    return entries[i].Time.Before(entries[j].Time)
+  })
+  return entries, nil
+}
+
+func readFile(path string) ([]Entry, error) {
+  file, err := os.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  defer file.Close()
+  var r io.Reader = file
+  if strings.HasSuffix(path, ".gz") {
+    gz, err := gzip.NewReader(file)
+    if err != nil {
+      return nil, err
+    }
+    defer gz.Close()
+    r = gz
+  }
+
+  entries := []Entry{}
+  decoder := json.NewDecoder(r)
+  for {
+    entry := Entry{}
+    err := decoder.Decode(&entry)
+    if err == io.EOF {
+      return entries, nil
+    }
+    if errors.Is(err, io.ErrUnexpectedEOF) {
+      slog.Warn("Truncated recording file", slog.String("file", path), slog.Int("entries", len(entries)))
+      return entries, nil
+    }
+    if err != nil {
+      return nil, err
+    }
+    entries = append(entries, entry)
+  }
+}
@@ -0,0 +1,146 @@
+// Package recorder archives the raw requests and responses of the upstream API,
+// to investigate game bugs and schema changes after the fact.
+package recorder
+
+import (
+  "bytes"
+  "encoding/json"
+  "io"
+  "log/slog"
+  "net/http"
+  "strconv"
+  "strings"
+  "time"
+
+  "github.com/Xide/helldivers2-dashboard/pkg/client"
+)
+
+// Entry is a recorded request and its response, one line of a recording file
+type Entry struct {
+  Time time.Time `json:"time"`
+  // Path of the request with the war ID replaced by {war_id},
+  // e.g. /api/WarSeason/{war_id}/Status
+  Route  string `json:"route"`
+  WarID  int    `json:"war_id,omitempty"`
+  Method string `json:"method"`
+  URL    string `json:"url"`
+  // Duration of the request, in seconds
+  Duration       float64     `json:"duration"`
+  RequestHeaders http.Header `json:"request_headers"`
+  // HTTP status code, 0 when the request failed without a response
+  Status  int         `json:"status"`
+  Headers http.Header `json:"headers,omitempty"`
+  // Response body, when it is valid JSON
+  Body json.RawMessage `json:"body,omitempty"`
+  // Response body otherwise, base64 encoded
+  RawBody []byte `json:"raw_body,omitempty"`
+  // Error of the request, or of reading its response
+  Error string `json:"error,omitempty"`
+}
+
+// Request headers never written to the recordings: credentials, and the
+// contact of the operator sent to the community API
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "X-Super-Contact"}
+
+// Split the war ID out of a request path.
+// The war ID is the first numeric segment of the path, all the upstream
+// routes taking a war ID have it before any other number.
+func routeOf(path string) (string, int) {
+  segments := strings.Split(path, "/")
+  for i, segment := range segments {
+    warID, err := strconv.Atoi(segment)
+    if err == nil {
+      segments[i] = "{war_id}"
+      return strings.Join(segments, "/"), warID
+    }
+  }
+  return path, 0
+}
+
+// Doer wraps an HttpRequestDoer and records every request it performs
+type Doer struct {
+  next client.HttpRequestDoer
+  // Nil when recording is disabled, requests are only forwarded to next
+  writer *Writer
+}
+
+// New wraps `next`, appending its requests and responses to `writer`
+func New(next client.HttpRequestDoer, writer *Writer) *Doer {
+  return &Doer{next: next, writer: writer}
+}
+
+func (d *Doer) Do(req *http.Request) (*http.Response, error) {
+  if d.writer == nil {
+    return d.next.Do(req)
+  }
+  route, warID := routeOf(req.URL.Path)
+  entry := &Entry{
+    Time:           time.Now().UTC(),
+    Route:          route,
+    WarID:          warID,
+    Method:         req.Method,
+    URL:            req.URL.String(),
+    RequestHeaders: req.Header.Clone(),
+  }
+  for _, header := range redactedHeaders {
+    entry.RequestHeaders.Del(header)
+  }
+
+  res, err := d.next.Do(req)
+  entry.Duration = time.Since(entry.Time).Seconds()
+  if err != nil {
+    entry.Error = err.Error()
+    d.write(entry)
+    return res, err
+  }
+  entry.Status = res.StatusCode
+  entry.Headers = res.Header.Clone()
+
+  // Read the body to record it, and hand a copy to the caller
+  body, err := io.ReadAll(res.Body)
+  res.Body.Close()
+  res.Body = io.NopCloser(bytes.NewReader(body))
+  if err != nil {
+    entry.Error = err.Error()
+    d.write(entry)
+    return nil, err
+  }
+  if json.Valid(body) {
+    entry.Body = body
+  } else if len(body) > 0 {
+    entry.RawBody = body
+  }
+  d.write(entry)
+  return res, nil
+}
+
+// A recording failure must not break the caller, it is only logged
+func (d *Doer) write(entry *Entry) {
+  err := d.writer.Write(entry)
+  if err != nil {
+    slog.Error("Failed to record upstream response", slog.String("route", entry.Route), slog.Any("error", err))
+  }
+}
+
+// Close the recording file, later requests are no longer recorded
+func (d *Doer) Close() error {
+  if d.writer == nil {
+    return nil
+  }
+  return d.writer.Close()
+}
+
+// Wrap `next` in a recording Doer writing to opts.Dir, only forwarding the
+// requests when recording is disabled (empty opts.Dir).
+// Close it on shutdown, to terminate the gzip stream of the last file.
+func Wrap(next client.HttpRequestDoer, opts Options) (*Doer, error) {
+  if opts.Dir == "" {
+    return New(next, nil), nil
+  }
+  writer, err := NewWriter(opts)
+  if err != nil {
+    return nil, err
+  }
+  slog.Info("Recording upstream responses", slog.String("dir", opts.Dir), slog.Int64("max_size", opts.MaxSize), slog.Duration("max_age", opts.MaxAge))
+  return New(next, writer), nil
+}
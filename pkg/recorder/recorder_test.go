@@ -0,0 +1,232 @@
+package recorder
+
+import (
+  "compress/gzip"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// Upstream API answering JSON on the war routes, and plain text otherwise
+func startUpstream(t *testing.T) *httptest.Server {
+  t.Helper()
+  server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path == "/api/WarSeason/801/Status" {
+      w.Header().Set("Content-Type", "application/json")
+      io.WriteString(w, `{"warId":801}`)
+      return
+    }
+    w.WriteHeader(http.StatusServiceUnavailable)
+    io.WriteString(w, "upstream unavailable")
+  }))
+  t.Cleanup(server.Close)
+  return server
+}
+
+// Perform a GET request through the doer, and return the body seen by the caller
+func get(t *testing.T, doer *Doer, url string, headers map[string]string) string {
+  t.Helper()
+  req, err := http.NewRequest(http.MethodGet, url, nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  for k, v := range headers {
+    req.Header.Set(k, v)
+  }
+  res, err := doer.Do(req)
+  if err != nil {
+    return ""
+  }
+  defer res.Body.Close()
+  body, err := io.ReadAll(res.Body)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return string(body)
+}
+
+// Decompressed content of a recording file
+func readRecording(t *testing.T, file string) string {
+  t.Helper()
+  f, err := os.Open(file)
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer f.Close()
+  reader, err := gzip.NewReader(f)
+  if err != nil {
+    t.Fatal(err)
+  }
+  content, err := io.ReadAll(reader)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return string(content)
+}
+
+func recordingFiles(t *testing.T, dir string) []string {
+  t.Helper()
+  files, err := filepath.Glob(filepath.Join(dir, "*.jsonl.gz"))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return files
+}
+
+func TestRecordAndRead(t *testing.T) {
+  upstream := startUpstream(t)
+  dir := t.TempDir()
+  // Every entry is larger than 1 byte, each one rotates the file
+  doer, err := Wrap(&http.Client{}, Options{Dir: dir, Prefix: "test", MaxSize: 1})
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  body := get(t, doer, upstream.URL+"/api/WarSeason/801/Status", map[string]string{
+    "Authorization":   "Bearer secret",
+    "X-Super-Contact": "ops@example.com",
+    "X-Request-Id":    "request-1",
+  })
+  if body != `{"warId":801}` {
+    t.Errorf("body handed to the caller: got %q", body)
+  }
+  get(t, doer, upstream.URL+"/api/raw", nil)
+  get(t, doer, "http://127.0.0.1:1/api/WarSeason/801/Status", nil)
+  err = doer.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  if files := recordingFiles(t, dir); len(files) != 3 {
+    t.Errorf("got %d recording files, want 3 (one per entry)", len(files))
+  }
+  entries, err := Read(dir)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(entries) != 3 {
+    t.Fatalf("got %d entries, want 3", len(entries))
+  }
+
+  status := entries[0]
+  if status.Route != "/api/WarSeason/{war_id}/Status" || status.WarID != 801 || status.Status != 200 {
+    t.Errorf("json entry: got %+v", status)
+  }
+  if string(status.Body) != `{"warId":801}` || len(status.RawBody) != 0 {
+    t.Errorf("json entry body: got %s, raw %q", status.Body, status.RawBody)
+  }
+  for _, header := range []string{"Authorization", "X-Super-Contact"} {
+    if status.RequestHeaders.Get(header) != "" {
+      t.Errorf("the %s header was recorded", header)
+    }
+  }
+  for _, file := range recordingFiles(t, dir) {
+    content := readRecording(t, file)
+    if strings.Contains(content, "Bearer secret") || strings.Contains(content, "ops@example.com") {
+      t.Errorf("%s: redacted header written: %s", file, content)
+    }
+  }
+  if status.RequestHeaders.Get("X-Request-Id") != "request-1" {
+    t.Errorf("request ID: got %q, want request-1", status.RequestHeaders.Get("X-Request-Id"))
+  }
+
+  raw := entries[1]
+  if raw.Route != "/api/raw" || raw.Status != 503 || raw.Body != nil || string(raw.RawBody) != "upstream unavailable" {
+    t.Errorf("raw entry: got %+v", raw)
+  }
+
+  failed := entries[2]
+  if failed.Status != 0 || failed.Error == "" {
+    t.Errorf("failed entry: got status %d, error %q", failed.Status, failed.Error)
+  }
+  for i := 1; i < len(entries); i++ {
+    if entries[i].Time.Before(entries[i-1].Time) {
+      t.Errorf("entries not sorted by time: %v before %v", entries[i-1].Time, entries[i].Time)
+    }
+  }
+
+  // Closed: requests are still forwarded, but no longer recorded
+  get(t, doer, upstream.URL+"/api/WarSeason/801/Status", nil)
+  if files := recordingFiles(t, dir); len(files) != 3 {
+    t.Errorf("got %d recording files after closing, want 3", len(files))
+  }
+}
+
+// A file of a process that did not exit cleanly is read up to its last
+// complete entry: entries are flushed, but the gzip stream is not terminated
+func TestReadUnclosedFile(t *testing.T) {
+  upstream := startUpstream(t)
+  dir := t.TempDir()
+  doer, err := Wrap(&http.Client{}, Options{Dir: dir, Prefix: "test"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  for i := 0; i < 3; i++ {
+    get(t, doer, upstream.URL+"/api/WarSeason/801/Status", nil)
+  }
+  files := recordingFiles(t, dir)
+  if len(files) != 1 {
+    t.Fatalf("got %d recording files, want 1", len(files))
+  }
+  entries, err := Read(files[0])
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(entries) != 3 {
+    t.Errorf("got %d entries of the unclosed file, want 3", len(entries))
+  }
+
+  // Cut in the middle of the last entry
+  content, err := os.ReadFile(files[0])
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = os.WriteFile(files[0], content[:len(content)-10], 0o644)
+  if err != nil {
+    t.Fatal(err)
+  }
+  entries, err = Read(files[0])
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(entries) != 2 {
+    t.Errorf("got %d entries of the truncated file, want 2", len(entries))
+  }
+}
+
+func TestWrapDisabled(t *testing.T) {
+  upstream := startUpstream(t)
+  doer, err := Wrap(&http.Client{}, Options{})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if body := get(t, doer, upstream.URL+"/api/WarSeason/801/Status", nil); body != `{"warId":801}` {
+    t.Errorf("body: got %q", body)
+  }
+  err = doer.Close()
+  if err != nil {
+    t.Error(err)
+  }
+}
+
+func TestRouteOf(t *testing.T) {
+  tests := []struct {
+    path  string
+    route string
+    warID int
+  }{
+    {"/api/WarSeason/801/Status", "/api/WarSeason/{war_id}/Status", 801},
+    {"/api/v2/Assignment/War/801", "/api/v2/Assignment/War/{war_id}", 801},
+    {"/api/v1/planets", "/api/v1/planets", 0},
+  }
+  for _, test := range tests {
+    route, warID := routeOf(test.path)
+    if route != test.route || warID != test.warID {
+      t.Errorf("routeOf(%q): got %q %d, want %q %d", test.path, route, warID, test.route, test.warID)
+    }
+  }
+}
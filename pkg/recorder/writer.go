@@ -0,0 +1,147 @@
+package recorder
+
+import (
+  "compress/gzip"
+  "encoding/json"
+  "fmt"
+  "os"
+  "path/filepath"
+  "sync"
+  "time"
+)
+
+// Options of the recording files
+type Options struct {
+  // Directory of the recording files, created if missing
+  Dir string
+  // Prefix of the file names, e.g. `exporter` gives exporter-20240301T120000Z.jsonl.gz
+  Prefix string
+  // A file is rotated once it holds more than MaxSize compressed bytes, 0 disables the limit
+  MaxSize int64
+  // A file is rotated once it is older than MaxAge, 0 disables the limit
+  MaxAge time.Duration
+}
+
+// Writer appends entries to gzip compressed JSONL files, rotated by size and age.
+// Rotated files are kept, the recordings are an archive.
+type Writer struct {
+  opts Options
+
+  mu      sync.Mutex
+  file    *os.File
+  gzip    *gzip.Writer
+  size    int64
+  created time.Time
+  closed  bool
+}
+
+func NewWriter(opts Options) (*Writer, error) {
+  if opts.Dir == "" {
+    return nil, fmt.Errorf("recording directory is required")
+  }
+  if opts.Prefix == "" {
+    opts.Prefix = "recording"
+  }
+  err := os.MkdirAll(opts.Dir, 0o755)
+  if err != nil {
+    return nil, err
+  }
+  return &Writer{opts: opts}, nil
+}
+
+// Counts the compressed bytes written to the file
+type countingWriter struct {
+  w *Writer
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+  n, err := c.w.file.Write(p)
+  c.w.size += int64(n)
+  return n, err
+}
+
+// Open a new file. Files are named after their creation time,
+// a suffix is added if a file already exists with the same name.
+func (w *Writer) open(now time.Time) error {
+  base := fmt.Sprintf("%s-%s", w.opts.Prefix, now.UTC().Format("20060102T150405Z"))
+  name := filepath.Join(w.opts.Dir, base+".jsonl.gz")
+  for i := 1; ; i++ {
+    file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+    if os.IsExist(err) {
+      name = filepath.Join(w.opts.Dir, fmt.Sprintf("%s-%d.jsonl.gz", base, i))
+      continue
+    }
+    if err != nil {
+      return err
+    }
+    w.file = file
+    break
+  }
+  w.gzip = gzip.NewWriter(countingWriter{w})
+  w.size = 0
+  w.created = now
+  return nil
+}
+
+// Close the current file, if any
+func (w *Writer) closeFile() error {
+  if w.file == nil {
+    return nil
+  }
+  err := w.gzip.Close()
+  closeErr := w.file.Close()
+  w.file, w.gzip = nil, nil
+  if err != nil {
+    return err
+  }
+  return closeErr
+}
+
+func (w *Writer) shouldRotate(now time.Time) bool {
+  if w.opts.MaxSize > 0 && w.size >= w.opts.MaxSize {
+    return true
+  }
+  return w.opts.MaxAge > 0 && now.Sub(w.created) >= w.opts.MaxAge
+}
+
+// Append an entry to the current file, rotating it first if needed
+func (w *Writer) Write(entry *Entry) error {
+  line, err := json.Marshal(entry)
+  if err != nil {
+    return err
+  }
+  line = append(line, '\n')
+
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  if w.closed {
+    return fmt.Errorf("recording writer closed")
+  }
+  now := time.Now()
+  if w.file != nil && w.shouldRotate(now) {
+    err = w.closeFile()
+    if err != nil {
+      return err
+    }
+  }
+  if w.file == nil {
+    err = w.open(now)
+    if err != nil {
+      return err
+    }
+  }
+  _, err = w.gzip.Write(line)
+  if err != nil {
+    return err
+  }
+  // Flush every entry, so the file stays readable if the process dies
+  return w.gzip.Flush()
+}
+
+// Close the current file, later writes fail
+func (w *Writer) Close() error {
+  w.mu.Lock()
+  defer w.mu.Unlock()
+  w.closed = true
+  return w.closeFile()
+}